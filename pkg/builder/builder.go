@@ -0,0 +1,472 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query formats of the builder
+const (
+	FormatTable      = "table"
+	FormatTimeSeries = "timeseries"
+	FormatLogs       = "logs"
+)
+
+const logLevelAlias = "level"
+
+// Options is the query model of the visual query builder, see SqlBuilderOptions in src/containers/QueryEditor/types.ts
+type Options struct {
+	Table         string        `json:"table,omitempty"`
+	Fields        []string      `json:"fields,omitempty"`
+	LoglineFields []string      `json:"loglineFields,omitempty"`
+	Limit         Limit         `json:"limit,omitempty"`
+	LogLevelField string        `json:"logLevelField,omitempty"`
+	LogTimeField  *LogTimeField `json:"logTimeField,omitempty"`
+	Filters       []Filter      `json:"filters,omitempty"`
+	GroupBy       []string      `json:"groupBy,omitempty"`
+	Aggregations  []Aggregation `json:"aggregations,omitempty"`
+	OrderBy       []OrderBy     `json:"orderBy,omitempty"`
+	// LogContextColumns are columns equal in rows of the log context, e.g. the host
+	LogContextColumns []string `json:"logContextColumns,omitempty"`
+	// LogContextField is the unique column ordering rows of the log context with the same time
+	LogContextField string `json:"logContextField,omitempty"`
+}
+
+type LogTimeField struct {
+	Name string `json:"name,omitempty"`
+	Cast string `json:"cast,omitempty"`
+}
+
+type Filter struct {
+	LogicalOp  string   `json:"logicalOp,omitempty"`
+	Column     string   `json:"column,omitempty"`
+	Expr       string   `json:"expr,omitempty"`
+	Params     []string `json:"params,omitempty"`
+	ParamsType string   `json:"paramsType,omitempty"`
+	SkipEmpty  bool     `json:"skipEmpty,omitempty"`
+}
+
+type Aggregation struct {
+	AggregationFunction string            `json:"aggregationFunction,omitempty"`
+	Column              string            `json:"column,omitempty"`
+	Alias               string            `json:"alias,omitempty"`
+	Params              AggregationParams `json:"params"`
+}
+
+type AggregationParams struct {
+	Distinct bool `json:"distinct,omitempty"`
+}
+
+type OrderBy struct {
+	Column        string `json:"column,omitempty"`
+	SortDirection string `json:"sortDirection,omitempty"`
+}
+
+// Limit is the row limit, the frontend saves it either as a string or as a number
+type Limit string
+
+func (l *Limit) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*l = Limit(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		// null and other values don't limit the query
+		*l = ""
+		return nil
+	}
+	*l = Limit(n.String())
+	return nil
+}
+
+var expressions = map[string]string{
+	"like":             "LIKE",
+	"notLike":          "NOT LIKE",
+	"regexp":           "REGEXP",
+	"equals":           "=",
+	"harshEquals":      "==",
+	"notEquals":        "!=",
+	"lessOrGtr":        "<>",
+	"gtr":              ">",
+	"gtrOrEquals":      ">=",
+	"less":             "<",
+	"lessOrEquals":     "<=",
+	"null":             "IS NULL",
+	"notNull":          "IS NOT NULL",
+	"between":          "BETWEEN",
+	"notBetween":       "NOT BETWEEN",
+	"in":               "IN",
+	"notIn":            "NOT IN",
+	"insideDashboard":  "BETWEEN $__fromTimestamp AND $__toTimestamp",
+	"outsideDashboard": "NOT BETWEEN $__fromTimestamp AND $__toTimestamp",
+	"isTrue":           "== true",
+	"isFalse":          "== false",
+}
+
+var (
+	expressionsWithMultipleParams = []string{"in", "notIn", "between", "notBetween"}
+	expressionsWithoutParams      = []string{"null", "notNull", "insideDashboard", "outsideDashboard", "isTrue", "isFalse"}
+	panelVariables                = []string{"$__fromTimestamp", "$__toTimestamp"}
+)
+
+var logicalOps = map[string]string{
+	"and": "AND",
+	"or":  "OR",
+}
+
+var aggregationFunctions = map[string]string{
+	"count": "COUNT",
+	"min":   "MIN",
+	"max":   "MAX",
+	"sum":   "SUM",
+	"avg":   "AVG",
+	"some":  "SOME",
+}
+
+var primitiveTypes = []string{
+	"Bool", "String", "Utf8", "Int8", "Int16", "Int32", "Int64", "Uint8", "Uint16", "Uint32", "Uint64",
+	"Float", "Double", "Decimal", "Date", "Datetime", "Timestamp", "Interval", "TzDate", "TzDatetime",
+	"TzTimestamp", "Yson", "Json", "Uuid", "Dynumber", "JsonDocument",
+}
+
+// Compile returns YQL of the builder query, the text matches getRawSqlFromBuilderOptions of the frontend
+func Compile(options Options, format string) string {
+	fields := selectFields(options, format)
+
+	var b strings.Builder
+	b.WriteString("SELECT")
+	if len(fields) > 0 {
+		b.WriteString(" " + strings.Join(fields, ", \n"))
+	}
+	b.WriteString(" \nFROM")
+	if options.Table != "" {
+		b.WriteString(" " + QuoteIdentifier(options.Table))
+	}
+	b.WriteString(where(options.Filters))
+	b.WriteString(groupBy(options.GroupBy))
+	b.WriteString(orderBy(options.OrderBy))
+	b.WriteString(limit(options.Limit))
+	return b.String()
+}
+
+// selectFields returns expressions of selected fields, log fields and aggregations
+func selectFields(options Options, format string) []string {
+	isLogs := format == FormatLogs
+	var fields []string
+	if isLogs {
+		if logLine := logLineFields(options.LoglineFields, options.LogTimeField); logLine != "" {
+			fields = append(fields, logLine)
+		}
+	}
+	selected := make([]field, 0, len(options.Fields))
+	for _, name := range options.Fields {
+		selected = append(selected, field{name: name})
+	}
+	if isLogs {
+		selected = logFields(selected, options.LogLevelField, options.LogTimeField)
+	}
+	for _, f := range selected {
+		fields = append(fields, f.expression())
+	}
+	for _, aggregation := range options.Aggregations {
+		if expression := aggregation.expression(); expression != "" {
+			fields = append(fields, expression)
+		}
+	}
+	return fields
+}
+
+// QuoteIdentifier wraps the identifier in backticks
+func QuoteIdentifier(s string) string {
+	return quote(s, "`")
+}
+
+// QuoteString wraps the string in double quotes, a YQL string literal
+func QuoteString(s string) string {
+	return quote(s, `"`)
+}
+
+// quote escapes backslashes, wrappers and control characters with YQL escape sequences
+func quote(s string, wrapper string) string {
+	var b strings.Builder
+	b.WriteString(wrapper)
+	for _, r := range s {
+		switch {
+		case r == '\\' || string(r) == wrapper:
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < ' ':
+			fmt.Fprintf(&b, `\x%02x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString(wrapper)
+	return b.String()
+}
+
+type field struct {
+	name        string
+	alias       string
+	transformer func(name string) string
+}
+
+func (f field) expression() string {
+	expression := QuoteIdentifier(f.name)
+	if f.transformer != nil {
+		expression = f.transformer(f.name)
+	}
+	if f.alias == "" {
+		return expression
+	}
+	return expression + " AS " + QuoteIdentifier(f.alias)
+}
+
+// logFields moves the log time field first and the lower-cased log level field last
+func logFields(fields []field, logLevelField string, logTimeField *LogTimeField) []field {
+	if logLevelField != "" {
+		fields = slices.DeleteFunc(fields, func(f field) bool { return f.name == logLevelField })
+		level := field{
+			name: logLevelField,
+			transformer: func(name string) string {
+				return "String::AsciiToLower(" + QuoteIdentifier(name) + ")"
+			},
+		}
+		if logLevelField != logLevelAlias {
+			level.alias = logLevelAlias
+		}
+		fields = append(fields, level)
+	}
+	if logTimeField != nil && logTimeField.Name != "" {
+		fields = slices.DeleteFunc(fields, func(f field) bool { return f.name == logTimeField.Name })
+		fields = append([]field{logTimeField.field()}, fields...)
+	}
+	return fields
+}
+
+// field returns the log time field, cast to the type of the field when it's set
+func (f LogTimeField) field() field {
+	timeField := field{name: f.Name}
+	if slices.Contains(primitiveTypes, f.Cast) {
+		timeField.transformer = func(name string) string {
+			return castAs(QuoteIdentifier(name), f.Cast)
+		}
+	}
+	return timeField
+}
+
+func castAs(expression string, castType string) string {
+	return "CAST(" + expression + " AS " + castType + ")"
+}
+
+// logLineFields concatenates name=value pairs of the fields into the logLine column
+func logLineFields(fields []string, logTimeField *LogTimeField) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		expression := QuoteIdentifier(f)
+		if logTimeField != nil && f == logTimeField.Name && logTimeField.Cast != "" {
+			expression = castAs(expression, logTimeField.Cast)
+		}
+		parts[i] = QuoteString(f+"=") + "||" + castAs(expression, "String")
+	}
+	return strings.Join(parts, "||"+QuoteString(", ")+"||") + " AS " + QuoteIdentifier("logLine")
+}
+
+func (a Aggregation) expression() string {
+	function, ok := aggregationFunctions[a.AggregationFunction]
+	if !ok || a.Column == "" {
+		return ""
+	}
+	column := a.Column
+	if column != "*" {
+		column = QuoteIdentifier(column)
+	}
+	distinct := ""
+	if a.Params.Distinct {
+		distinct = "DISTINCT "
+	}
+	expression := function + "(" + distinct + column + ")"
+	if a.Alias != "" {
+		expression += " AS " + QuoteIdentifier(a.Alias)
+	}
+	return expression
+}
+
+func where(filters []Filter) string {
+	conditions := filterConditions(filters)
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " \nWHERE \n" + strings.Join(conditions, " \n")
+}
+
+func filterConditions(filters []Filter) (conditions []string) {
+	for _, filter := range filters {
+		if filter.Column == "" {
+			continue
+		}
+		if condition := filter.expression(); condition != "" {
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions
+}
+
+func (f Filter) expression() string {
+	operator, ok := expressions[f.Expr]
+	if f.Column == "" || !ok {
+		return ""
+	}
+	var result []string
+	if op, ok := logicalOps[f.LogicalOp]; ok {
+		result = append(result, op)
+	}
+	condition := []string{QuoteIdentifier(f.Column), operator}
+	if slices.Contains(expressionsWithoutParams, f.Expr) {
+		return strings.Join(append(result, condition...), " ")
+	}
+	if params, ok := f.params(); ok {
+		condition = append(condition, params)
+	}
+	if f.SkipEmpty && f.fallbackAvailable() {
+		// the filter is skipped when the variable is empty
+		variable := f.Params[0]
+		result = append(result, "IF("+`"`+variable[:len(variable)-1]+`:text}"`+` == "", true, `+strings.Join(condition, " ")+")")
+	} else {
+		result = append(result, strings.Join(condition, " "))
+	}
+	return strings.Join(result, " ")
+}
+
+func (f Filter) params() (string, bool) {
+	if f.ParamsType == "" || len(f.Params) == 0 {
+		return "", false
+	}
+	if !slices.Contains(expressionsWithMultipleParams, f.Expr) {
+		return param(f.Params[0], f.ParamsType), true
+	}
+	params := make([]string, len(f.Params))
+	for i, p := range f.Params {
+		params[i] = param(p, f.ParamsType)
+	}
+	switch f.Expr {
+	case "in", "notIn":
+		return "(" + strings.Join(params, ", ") + ")", true
+	default:
+		return strings.Join(params, " AND "), true
+	}
+}
+
+func (f Filter) fallbackAvailable() bool {
+	if len(f.Params) == 0 {
+		return false
+	}
+	if slices.Contains(expressionsWithMultipleParams, f.Expr) && len(f.Params) != 1 {
+		return false
+	}
+	return isDashboardVariable(f.Params[0])
+}
+
+// param keeps numbers and variables as is and quotes strings
+func param(p string, paramsType string) string {
+	p = strings.TrimSpace(p)
+	if paramsType == "number" && isNumber(p) {
+		return p
+	}
+	if isVariable(p) {
+		return p
+	}
+	return QuoteString(p)
+}
+
+func isDashboardVariable(p string) bool {
+	return strings.HasPrefix(p, "${") && strings.HasSuffix(p, "}")
+}
+
+func isVariable(p string) bool {
+	return isDashboardVariable(p) || slices.Contains(panelVariables, p)
+}
+
+// isNumber reports whether the trimmed string is a number for JavaScript's Number(),
+// so parameters are quoted the same way as in the frontend
+func isNumber(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return true
+	}
+	unsigned := strings.TrimLeft(s, "+-")
+	if len(s)-len(unsigned) > 1 {
+		return false
+	}
+	if unsigned == "Infinity" {
+		return true
+	}
+	if len(unsigned) > 2 && unsigned[0] == '0' && strings.ContainsRune("xXoObB", rune(unsigned[1])) {
+		// hexadecimal, octal and binary literals can't have a sign
+		_, err := strconv.ParseUint(unsigned, 0, 64)
+		return err == nil && unsigned == s
+	}
+	if strings.ContainsAny(unsigned, "_nNiIxXpP") {
+		return false
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	return err == nil || math.IsInf(n, 0)
+}
+
+func groupBy(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = QuoteIdentifier(f)
+	}
+	return "\n GROUP BY " + strings.Join(quoted, ", ")
+}
+
+func orderBy(orderBy []OrderBy) string {
+	var fields []string
+	for _, o := range orderBy {
+		if o.Column == "" {
+			continue
+		}
+		expression := QuoteIdentifier(o.Column)
+		if o.SortDirection != "" {
+			expression += " " + o.SortDirection
+		}
+		fields = append(fields, expression)
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	return "\n ORDER BY " + strings.Join(fields, ", ")
+}
+
+func limit(l Limit) string {
+	value := string(l)
+	switch {
+	case value == "":
+		return ""
+	case isVariable(value):
+		return " \nLIMIT " + castAs(value, "Uint16")
+	case isNumber(value):
+		return " \nLIMIT " + value
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,211 @@
+package builder_test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type testQuery struct {
+	Format         string          `json:"format"`
+	BuilderOptions builder.Options `json:"builderOptions"`
+}
+
+// TestCompile compares YQL of testdata/*.json builder queries with testdata/*.yql,
+// the expected YQL is the output of the frontend for the same builder options,
+// src/containers/QueryEditor/prepare-query.golden.spec.ts checks the frontend against the same files
+func TestCompile(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, inputs)
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".json")
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile(input)
+			require.NoError(t, err)
+			var query testQuery
+			require.NoError(t, json.Unmarshal(raw, &query))
+			yql := builder.Compile(query.BuilderOptions, query.Format)
+
+			golden := strings.TrimSuffix(input, ".json") + ".yql"
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(yql), 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), yql)
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	assert.Equal(t, "`my \\`table\\` \\\\`", builder.QuoteIdentifier("my `table` \\"))
+	assert.Equal(t, `"say \"hi\""`, builder.QuoteString(`say "hi"`))
+}
+
+func TestCompileLogsVolume(t *testing.T) {
+	options := builder.Options{
+		Table:         "logs",
+		LogLevelField: "lvl",
+		LogTimeField:  &builder.LogTimeField{Name: "ts", Cast: "Timestamp"},
+		Filters:       []builder.Filter{{Column: "host", Expr: "equals", Params: []string{"a"}, ParamsType: "text"}},
+		Limit:         "100",
+	}
+	assert.Equal(t, "SELECT `time`, `level`, COUNT(*) AS `count` \n"+
+		"FROM `logs` \n"+
+		"WHERE CAST(`ts` AS Timestamp) BETWEEN $__fromTimestamp AND $__toTimestamp AND (`host` = \"a\") \n"+
+		"GROUP BY DateTime::MakeTimestamp(DateTime::StartOf(CAST(`ts` AS Timestamp), DateTime::IntervalFromMilliseconds(60000))) AS `time`, `lvl` AS `level` \n"+
+		"ORDER BY `time`",
+		builder.CompileLogsVolume(options, time.Minute))
+}
+
+func TestCompileLogContext(t *testing.T) {
+	options := builder.Options{
+		Table:             "logs",
+		Fields:            []string{"host", "message"},
+		LogTimeField:      &builder.LogTimeField{Name: "ts"},
+		LogContextColumns: []string{"host", "pod"},
+		LogContextField:   "id",
+		Limit:             "100",
+	}
+	logContext := builder.LogContext{
+		TimeNs:     1704067200123456789,
+		Columns:    map[string]json.RawMessage{"pod": []byte(`null`), "host": []byte(`"a"`)},
+		TieBreaker: []byte(`42`),
+		Limit:      10,
+	}
+	assert.Equal(t, "SELECT `ts`, \n`host`, \n`message` \n"+
+		"FROM `logs` \n"+
+		"WHERE `host` = \"a\" AND `pod` IS NULL AND (`ts`, `id`) < (CAST(1704067200123456 AS Timestamp), 42) \n"+
+		"ORDER BY `ts` DESC, `id` DESC \n"+
+		"LIMIT 10",
+		builder.CompileLogContext(options, logContext))
+
+	options.LogContextField = ""
+	logContext.Direction = builder.LogContextForward
+	logContext.Columns = nil
+	forward := "SELECT `ts`, \n`host`, \n`message` \n" +
+		"FROM `logs` \n" +
+		"WHERE `ts` > CAST(1704067200123456 AS Timestamp) \n" +
+		"ORDER BY `ts` \n" +
+		"LIMIT 10"
+	assert.Equal(t, forward, builder.CompileLogContext(options, logContext))
+
+	options.LogContextField = "id"
+	logContext.TieBreaker = []byte(`null`)
+	assert.Equal(t, forward, builder.CompileLogContext(options, logContext))
+}
+
+func TestCompileTraces(t *testing.T) {
+	options := builder.TraceOptions{
+		Table:   "spans",
+		TraceID: "abc",
+		Columns: builder.TraceColumns{
+			TraceID:       "trace_id",
+			SpanID:        "span_id",
+			ParentSpanID:  "parent_id",
+			ServiceName:   "service",
+			OperationName: "operation",
+			StartTime:     "start",
+			Duration:      "duration_us",
+			Tags:          "tags",
+		},
+		DurationUnit: "us",
+	}
+	assert.Equal(t, "SELECT `trace_id` AS `traceID`, \n`span_id` AS `spanID`, \n`parent_id` AS `parentSpanID`, \n"+
+		"`service` AS `serviceName`, \n`operation` AS `operationName`, \n`start` AS `startTime`, \n"+
+		"`duration_us` AS `duration`, \n`tags` \n"+
+		"FROM `spans` \n"+
+		"WHERE `trace_id` = \"abc\" \n"+
+		"ORDER BY `start` \n"+
+		"LIMIT 10000",
+		builder.CompileTraces(options))
+
+	options.Mode = builder.TraceModeSearch
+	options.ServiceName = "api"
+	options.MinDuration = "1.5ms"
+	options.MaxDuration = "2500ns"
+	assert.Equal(t, "SELECT `trace_id` AS `traceID`, \nMIN(`start`) AS `startTime`, \n"+
+		"MIN_BY(`service`, `start`) AS `serviceName`, \nMIN_BY(`operation`, `start`) AS `operationName`, \n"+
+		"MIN_BY(`duration_us`, `start`) AS `duration`, \nCOUNT(*) AS `spans` \n"+
+		"FROM `spans` \n"+
+		"WHERE `start` BETWEEN $__fromTimestamp AND $__toTimestamp \n"+
+		"GROUP BY `trace_id` \n"+
+		"HAVING MIN_BY(`service`, `start`) = \"api\" AND MIN_BY(`duration_us`, `start`) >= 1500 AND MIN_BY(`duration_us`, `start`) <= 2.5 \n"+
+		"ORDER BY `startTime` DESC \n"+
+		"LIMIT 20",
+		builder.CompileTraces(options))
+}
+
+func TestCompileStream(t *testing.T) {
+	query := "SELECT * FROM `events` WHERE $__timeFilter(`ts`);"
+	options := builder.StreamOptions{Column: "ts", Limit: 100}
+	assert.Equal(t, "SELECT * \nFROM (SELECT * FROM `events` WHERE $__timeFilter(`ts`)\n) \n"+
+		"ORDER BY `ts` DESC \n"+
+		"LIMIT 100",
+		builder.CompileStream(query, options))
+
+	options.After, options.AfterTime = json.RawMessage(`1704067200000000`), true
+	assert.Equal(t, "SELECT * \nFROM (SELECT * FROM `events` WHERE $__timeFilter(`ts`)\n) \n"+
+		"WHERE `ts` > CAST(1704067200000000 AS Timestamp) \n"+
+		"ORDER BY `ts` \n"+
+		"LIMIT 100",
+		builder.CompileStream(query, options))
+
+	options = builder.StreamOptions{Column: "seq", After: json.RawMessage(`"0042"`)}
+	assert.Equal(t, "SELECT * \nFROM (SELECT 1\n) \n"+
+		"WHERE `seq` > \"0042\" \n"+
+		"ORDER BY `seq` \n"+
+		"LIMIT 1000",
+		builder.CompileStream("SELECT 1", options))
+
+	// the closing parenthesis isn't commented out by a comment ending the query
+	assert.Equal(t, "SELECT * \nFROM (SELECT * FROM `events` -- tail\n) \n"+
+		"WHERE `seq` > \"0042\" \n"+
+		"ORDER BY `seq` \n"+
+		"LIMIT 1000",
+		builder.CompileStream("SELECT * FROM `events`; -- tail\n", options))
+}
+
+func TestCompileSystem(t *testing.T) {
+	assert.Equal(t, "SELECT `NodeId`, \n`Host`, \n`Address`, \n`Port`, \n`StartTime`, \n`UpTime`, \n`CpuThreads`, \n`CpuUsage`, \n`CpuIdle` \n"+
+		"FROM `.sys/nodes` \n"+
+		"ORDER BY `NodeId` \n"+
+		"LIMIT 100",
+		builder.CompileSystem(builder.SystemOptions{View: "nodes"}))
+
+	query := builder.CompileSystem(builder.SystemOptions{View: "top_queries_by_cpu_time", Window: builder.SystemWindowHour, Limit: 10})
+	assert.Contains(t, query, "FROM `.sys/top_queries_by_cpu_time_one_hour` \n"+
+		"WHERE $__timeFilter(`IntervalEnd`) \n"+
+		"ORDER BY `IntervalEnd` DESC, `Rank` \n"+
+		"LIMIT 10")
+	assert.Contains(t, builder.CompileSystem(builder.SystemOptions{View: "query_metrics"}), "FROM `.sys/query_metrics_one_minute`")
+}
+
+func TestCompileRowLimit(t *testing.T) {
+	assert.Equal(t, "SELECT * FROM `logs` WHERE msg = \"a;b\" -- ;\nLIMIT 101",
+		builder.CompileRowLimit("SELECT * FROM `logs` WHERE msg = \"a;b\" -- ;\n;", 101))
+	// ORDER BY of subqueries isn't kept by outer queries, so LIMIT follows the ORDER BY of the statement
+	assert.Equal(t, "SELECT * FROM (SELECT * FROM `logs` LIMIT 5) ORDER BY `ts` DESC\nLIMIT 101",
+		builder.CompileRowLimit("SELECT * FROM (SELECT * FROM `logs` LIMIT 5) ORDER BY `ts` DESC", 101))
+	for _, query := range []string{
+		"$t = SELECT 1; SELECT * FROM $t",
+		"SELECT 1; SELECT 2",
+		"UPSERT INTO `t` (a) VALUES (1)",
+		"SELECT * FROM `logs` ORDER BY `ts` DESC LIMIT 10",
+		"SELECT 1 UNION ALL SELECT 2",
+	} {
+		assert.Equal(t, query, builder.CompileRowLimit(query, 101))
+	}
+}
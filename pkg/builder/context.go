@@ -0,0 +1,101 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Directions of the log context
+const (
+	LogContextBackward = "backward"
+	LogContextForward  = "forward"
+)
+
+// LogContext is the log row around which rows of the log context are read
+type LogContext struct {
+	TimeNs int64 `json:"timeNs,string"`
+	// Columns are values of log context columns of the row, JSON strings, numbers or null,
+	// columns missing from the row are not filtered
+	Columns map[string]json.RawMessage `json:"columns,omitempty"`
+	// TieBreaker is the value of the log context field of the row
+	TieBreaker json.RawMessage `json:"tieBreaker,omitempty"`
+	Direction  string          `json:"direction,omitempty"`
+	Limit      int             `json:"limit,omitempty"`
+}
+
+// CompileLogContext returns YQL reading rows of the logs query before or after the row, ordered by the log time
+// and the log context field like the primary key of log tables, where log context columns are equal to ones of the row
+func CompileLogContext(options Options, logContext LogContext) string {
+	order := []string{""}
+	if options.LogTimeField != nil {
+		order[0] = options.LogTimeField.field().expression()
+	}
+	row := []string{fmt.Sprintf("CAST(%d AS Timestamp)", logContext.TimeNs/1000)}
+	// a row without the value of the log context field is compared by the log time only
+	if options.LogContextField != "" && literal(logContext.TieBreaker) != "NULL" {
+		order = append(order, QuoteIdentifier(options.LogContextField))
+		row = append(row, literal(logContext.TieBreaker))
+	}
+
+	var conditions []string
+	columns := make([]string, 0, len(logContext.Columns))
+	for column := range logContext.Columns {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		value := literal(logContext.Columns[column])
+		if value == "NULL" {
+			conditions = append(conditions, QuoteIdentifier(column)+" IS NULL")
+			continue
+		}
+		conditions = append(conditions, QuoteIdentifier(column)+" = "+value)
+	}
+
+	operator, direction := "<", " DESC"
+	if logContext.Direction == LogContextForward {
+		operator, direction = ">", ""
+	}
+	if len(order) == 1 {
+		conditions = append(conditions, order[0]+" "+operator+" "+row[0])
+	} else {
+		conditions = append(conditions, "("+strings.Join(order, ", ")+") "+operator+" ("+strings.Join(row, ", ")+")")
+	}
+	for i := range order {
+		order[i] += direction
+	}
+
+	fields := selectFields(options, FormatLogs)
+	if len(fields) == 0 {
+		fields = []string{"*"}
+	}
+	var b strings.Builder
+	b.WriteString("SELECT " + strings.Join(fields, ", \n"))
+	b.WriteString(" \nFROM " + QuoteIdentifier(options.Table))
+	b.WriteString(" \nWHERE " + strings.Join(conditions, " AND "))
+	b.WriteString(" \nORDER BY " + strings.Join(order, ", "))
+	b.WriteString(fmt.Sprintf(" \nLIMIT %d", logContext.Limit))
+	return b.String()
+}
+
+// literal returns YQL of the JSON value, strings are quoted and numbers are kept as is
+func literal(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "NULL"
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return QuoteString(s)
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String()
+	}
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return fmt.Sprint(b)
+	}
+	return "NULL"
+}
@@ -0,0 +1,110 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompileRowLimit appends LIMIT to a single SELECT statement reading at most limit rows. Statements with their own
+// top level LIMIT or with set operators and other queries are returned unchanged. The statement isn't wrapped
+// into a subquery, since the outer query doesn't keep the ORDER BY of subqueries
+func CompileRowLimit(query string, limit int) string {
+	statement := trimStatement(query)
+	code := codeOf(statement)
+	if !strings.EqualFold(firstWord(statement), "SELECT") || strings.Contains(code, ";") {
+		return query
+	}
+	for _, word := range topLevelWords(code) {
+		switch word {
+		case "LIMIT", "UNION", "INTERSECT", "EXCEPT":
+			return query
+		}
+	}
+	// the statement can end with a comment
+	return statement + "\nLIMIT " + strconv.Itoa(limit)
+}
+
+// trimStatement trims spaces and separators ending the statement, also before a comment ending it
+func trimStatement(query string) string {
+	statement := strings.TrimSpace(query)
+	code := strings.TrimRight(codeOf(statement), " \t\r\n")
+	for strings.HasSuffix(code, ";") {
+		i := len(code) - 1
+		statement = strings.TrimSpace(statement[:i] + statement[i+1:])
+		code = strings.TrimRight(code[:i], " \t\r\n")
+	}
+	return statement
+}
+
+func firstWord(s string) string {
+	word, _, _ := strings.Cut(s, " ")
+	word, _, _ = strings.Cut(word, "\n")
+	return strings.TrimSpace(word)
+}
+
+// topLevelWords returns upper case words of the code outside of parentheses
+func topLevelWords(code string) (words []string) {
+	depth, start := 0, -1
+	for i := 0; i <= len(code); i++ {
+		var c byte
+		if i < len(code) {
+			c = code[i]
+		}
+		if depth == 0 && (c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			words = append(words, strings.ToUpper(code[start:i]))
+			start = -1
+		}
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+	}
+	return words
+}
+
+// codeOf replaces literals, quoted identifiers and comments of the query with spaces
+func codeOf(query string) string {
+	code := []byte(query)
+	blank := func(from, to int) {
+		for j := from; j < to && j < len(code); j++ {
+			code[j] = ' '
+		}
+	}
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			start := i
+			for i++; i < len(query) && query[i] != c; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+			}
+			blank(start, i+1)
+		case strings.HasPrefix(query[i:], "--"):
+			start := i
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(query)
+			}
+			blank(start, i)
+		case strings.HasPrefix(query[i:], "/*"):
+			start := i
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+			blank(start, i+1)
+		}
+	}
+	return string(code)
+}
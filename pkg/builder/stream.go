@@ -0,0 +1,52 @@
+package builder
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// DefaultStreamLimit limits rows read by a poll of streams without a limit
+const DefaultStreamLimit = 1000
+
+// StreamOptions streams rows appended to the query results, ordered by a monotonic column like a timestamp or a sequence
+type StreamOptions struct {
+	Column string `json:"column"`
+	// Interval is the poll interval, e.g. 5s
+	Interval string `json:"interval,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+
+	// Poll is set by the poller of the stream, other requests run the query as is
+	Poll bool `json:"poll,omitempty"`
+	// After is the value of the column in the last row read, a JSON number or string,
+	// or microseconds since the epoch when AfterTime is set
+	After     json.RawMessage `json:"after,omitempty"`
+	AfterTime bool            `json:"afterTime,omitempty"`
+}
+
+// CompileStream wraps the query into YQL reading rows after the last row read or, on the first poll,
+// the last rows of the query in the descending order of the column
+func CompileStream(query string, options StreamOptions) string {
+	query = trimStatement(query)
+	column := QuoteIdentifier(options.Column)
+	limit := options.Limit
+	if limit <= 0 {
+		limit = DefaultStreamLimit
+	}
+
+	var b strings.Builder
+	// the query can end with a comment
+	b.WriteString("SELECT * \nFROM (" + query + "\n)")
+	if len(options.After) == 0 {
+		b.WriteString(" \nORDER BY " + column + " DESC")
+	} else {
+		after := literal(options.After)
+		if options.AfterTime {
+			after = "CAST(" + after + " AS Timestamp)"
+		}
+		b.WriteString(" \nWHERE " + column + " > " + after)
+		b.WriteString(" \nORDER BY " + column)
+	}
+	b.WriteString(" \nLIMIT " + strconv.Itoa(limit))
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Windows of system views aggregated over intervals
+const (
+	SystemWindowMinute = "minute"
+	SystemWindowHour   = "hour"
+)
+
+// DefaultSystemLimit limits rows of system queries without a limit
+const DefaultSystemLimit = 100
+
+// SystemOptions is the system query reading a system view of the catalog
+type SystemOptions struct {
+	View string `json:"view"`
+	// Window selects the minute or the hour variant of views aggregated over intervals, minute by default
+	Window string `json:"window,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+// SystemView is a view of the .sys folder of the database. Views with windows are tables suffixed
+// by the window, rows of views with a time column are filtered by the dashboard time range
+type SystemView struct {
+	Name        string         `json:"name"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Windows     []string       `json:"windows,omitempty"`
+	TimeColumn  string         `json:"timeColumn,omitempty"`
+	Columns     []SystemColumn `json:"columns"`
+	// OrderBy is the YQL order of rows
+	OrderBy string `json:"-"`
+}
+
+// SystemColumn is a column of the system view with the Grafana unit of its values
+type SystemColumn struct {
+	Name string `json:"name"`
+	Unit string `json:"unit,omitempty"`
+}
+
+var systemWindows = []string{SystemWindowMinute, SystemWindowHour}
+
+// byIntervalRank orders rows of views of top rows by the last interval first and by the rank in intervals
+const byIntervalRank = "`IntervalEnd` DESC, `Rank`"
+
+// topQueriesColumns are columns of views of top queries, durations are converted to milliseconds
+var topQueriesColumns = []SystemColumn{
+	{Name: "IntervalEnd"}, {Name: "Rank"}, {Name: "QueryText"}, {Name: "Duration", Unit: "ms"}, {Name: "EndTime"},
+	{Name: "CPUTime", Unit: "µs"}, {Name: "ReadRows"}, {Name: "ReadBytes", Unit: "bytes"}, {Name: "UpdateRows"},
+	{Name: "UpdateBytes", Unit: "bytes"}, {Name: "DeleteRows"}, {Name: "Partitions"}, {Name: "UserSID"},
+}
+
+// SystemViews is the catalog of system views of system queries
+var SystemViews = []SystemView{
+	{
+		Name:        "top_queries_by_cpu_time",
+		Title:       "Top queries by CPU time",
+		Description: "Queries with the highest CPU time in each interval",
+		Windows:     systemWindows,
+		TimeColumn:  "IntervalEnd",
+		Columns:     topQueriesColumns,
+		OrderBy:     byIntervalRank,
+	},
+	{
+		Name:        "top_queries_by_duration",
+		Title:       "Top queries by duration",
+		Description: "Longest queries in each interval",
+		Windows:     systemWindows,
+		TimeColumn:  "IntervalEnd",
+		Columns:     topQueriesColumns,
+		OrderBy:     byIntervalRank,
+	},
+	{
+		Name:        "top_queries_by_read_bytes",
+		Title:       "Top queries by read bytes",
+		Description: "Queries reading the most data in each interval",
+		Windows:     systemWindows,
+		TimeColumn:  "IntervalEnd",
+		Columns:     topQueriesColumns,
+		OrderBy:     byIntervalRank,
+	},
+	{
+		Name:        "query_metrics",
+		Title:       "Query metrics",
+		Description: "Statistics of queries grouped by query text in each minute",
+		Windows:     []string{SystemWindowMinute},
+		TimeColumn:  "IntervalEnd",
+		Columns: []SystemColumn{
+			{Name: "IntervalEnd"}, {Name: "Rank"}, {Name: "QueryText"}, {Name: "Count"},
+			{Name: "SumCPUTime", Unit: "µs"}, {Name: "MaxCPUTime", Unit: "µs"},
+			{Name: "SumDuration", Unit: "ms"}, {Name: "MaxDuration", Unit: "ms"},
+			{Name: "SumReadRows"}, {Name: "SumReadBytes", Unit: "bytes"}, {Name: "SumUpdateRows"},
+			{Name: "SumUpdateBytes", Unit: "bytes"}, {Name: "SumDeleteRows"}, {Name: "SumRequestUnits"},
+		},
+		OrderBy: byIntervalRank,
+	},
+	{
+		Name:        "top_partitions",
+		Title:       "Top partitions by CPU",
+		Description: "Partitions of tables with the highest CPU usage in each interval",
+		Windows:     systemWindows,
+		TimeColumn:  "IntervalEnd",
+		Columns: []SystemColumn{
+			{Name: "IntervalEnd"}, {Name: "Rank"}, {Name: "TabletId"}, {Name: "Path"}, {Name: "PeakTime"},
+			{Name: "CPUCores", Unit: "percentunit"}, {Name: "NodeId"}, {Name: "DataSize", Unit: "bytes"},
+			{Name: "RowCount"}, {Name: "IndexSize", Unit: "bytes"}, {Name: "InFlightTxCount"},
+		},
+		OrderBy: byIntervalRank,
+	},
+	{
+		Name:        "partition_stats",
+		Title:       "Partition statistics",
+		Description: "Current size and load of partitions of tables",
+		Columns: []SystemColumn{
+			{Name: "Path"}, {Name: "PartIdx"}, {Name: "DataSize", Unit: "bytes"}, {Name: "RowCount"},
+			{Name: "IndexSize", Unit: "bytes"}, {Name: "CPUCores", Unit: "percentunit"}, {Name: "NodeId"},
+			{Name: "InFlightTxCount"}, {Name: "AccessTime"}, {Name: "UpdateTime"},
+		},
+		OrderBy: "`CPUCores` DESC",
+	},
+	{
+		Name:        "nodes",
+		Title:       "Nodes",
+		Description: "Nodes of the database with their CPU usage",
+		Columns: []SystemColumn{
+			{Name: "NodeId"}, {Name: "Host"}, {Name: "Address"}, {Name: "Port"}, {Name: "StartTime"},
+			{Name: "UpTime", Unit: "ms"}, {Name: "CpuThreads"}, {Name: "CpuUsage", Unit: "percentunit"},
+			{Name: "CpuIdle", Unit: "percentunit"},
+		},
+		OrderBy: "`NodeId`",
+	},
+}
+
+// LookupSystemView returns the view of the catalog
+func LookupSystemView(name string) (SystemView, bool) {
+	for _, view := range SystemViews {
+		if view.Name == name {
+			return view, true
+		}
+	}
+	return SystemView{}, false
+}
+
+// Table returns the path of the view of the window
+func (v SystemView) Table(window string) string {
+	if len(v.Windows) == 0 {
+		return ".sys/" + v.Name
+	}
+	if window == "" {
+		window = v.Windows[0]
+	}
+	return ".sys/" + v.Name + "_one_" + window
+}
+
+// CompileSystem returns YQL reading columns of the system view, rows of views with a time column
+// are filtered by the dashboard time range
+func CompileSystem(options SystemOptions) string {
+	view, _ := LookupSystemView(options.View)
+	fields := make([]string, len(view.Columns))
+	for i, column := range view.Columns {
+		fields[i] = QuoteIdentifier(column.Name)
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = DefaultSystemLimit
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + strings.Join(fields, ", \n"))
+	b.WriteString(" \nFROM " + QuoteIdentifier(view.Table(options.Window)))
+	if view.TimeColumn != "" {
+		b.WriteString(" \nWHERE $__timeFilter(" + QuoteIdentifier(view.TimeColumn) + ")")
+	}
+	b.WriteString(" \nORDER BY " + view.OrderBy)
+	b.WriteString(" \nLIMIT " + strconv.Itoa(limit))
+	return b.String()
+}
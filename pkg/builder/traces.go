@@ -0,0 +1,182 @@
+package builder
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Modes of traces queries
+const (
+	TraceModeTrace  = "trace"
+	TraceModeSearch = "search"
+)
+
+// Names of trace columns expected by the Grafana trace view
+const (
+	TraceIDColumn       = "traceID"
+	SpanIDColumn        = "spanID"
+	ParentSpanIDColumn  = "parentSpanID"
+	ServiceNameColumn   = "serviceName"
+	OperationNameColumn = "operationName"
+	StartTimeColumn     = "startTime"
+	DurationColumn      = "duration"
+	TagsColumn          = "tags"
+	SpansColumn         = "spans"
+)
+
+// DefaultTraceSearchLimit limits traces found by searches without a limit
+const DefaultTraceSearchLimit = 20
+
+// maxTraceSpans limits spans of a trace
+const maxTraceSpans = 10000
+
+// TraceOptions is the traces query reading spans of a trace or searching traces in a table of spans
+type TraceOptions struct {
+	Table   string       `json:"table,omitempty"`
+	Mode    string       `json:"mode,omitempty"`
+	TraceID string       `json:"traceId,omitempty"`
+	Columns TraceColumns `json:"columns"`
+	// DurationUnit is the unit of numeric durations of spans: ns, us, ms (default) or s
+	DurationUnit string `json:"durationUnit,omitempty"`
+
+	// Filters of trace searches
+	ServiceName   string `json:"serviceName,omitempty"`
+	OperationName string `json:"operationName,omitempty"`
+	MinDuration   string `json:"minDuration,omitempty"`
+	MaxDuration   string `json:"maxDuration,omitempty"`
+	Limit         int    `json:"limit,omitempty"`
+}
+
+// TraceColumns maps columns of the table of spans to trace columns, Tags is a Json column
+type TraceColumns struct {
+	TraceID       string `json:"traceId,omitempty"`
+	SpanID        string `json:"spanId,omitempty"`
+	ParentSpanID  string `json:"parentSpanId,omitempty"`
+	ServiceName   string `json:"serviceName,omitempty"`
+	OperationName string `json:"operationName,omitempty"`
+	StartTime     string `json:"startTime,omitempty"`
+	Duration      string `json:"duration,omitempty"`
+	Tags          string `json:"tags,omitempty"`
+}
+
+// durationUnits are lengths of units of span durations
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// DurationUnitLength returns the length of the unit of span durations, milliseconds by default
+func (o TraceOptions) DurationUnitLength() (time.Duration, bool) {
+	if o.DurationUnit == "" {
+		return time.Millisecond, true
+	}
+	unit, ok := durationUnits[o.DurationUnit]
+	return unit, ok
+}
+
+// CompileTraces returns YQL reading spans of the trace or, in the search mode, traces matching filters
+// within the dashboard time range
+func CompileTraces(options TraceOptions) string {
+	if options.Mode == TraceModeSearch {
+		return compileTraceSearch(options)
+	}
+	c := options.Columns
+	fields := []string{
+		alias(c.TraceID, TraceIDColumn),
+		alias(c.SpanID, SpanIDColumn),
+	}
+	for _, column := range []struct{ name, alias string }{
+		{c.ParentSpanID, ParentSpanIDColumn},
+		{c.ServiceName, ServiceNameColumn},
+		{c.OperationName, OperationNameColumn},
+		{c.StartTime, StartTimeColumn},
+		{c.Duration, DurationColumn},
+		{c.Tags, TagsColumn},
+	} {
+		if column.name != "" {
+			fields = append(fields, alias(column.name, column.alias))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + strings.Join(fields, ", \n"))
+	b.WriteString(" \nFROM " + QuoteIdentifier(options.Table))
+	b.WriteString(" \nWHERE " + QuoteIdentifier(c.TraceID) + " = " + QuoteString(options.TraceID))
+	if c.StartTime != "" {
+		b.WriteString(" \nORDER BY " + QuoteIdentifier(c.StartTime))
+	}
+	b.WriteString(" \nLIMIT " + strconv.Itoa(maxTraceSpans))
+	return b.String()
+}
+
+// compileTraceSearch groups spans by traces, the service, the operation and the duration of a trace
+// are ones of its first span. Filters apply to these values of traces, so they don't drop spans before grouping.
+func compileTraceSearch(options TraceOptions) string {
+	c := options.Columns
+	start := QuoteIdentifier(c.StartTime)
+	fields := []string{
+		alias(c.TraceID, TraceIDColumn),
+		"MIN(" + start + ") AS " + QuoteIdentifier(StartTimeColumn),
+	}
+	first := func(column string) string {
+		return "MIN_BY(" + QuoteIdentifier(column) + ", " + start + ")"
+	}
+	for _, column := range []struct{ name, alias string }{
+		{c.ServiceName, ServiceNameColumn},
+		{c.OperationName, OperationNameColumn},
+		{c.Duration, DurationColumn},
+	} {
+		if column.name != "" {
+			fields = append(fields, first(column.name)+" AS "+QuoteIdentifier(column.alias))
+		}
+	}
+	fields = append(fields, "COUNT(*) AS "+QuoteIdentifier(SpansColumn))
+
+	var having []string
+	if options.ServiceName != "" && c.ServiceName != "" {
+		having = append(having, first(c.ServiceName)+" = "+QuoteString(options.ServiceName))
+	}
+	if options.OperationName != "" && c.OperationName != "" {
+		having = append(having, first(c.OperationName)+" = "+QuoteString(options.OperationName))
+	}
+	if c.Duration != "" {
+		unit, _ := options.DurationUnitLength()
+		if d, err := time.ParseDuration(options.MinDuration); err == nil {
+			having = append(having, first(c.Duration)+" >= "+durationIn(d, unit))
+		}
+		if d, err := time.ParseDuration(options.MaxDuration); err == nil {
+			having = append(having, first(c.Duration)+" <= "+durationIn(d, unit))
+		}
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = DefaultTraceSearchLimit
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + strings.Join(fields, ", \n"))
+	b.WriteString(" \nFROM " + QuoteIdentifier(options.Table))
+	b.WriteString(" \nWHERE " + start + " BETWEEN $__fromTimestamp AND $__toTimestamp")
+	b.WriteString(" \nGROUP BY " + QuoteIdentifier(c.TraceID))
+	if len(having) > 0 {
+		b.WriteString(" \nHAVING " + strings.Join(having, " AND "))
+	}
+	b.WriteString(" \nORDER BY " + QuoteIdentifier(StartTimeColumn) + " DESC")
+	b.WriteString(" \nLIMIT " + strconv.Itoa(limit))
+	return b.String()
+}
+
+// durationIn returns the duration in units, fractions of units are kept
+func durationIn(d time.Duration, unit time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(unit), 'f', -1, 64)
+}
+
+func alias(column string, name string) string {
+	if column == name {
+		return QuoteIdentifier(column)
+	}
+	return QuoteIdentifier(column) + " AS " + QuoteIdentifier(name)
+}
@@ -0,0 +1,44 @@
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Aliases of columns of log volume queries
+const (
+	VolumeTimeAlias  = "time"
+	VolumeLevelAlias = "level"
+	VolumeCountAlias = "count"
+)
+
+// CompileLogsVolume returns YQL counting rows of the logs query per time bucket and level
+// within the dashboard time range, filters of the logs query are kept
+func CompileLogsVolume(options Options, bucket time.Duration) string {
+	timeExpression := ""
+	if options.LogTimeField != nil {
+		timeExpression = options.LogTimeField.field().expression()
+	}
+	bucketExpression := fmt.Sprintf("DateTime::MakeTimestamp(DateTime::StartOf(%s, DateTime::IntervalFromMilliseconds(%d)))",
+		timeExpression, max(bucket.Milliseconds(), 1))
+
+	fields := []string{QuoteIdentifier(VolumeTimeAlias)}
+	groupBy := []string{bucketExpression + " AS " + QuoteIdentifier(VolumeTimeAlias)}
+	if options.LogLevelField != "" {
+		fields = append(fields, QuoteIdentifier(VolumeLevelAlias))
+		groupBy = append(groupBy, QuoteIdentifier(options.LogLevelField)+" AS "+QuoteIdentifier(VolumeLevelAlias))
+	}
+	fields = append(fields, "COUNT(*) AS "+QuoteIdentifier(VolumeCountAlias))
+
+	var b strings.Builder
+	b.WriteString("SELECT " + strings.Join(fields, ", "))
+	b.WriteString(" \nFROM " + QuoteIdentifier(options.Table))
+	b.WriteString(" \nWHERE " + timeExpression + " BETWEEN $__fromTimestamp AND $__toTimestamp")
+	if conditions := filterConditions(options.Filters); len(conditions) > 0 {
+		b.WriteString(" AND (" + strings.Join(conditions, " \n") + ")")
+	}
+	b.WriteString(" \nGROUP BY " + strings.Join(groupBy, ", "))
+	b.WriteString(" \nORDER BY " + QuoteIdentifier(VolumeTimeAlias))
+	return b.String()
+}
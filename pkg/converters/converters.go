@@ -1,6 +1,7 @@
 package converters
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -158,6 +159,16 @@ var Converters = map[string]Converter{
 		fieldType:  data.FieldTypeNullableTime,
 		scanType:   reflect.PtrTo(reflect.PtrTo(reflect.TypeOf(time.Time{}))),
 	},
+	"List<Utf8>": {
+		fieldType: data.FieldTypeJSON,
+		scanType:  reflect.PtrTo(reflect.TypeOf([]string{})),
+		convert:   stringListConvert,
+	},
+	"Optional<List<Utf8>>": {
+		fieldType: data.FieldTypeNullableJSON,
+		scanType:  reflect.PtrTo(reflect.PtrTo(reflect.TypeOf([]string{}))),
+		convert:   stringListNullConvert,
+	},
 }
 
 var ComplexTypes = []string{"Map"}
@@ -262,4 +273,41 @@ func decimalNullConvert(in interface{}) (interface{}, error) {
 	}
 	f, _ := (*v).Float64()
 	return &f, nil
-}
\ No newline at end of file
+}
+
+func stringListConvert(in interface{}) (interface{}, error) {
+	if in == nil {
+		return json.RawMessage(`[]`), nil
+	}
+	v, ok := in.(*[]string)
+	if !ok {
+		return nil, fmt.Errorf("invalid list - %v", in)
+	}
+	if *v == nil {
+		return json.RawMessage(`[]`), nil
+	}
+	list, err := json.Marshal(*v)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(list), nil
+}
+
+func stringListNullConvert(in interface{}) (interface{}, error) {
+	if in == nil {
+		return (*json.RawMessage)(nil), nil
+	}
+	v, ok := in.(**[]string)
+	if !ok {
+		return nil, fmt.Errorf("invalid list - %v", in)
+	}
+	if *v == nil {
+		return (*json.RawMessage)(nil), nil
+	}
+	list, err := stringListConvert(*v)
+	if err != nil {
+		return nil, err
+	}
+	raw := list.(json.RawMessage)
+	return &raw, nil
+}
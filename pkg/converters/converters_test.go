@@ -1,6 +1,7 @@
 package converters_test
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -19,3 +20,17 @@ func TestDate(t *testing.T) {
 	actual := v.(time.Time)
 	assert.Equal(t, d, actual)
 }
+
+func TestStringList(t *testing.T) {
+	list := []string{"deploy", "db"}
+	sut := converters.GetConverter("List<Utf8>")
+	v, err := sut.FrameConverter.ConverterFunc(&list)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `["deploy","db"]`, string(v.(json.RawMessage)))
+
+	var missing *[]string
+	sut = converters.GetConverter("Optional<List<Utf8>>")
+	v, err = sut.FrameConverter.ConverterFunc(&missing)
+	assert.Nil(t, err)
+	assert.Nil(t, v.(*json.RawMessage))
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/sqlds/v2"
 
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
 	"github.com/ydb/grafana-ydb-datasource/pkg/plugin"
 )
 
@@ -23,46 +25,75 @@ func main() {
 }
 
 func newDatasource(ctx context.Context, settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
-	ds := sqlds.NewDatasource(&plugin.Ydb{})
+	ydbDriver := &plugin.Ydb{}
+	ds := sqlds.NewDatasource(ydbDriver)
+	schema := plugin.NewSchema(settings)
 	ds.CustomRoutes = map[string]func(http.ResponseWriter, *http.Request){
-		"/listTables": func(w http.ResponseWriter, r *http.Request) {
-			if err := func(w http.ResponseWriter) error {
-				tablesString, err := plugin.RetrieveListTablesForRoot(r.Context(), settings)
-				if err != nil {
-					return err
-				}
-				_, err = w.Write(tablesString)
-				if err != nil {
-					return err
-				}
-				return nil
-			}(w); err != nil {
-				w.WriteHeader(500)
-				jsonErr, _ := json.Marshal(err.Error())
-				w.Write(jsonErr)
-				log.DefaultLogger.Error(err.Error())
+		"/listTables": resourceHandler(func(ctx context.Context, r *http.Request) ([]byte, error) {
+			query, err := plugin.ParseTablesQuery(r.URL.Query())
+			if err != nil {
+				return nil, err
 			}
-		},
-		"/listFields": func(w http.ResponseWriter, r *http.Request) {
-			if err := func(w http.ResponseWriter) error {
-				query := r.URL.Query()
-				table := query.Get("table")
-				fieldsString, err := plugin.RetrieveTableFields(r.Context(), settings, table)
-				if err != nil {
-					return err
-				}
-				_, err = w.Write(fieldsString)
-				if err != nil {
-					return err
-				}
-				return nil
-			}(w); err != nil {
-				w.WriteHeader(500)
-				jsonErr, _ := json.Marshal(err.Error())
-				w.Write(jsonErr)
-				log.DefaultLogger.Error(err.Error())
+			return schema.RetrieveListTables(ctx, query)
+		}),
+		"/listEntries": resourceHandler(func(ctx context.Context, r *http.Request) ([]byte, error) {
+			query, err := plugin.ParseTablesQuery(r.URL.Query())
+			if err != nil {
+				return nil, err
 			}
-		},
+			return schema.RetrieveListEntries(ctx, query)
+		}),
+		"/listFields": resourceHandler(func(ctx context.Context, r *http.Request) ([]byte, error) {
+			return schema.RetrieveTableFields(ctx, r.URL.Query()["table"])
+		}),
+		"/describeTable": resourceHandler(func(ctx context.Context, r *http.Request) ([]byte, error) {
+			return schema.RetrieveTableDescription(ctx, r.URL.Query().Get("table"))
+		}),
+		"/completions": resourceHandler(func(ctx context.Context, r *http.Request) ([]byte, error) {
+			return schema.RetrieveCompletions(ctx, r.FormValue("query"), r.FormValue("cursor"))
+		}),
+		"/refreshSchema": resourceHandler(func(ctx context.Context, r *http.Request) ([]byte, error) {
+			return schema.RefreshSchema(ctx)
+		}),
+		"/systemViews": resourceHandler(func(context.Context, *http.Request) ([]byte, error) {
+			return json.Marshal(builder.SystemViews)
+		}),
+	}
+	if config, err := models.LoadSettings(settings); err != nil || config.AuthKind != "ForwardOAuthIdentity" {
+		if _, err := ds.NewDatasource(settings); err != nil {
+			schema.Close()
+			return nil, err
+		}
+		return plugin.NewDatasource(ds, ydbDriver, schema), nil
+	}
+	// every Grafana user gets an own connection with the user's OAuth identity
+	ds.EnableMultipleConnections = true
+	if _, err := ds.NewDatasource(settings); err != nil {
+		schema.Close()
+		return nil, err
+	}
+	return plugin.NewIdentityDatasource(ds, ydbDriver, schema), nil
+}
+
+// resourceHandler writes the response of the handler, the request context carries the user's OAuth identity
+func resourceHandler(handle func(ctx context.Context, r *http.Request) ([]byte, error)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := func(w http.ResponseWriter) error {
+			ctx := plugin.WithIdentityToken(r.Context(), r.Header.Get(backend.OAuthIdentityTokenHeaderName))
+			respData, err := handle(ctx, r)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(respData)
+			if err != nil {
+				return err
+			}
+			return nil
+		}(w); err != nil {
+			w.WriteHeader(plugin.HTTPStatus(err))
+			jsonErr, _ := json.Marshal(err.Error())
+			w.Write(jsonErr)
+			log.DefaultLogger.Error(err.Error())
+		}
 	}
-	return ds.NewDatasource(settings)
 }
@@ -10,4 +10,21 @@ var (
 	ErrUserOrPasswordEmpty                       = errors.New("username and password should not be empty")
 	ErrDBLocationEmpty                           = errors.New("data base location should not be empty")
 	ErrLoadingSettingsForServiceAccAuthAccessKey = errors.New("error loading settings for service account auth access key")
+	ErrTokenEndpointEmpty                        = errors.New("token endpoint should not be empty")
+	ErrTokenEndpointInvalid                      = errors.New("token endpoint should be a valid http(s) url")
+	ErrSubjectTokenEmpty                         = errors.New("subject token should not be empty")
+	ErrPrivateKeyEmpty                           = errors.New("private key should not be empty")
+	ErrPrivateKeyInvalid                         = errors.New("private key should be a PEM key for RSA and ECDSA or a base64 secret for HMAC signing methods")
+	ErrSigningMethodUnsupported                  = errors.New("unsupported jwt signing method")
+	ErrOAuthPassThruDisabled                     = errors.New("forward oauth identity should be enabled to use the grafana user's identity")
+	ErrAuthKindUnknown                           = errors.New("unknown authentication type")
+	ErrEndpointSchemeInvalid                     = errors.New("endpoint scheme should be grpc or grpcs")
+	ErrEndpointInvalid                           = errors.New("endpoint should be in format grpc(s)://host:port")
+	ErrDBLocationInvalid                         = errors.New("data base location should be an absolute path like /path/to/database")
+	ErrTimeoutInvalid                            = errors.New("timeout should be a duration like 1500ms or a whole number of seconds")
+	ErrTimeoutOutOfRange                         = errors.New("timeout is out of range")
+	ErrPoolLimitNegative                         = errors.New("should not be negative")
+	ErrDurationInvalid                           = errors.New("should be a non-negative duration like 30m or a whole number of seconds")
+	ErrOAuthIdentityTokenEmpty                   = errors.New("grafana user's oauth identity token should not be empty")
+	ErrOAuthIdentityExpired                      = errors.New("grafana user's oauth identity expired, re-run the query")
 )
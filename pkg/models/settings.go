@@ -1,30 +1,80 @@
 package models
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/ydb-platform/ydb-go-sdk/v3/credentials"
 )
 
 type AuthKind string
 
 const defaultAuthKind AuthKind = `ServiceAccountKey`
 
+const (
+	minTimeout = time.Second
+	maxTimeout = time.Hour
+
+	defaultTimeout        = "10"
+	defaultQueryTimeout   = "60"
+	defaultSchemaCacheTTL = "1m"
+)
+
+// dbLocationMatch matches absolute database paths like /ru-central1/b1g/etn
+var dbLocationMatch = regexp.MustCompile(`^(/[^/\s]+)+$`)
+
+const (
+	defaultSubjectTokenType = "urn:ietf:params:oauth:token-type:access_token"
+	defaultSigningMethod    = "RS256"
+)
+
 // Settings - data loaded from grafana settings database
 type Settings struct {
 	AuthKind           AuthKind              `json:"authKind"`
 	DBEndpoint         string                `json:"endpoint,omitempty"`
 	DBLocation         string                `json:"dbLocation,omitempty"`
 	User               string                `json:"user,omitempty"`
+	TokenEndpoint      string                `json:"tokenEndpoint,omitempty"`
+	Audience           string                `json:"audience,omitempty"`
+	Scope              string                `json:"scope,omitempty"`
+	SubjectTokenType   string                `json:"subjectTokenType,omitempty"`
+	KeyID              string                `json:"keyId,omitempty"`
+	Issuer             string                `json:"issuer,omitempty"`
+	Subject            string                `json:"subject,omitempty"`
+	SigningMethod      string                `json:"signingMethod,omitempty"`
+	OAuthPassThru      bool                  `json:"oauthPassThru,omitempty"`
 	Secrets            *SecretPluginSettings `json:"-"`
 	Dsn                string
 	IsSecureConnection bool
-	Timeout            string
-	TimeoutDuration    time.Duration
+	// Timeout is the legacy timeout in seconds, used when a specific timeout is not set
+	Timeout                 string
+	ConnectTimeout          string `json:"connectTimeout,omitempty"`
+	QueryTimeout            string `json:"queryTimeout,omitempty"`
+	MetadataTimeout         string `json:"metadataTimeout,omitempty"`
+	ConnectTimeoutDuration  time.Duration
+	QueryTimeoutDuration    time.Duration
+	MetadataTimeoutDuration time.Duration
+	// Pool settings, zero values keep defaults of database/sql and ydb-go-sdk
+	MaxOpenConns                 int    `json:"maxOpenConns,omitempty"`
+	MaxIdleConns                 int    `json:"maxIdleConns,omitempty"`
+	ConnMaxLifetime              string `json:"connMaxLifetime,omitempty"`
+	SessionPoolSizeLimit         int    `json:"sessionPoolSizeLimit,omitempty"`
+	SessionIdleThreshold         string `json:"sessionIdleThreshold,omitempty"`
+	ConnMaxLifetimeDuration      time.Duration
+	SessionIdleThresholdDuration time.Duration
+	// SchemaCacheTTL is how long listed tables and fields are served from cache, zero disables the cache
+	SchemaCacheTTL         string `json:"schemaCacheTTL,omitempty"`
+	SchemaCacheTTLDuration time.Duration
 }
 
 type SecretPluginSettings struct {
@@ -32,6 +82,8 @@ type SecretPluginSettings struct {
 	AccessToken             string
 	Password                string
 	Certificate             string
+	SubjectToken            string
+	PrivateKey              string
 }
 
 type SettingsOptionFunc func(settings *Settings)
@@ -41,13 +93,17 @@ func LoadSettings(source backend.DataSourceInstanceSettings) (*Settings, error)
 	if source.JSONData == nil || len(source.JSONData) < 1 {
 		// If no settings have been saved return default values
 		return &Settings{
-			AuthKind: defaultAuthKind,
-			Secrets:  loadSecretPluginSettings(source.DecryptedSecureJSONData),
+			AuthKind:                defaultAuthKind,
+			Secrets:                 loadSecretPluginSettings(source.DecryptedSecureJSONData),
+			ConnectTimeoutDuration:  10 * time.Second,
+			QueryTimeoutDuration:    60 * time.Second,
+			MetadataTimeoutDuration: 10 * time.Second,
 		}, nil
 	}
 	settings := Settings{
-		AuthKind: defaultAuthKind,
-		Timeout:  "10",
+		AuthKind:       defaultAuthKind,
+		Timeout:        defaultTimeout,
+		SchemaCacheTTL: defaultSchemaCacheTTL,
 	}
 	err := json.Unmarshal(source.JSONData, &settings)
 	if err != nil {
@@ -63,36 +119,214 @@ func loadSecretPluginSettings(source map[string]string) *SecretPluginSettings {
 		AccessToken:             source["accessToken"],
 		Password:                source["password"],
 		Certificate:             source["certificate"],
+		SubjectToken:            source["subjectToken"],
+		PrivateKey:              source["privateKey"],
 	}
 }
 
+// validateSettings checks all settings and returns every found problem joined into one error
 func validateSettings(settings Settings) (*Settings, error) {
-	if settings.DBEndpoint == "" {
-		return nil, fmt.Errorf("%w", ErrEndpointEmpty)
+	var errs []error
+	if err := validateEndpoint(settings.DBEndpoint); err != nil {
+		errs = append(errs, err)
 	}
-	if settings.DBLocation == "" {
-		return nil, fmt.Errorf("%w", ErrDBLocationEmpty)
+	if err := validateDBLocation(settings.DBLocation); err != nil {
+		errs = append(errs, err)
 	}
 	switch settings.AuthKind {
+	case "Anonymous", "MetaData":
 	case "ServiceAccountKey":
 		if settings.Secrets.ServiceAccAuthAccessKey == "" {
-			return nil, fmt.Errorf("%w", ErrServiceAccAuthAccessKeyEmpty)
+			errs = append(errs, fmt.Errorf("%w", ErrServiceAccAuthAccessKeyEmpty))
 		}
 	case "AccessToken":
 		if settings.Secrets.AccessToken == "" {
-			return nil, fmt.Errorf("%w", ErrAccessTokenEmpty)
+			errs = append(errs, fmt.Errorf("%w", ErrAccessTokenEmpty))
 		}
 	case "UserPassword":
 		if settings.Secrets.Password == "" || settings.User == "" {
-			return nil, fmt.Errorf("%w", ErrUserOrPasswordEmpty)
+			errs = append(errs, fmt.Errorf("%w", ErrUserOrPasswordEmpty))
+		}
+	case "OAuth2TokenExchange":
+		if err := validateTokenEndpoint(settings.TokenEndpoint); err != nil {
+			errs = append(errs, err)
+		}
+		if settings.Secrets.SubjectToken == "" {
+			errs = append(errs, fmt.Errorf("%w", ErrSubjectTokenEmpty))
+		}
+		if settings.SubjectTokenType == "" {
+			settings.SubjectTokenType = defaultSubjectTokenType
+		}
+	case "JWT":
+		if err := validateTokenEndpoint(settings.TokenEndpoint); err != nil {
+			errs = append(errs, err)
+		}
+		if settings.Secrets.PrivateKey == "" {
+			errs = append(errs, fmt.Errorf("%w", ErrPrivateKeyEmpty))
+		}
+		if settings.SigningMethod == "" {
+			settings.SigningMethod = defaultSigningMethod
+		}
+		if !slices.Contains(credentials.GetSupportedOauth2TokenExchangeJwtAlgorithms(), settings.SigningMethod) {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrSigningMethodUnsupported, settings.SigningMethod))
+		} else if settings.Secrets.PrivateKey != "" {
+			if _, err := ParsePrivateKey(settings.SigningMethod, settings.Secrets.PrivateKey); err != nil {
+				errs = append(errs, fmt.Errorf("%w: %s", ErrPrivateKeyInvalid, err.Error()))
+			}
+		}
+	case "ForwardOAuthIdentity":
+		if !settings.OAuthPassThru {
+			errs = append(errs, fmt.Errorf("%w", ErrOAuthPassThruDisabled))
 		}
+	default:
+		errs = append(errs, fmt.Errorf("%w: %q", ErrAuthKindUnknown, settings.AuthKind))
+	}
+	// datasources saved "0" when the legacy timeout wasn't set
+	if TimeoutUnset(settings.Timeout) {
+		settings.Timeout = defaultTimeout
+	}
+	if TimeoutUnset(settings.ConnectTimeout) {
+		settings.ConnectTimeout = settings.Timeout
+	}
+	if TimeoutUnset(settings.MetadataTimeout) {
+		settings.MetadataTimeout = settings.Timeout
+	}
+	if TimeoutUnset(settings.QueryTimeout) {
+		settings.QueryTimeout = defaultQueryTimeout
+	}
+	for _, timeout := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"connect", settings.ConnectTimeout, &settings.ConnectTimeoutDuration},
+		{"query", settings.QueryTimeout, &settings.QueryTimeoutDuration},
+		{"metadata", settings.MetadataTimeout, &settings.MetadataTimeoutDuration},
+	} {
+		d, err := ParseTimeout(timeout.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", timeout.name, err))
+			continue
+		}
+		*timeout.duration = d
+	}
+	errs = append(errs, validateLimits(&settings)...)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 	settings.Dsn = settings.DBEndpoint + settings.DBLocation
 	settings.IsSecureConnection = strings.HasPrefix(settings.DBEndpoint, "grpcs://")
-	t, err := strconv.Atoi(settings.Timeout)
+	return &settings, nil
+}
+
+// TimeoutUnset reports whether the timeout is empty or zero, which means the default timeout
+func TimeoutUnset(timeout string) bool {
+	d, err := parseDuration(timeout)
+	return timeout == "" || err == nil && d == 0
+}
+
+// ParseTimeout parses a duration like "1500ms" or "2m", a whole number is treated as seconds
+func ParseTimeout(timeout string) (time.Duration, error) {
+	d, err := parseDuration(timeout)
 	if err != nil {
-		return nil, fmt.Errorf("timeout %s invalid: %w", settings.Timeout, err)
+		return 0, fmt.Errorf("%w: %q", ErrTimeoutInvalid, timeout)
 	}
-	settings.TimeoutDuration = time.Duration(t) * time.Second
-	return &settings, nil
+	if d < minTimeout || d > maxTimeout {
+		return 0, fmt.Errorf("%w: %s not in [%s, %s]", ErrTimeoutOutOfRange, d, minTimeout, maxTimeout)
+	}
+	return d, nil
+}
+
+func parseDuration(duration string) (time.Duration, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		seconds, atoiErr := strconv.Atoi(duration)
+		if atoiErr != nil {
+			return 0, err
+		}
+		d = time.Duration(seconds) * time.Second
+	}
+	return d, nil
+}
+
+func validateLimits(settings *Settings) (errs []error) {
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"max open connections", settings.MaxOpenConns},
+		{"max idle connections", settings.MaxIdleConns},
+		{"session pool size limit", settings.SessionPoolSizeLimit},
+	} {
+		if limit.value < 0 {
+			errs = append(errs, fmt.Errorf("%s %w: %d", limit.name, ErrPoolLimitNegative, limit.value))
+		}
+	}
+	for _, duration := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"connection max lifetime", settings.ConnMaxLifetime, &settings.ConnMaxLifetimeDuration},
+		{"session idle threshold", settings.SessionIdleThreshold, &settings.SessionIdleThresholdDuration},
+		{"schema cache ttl", settings.SchemaCacheTTL, &settings.SchemaCacheTTLDuration},
+	} {
+		if duration.value == "" {
+			continue
+		}
+		d, err := parseDuration(duration.value)
+		if err != nil || d < 0 {
+			errs = append(errs, fmt.Errorf("%s %w: %q", duration.name, ErrDurationInvalid, duration.value))
+			continue
+		}
+		*duration.duration = d
+	}
+	return errs
+}
+
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("%w", ErrEndpointEmpty)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "grpc" && u.Scheme != "grpcs") {
+		return fmt.Errorf("%w: %s", ErrEndpointSchemeInvalid, endpoint)
+	}
+	if u.Host == "" || u.Path != "" || u.RawQuery != "" {
+		return fmt.Errorf("%w: %s", ErrEndpointInvalid, endpoint)
+	}
+	return nil
+}
+
+func validateDBLocation(location string) error {
+	if location == "" {
+		return fmt.Errorf("%w", ErrDBLocationEmpty)
+	}
+	if !dbLocationMatch.MatchString(location) {
+		return fmt.Errorf("%w: %s", ErrDBLocationInvalid, location)
+	}
+	return nil
+}
+
+// ParsePrivateKey parses the key according to the signing method family:
+// PEM for RSA and ECDSA, base64 secret for HMAC
+func ParsePrivateKey(signingMethod string, key string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(signingMethod, "ES"):
+		return jwt.ParseECPrivateKeyFromPEM([]byte(key))
+	case strings.HasPrefix(signingMethod, "HS"):
+		return base64.StdEncoding.DecodeString(key)
+	}
+	return jwt.ParseRSAPrivateKeyFromPEM([]byte(key))
+}
+
+func validateTokenEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return fmt.Errorf("%w", ErrTokenEndpointEmpty)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%w: %s", ErrTokenEndpointInvalid, endpoint)
+	}
+	return nil
 }
@@ -0,0 +1,85 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+func TestLoadSettings(t *testing.T) {
+	settings, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData:                []byte(`{"authKind":"AccessToken","endpoint":"grpcs://localhost:2135","dbLocation":"/ru-central1/b1g/etn"}`),
+		DecryptedSecureJSONData: map[string]string{"accessToken": "token"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "grpcs://localhost:2135/ru-central1/b1g/etn", settings.Dsn)
+	assert.True(t, settings.IsSecureConnection)
+}
+
+func TestLoadSettingsUnknownAuthKind(t *testing.T) {
+	_, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonimous","endpoint":"grpc://localhost:2136","dbLocation":"/local"}`),
+	})
+	assert.ErrorIs(t, err, models.ErrAuthKindUnknown)
+}
+
+func TestLoadSettingsReportsAllErrors(t *testing.T) {
+	_, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"UserPassword","endpoint":"http://localhost:2136","dbLocation":"local/","Timeout":"500ms"}`),
+	})
+	assert.ErrorIs(t, err, models.ErrEndpointSchemeInvalid)
+	assert.ErrorIs(t, err, models.ErrDBLocationInvalid)
+	assert.ErrorIs(t, err, models.ErrUserOrPasswordEmpty)
+	assert.ErrorIs(t, err, models.ErrTimeoutOutOfRange)
+}
+
+func TestLoadSettingsInvalidEndpoint(t *testing.T) {
+	_, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136/local","dbLocation":"/local","Timeout":"ten"}`),
+	})
+	assert.ErrorIs(t, err, models.ErrEndpointInvalid)
+	assert.ErrorIs(t, err, models.ErrTimeoutInvalid)
+}
+
+func TestLoadSettingsTimeouts(t *testing.T) {
+	settings, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136","dbLocation":"/local","Timeout":"30","queryTimeout":"2m","metadataTimeout":"1500ms"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, settings.ConnectTimeoutDuration)
+	assert.Equal(t, 2*time.Minute, settings.QueryTimeoutDuration)
+	assert.Equal(t, 1500*time.Millisecond, settings.MetadataTimeoutDuration)
+	assert.Equal(t, time.Minute, settings.SchemaCacheTTLDuration)
+}
+
+func TestLoadSettingsLegacyZeroTimeout(t *testing.T) {
+	settings, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136","dbLocation":"/local","Timeout":"0","queryTimeout":"0"}`),
+	})
+	require.NoError(t, err, "datasources saved before timeouts were validated keep working")
+	assert.Equal(t, 10*time.Second, settings.ConnectTimeoutDuration)
+	assert.Equal(t, 60*time.Second, settings.QueryTimeoutDuration)
+	assert.Equal(t, 10*time.Second, settings.MetadataTimeoutDuration)
+}
+
+func TestLoadSettingsPool(t *testing.T) {
+	settings, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136","dbLocation":"/local","maxOpenConns":20,"connMaxLifetime":"30m","sessionPoolSizeLimit":10,"sessionIdleThreshold":"5s"}`),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 20, settings.MaxOpenConns)
+	assert.Equal(t, 30*time.Minute, settings.ConnMaxLifetimeDuration)
+	assert.Equal(t, 10, settings.SessionPoolSizeLimit)
+	assert.Equal(t, 5*time.Second, settings.SessionIdleThresholdDuration)
+
+	_, err = models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136","dbLocation":"/local","maxIdleConns":-1,"sessionIdleThreshold":"often"}`),
+	})
+	assert.ErrorIs(t, err, models.ErrPoolLimitNegative)
+	assert.ErrorIs(t, err, models.ErrDurationInvalid)
+}
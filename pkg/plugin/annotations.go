@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Names of fields of annotation frames of Grafana
+const (
+	annotationTime    = "time"
+	annotationTimeEnd = "timeEnd"
+	annotationText    = "text"
+	annotationTitle   = "title"
+	annotationTags    = "tags"
+)
+
+// annotationFrame converts rows of the annotation query to annotations. Rows without a time are skipped,
+// annotations without a text column are described by name=value pairs of other columns
+func annotationFrame(frame *data.Frame) (*data.Frame, error) {
+	timeTypes := []data.FieldType{data.FieldTypeTime, data.FieldTypeNullableTime}
+	timeIndex := logFieldIndex(frame, []string{annotationTime}, timeTypes...)
+	if timeIndex < 0 {
+		timeIndex = logFieldIndex(frame, nil, timeTypes...)
+	}
+	if timeIndex < 0 {
+		return nil, fmt.Errorf("%w: annotations need a %s column", ErrInvalidParameter, annotationTime)
+	}
+	index := func(name string, types ...data.FieldType) int {
+		i := logFieldIndex(frame, []string{name}, types...)
+		if i == timeIndex {
+			return -1
+		}
+		return i
+	}
+	timeEndIndex := index(annotationTimeEnd, timeTypes...)
+	textIndex, titleIndex, tagsIndex := index(annotationText), index(annotationTitle), index(annotationTags)
+	var rest []int
+	for i := range frame.Fields {
+		if !slices.Contains([]int{timeIndex, timeEndIndex, textIndex, titleIndex, tagsIndex}, i) {
+			rest = append(rest, i)
+		}
+	}
+
+	times := data.NewField(annotationTime, nil, []time.Time{})
+	texts := data.NewField(annotationText, nil, []string{})
+	annotations := data.NewFrame(frame.Name, times, texts)
+	var timeEnds, titles, tags *data.Field
+	if timeEndIndex >= 0 {
+		timeEnds = data.NewField(annotationTimeEnd, nil, []*time.Time{})
+		annotations.Fields = append(annotations.Fields, timeEnds)
+	}
+	if titleIndex >= 0 {
+		titles = data.NewField(annotationTitle, nil, []string{})
+		annotations.Fields = append(annotations.Fields, titles)
+	}
+	if tagsIndex >= 0 {
+		tags = data.NewField(annotationTags, nil, []json.RawMessage{})
+		annotations.Fields = append(annotations.Fields, tags)
+	}
+
+	for row := 0; row < frame.Rows(); row++ {
+		value, ok := frame.Fields[timeIndex].ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		times.Append(value.(time.Time))
+		texts.Append(logBody(frame, row, textIndex, rest))
+		if timeEnds != nil {
+			var end *time.Time
+			if value, ok := frame.Fields[timeEndIndex].ConcreteAt(row); ok {
+				t := value.(time.Time)
+				end = &t
+			}
+			timeEnds.Append(end)
+		}
+		if titles != nil {
+			titles.Append(fieldString(frame.Fields[titleIndex], row))
+		}
+		if tags != nil {
+			list, err := json.Marshal(annotationTagList(frame.Fields[tagsIndex], row))
+			if err != nil {
+				return nil, err
+			}
+			tags.Append(json.RawMessage(list))
+		}
+	}
+	annotations.RefID = frame.RefID
+	annotations.Meta = frame.Meta
+	return annotations, nil
+}
+
+// annotationTagList reads tags from a Json list, e.g. of List<Utf8> columns, or from comma separated values
+func annotationTagList(field *data.Field, row int) []string {
+	value, ok := field.ConcreteAt(row)
+	if !ok {
+		return []string{}
+	}
+	var values []string
+	switch v := value.(type) {
+	case json.RawMessage:
+		if err := json.Unmarshal(v, &values); err != nil {
+			values = strings.Split(strings.Trim(string(v), "[]"), ",")
+		}
+	default:
+		s := fmt.Sprint(v)
+		if err := json.Unmarshal([]byte(s), &values); err != nil {
+			values = strings.Split(s, ",")
+		}
+	}
+	tags := make([]string, 0, len(values))
+	for _, tag := range values {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
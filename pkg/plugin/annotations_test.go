@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationFrame(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	frame := data.NewFrame("A",
+		data.NewField("Time", nil, []*time.Time{&t0, nil, &t1}),
+		data.NewField("timeEnd", nil, []*time.Time{&t1, &t1, nil}),
+		data.NewField("title", nil, []string{"deploy", "skipped", "rollback"}),
+		data.NewField("text", nil, []string{"v2", "", "v1"}),
+		data.NewField("tags", nil, []string{" deploy, prod ,", "", `["rollback"]`}),
+		data.NewField("host", nil, []string{"a", "b", "c"}),
+	)
+
+	annotations, err := annotationFrame(frame)
+	require.NoError(t, err)
+	require.Equal(t, 2, annotations.Rows())
+	assert.Equal(t, []string{"time", "text", "timeEnd", "title", "tags"}, fieldNames(annotations))
+	assert.Equal(t, []any{t0, t1}, concreteValues(annotations.Fields[0]))
+	assert.Equal(t, []any{"v2", "v1"}, concreteValues(annotations.Fields[1]))
+	assert.Equal(t, []any{t1}, concreteValues(annotations.Fields[2])[:1])
+	assert.Nil(t, annotations.Fields[2].At(1))
+	assert.Equal(t, []any{json.RawMessage(`["deploy","prod"]`), json.RawMessage(`["rollback"]`)},
+		concreteValues(annotations.Fields[4]))
+}
+
+func TestAnnotationFrameWithoutText(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	frame := data.NewFrame("A",
+		data.NewField("created", nil, []time.Time{t0}),
+		data.NewField("tags", nil, []*json.RawMessage{nil}),
+		data.NewField("code", nil, []int64{500}),
+	)
+
+	annotations, err := annotationFrame(frame)
+	require.NoError(t, err)
+	assert.Equal(t, "code=500", annotations.Fields[1].At(0))
+	assert.Equal(t, json.RawMessage(`[]`), annotations.Fields[2].At(0))
+
+	_, err = annotationFrame(data.NewFrame("A", data.NewField("text", nil, []string{"a"})))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
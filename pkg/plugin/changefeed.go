@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Names of fields of changefeed records, values of columns are in fields prefixed by key., old. and new.
+const (
+	changefeedOperationField = "operation"
+	changefeedKeyPrefix      = "key."
+	changefeedOldPrefix      = "old."
+	changefeedNewPrefix      = "new."
+)
+
+// Operations of changefeed records
+const (
+	changefeedUpdate = "update"
+	changefeedErase  = "erase"
+)
+
+// changefeedColumns are columns of records of the changefeed, images of rows are recorded depending on the mode
+type changefeedColumns struct {
+	Keys    []string
+	Columns []string
+	Old     bool
+	New     bool
+}
+
+type describeChangefeedFunc func(ctx context.Context) (changefeedColumns, error)
+
+// describeChangefeed reads columns of the table of the changefeed with the driver opening readers of the changefeed
+func (ds *Datasource) describeChangefeed(options topicOptions, connectionArgs json.RawMessage) describeChangefeedFunc {
+	return func(ctx context.Context) (changefeedColumns, error) {
+		tablePath, err := ds.schema.tablePath(options.Table)
+		if err != nil {
+			return changefeedColumns{}, err
+		}
+		driver, closeDriver, err := ds.topicDriver(ctx, connectionArgs)
+		if err != nil {
+			return changefeedColumns{}, err
+		}
+		defer closeDriver()
+		ctx, cancel := context.WithTimeout(ctx, ds.schema.settings.MetadataTimeoutDuration)
+		defer cancel()
+		description, err := describeTable(ctx, driver, tablePath)
+		if err != nil {
+			return changefeedColumns{}, err
+		}
+		return newChangefeedColumns(description, options.Changefeed)
+	}
+}
+
+// newChangefeedColumns returns columns of records of the changefeed of the table, only the JSON format is decoded
+func newChangefeedColumns(description TableDescription, name string) (columns changefeedColumns, _ error) {
+	i := slices.IndexFunc(description.Changefeeds, func(c ChangefeedDescription) bool { return c.Name == name })
+	if i < 0 {
+		return columns, fmt.Errorf("%w: table %s has no changefeed %q", ErrInvalidParameter, description.Path, name)
+	}
+	changefeed := description.Changefeeds[i]
+	if changefeed.Format != "json" {
+		return columns, fmt.Errorf("%w: changefeed %q has the %s format, only json records are decoded", ErrInvalidParameter, name, changefeed.Format)
+	}
+	columns.Keys = description.PrimaryKey
+	for _, column := range description.Columns {
+		if !slices.Contains(columns.Keys, column.Name) {
+			columns.Columns = append(columns.Columns, column.Name)
+		}
+	}
+	switch changefeed.Mode {
+	case "updates", "newImage":
+		columns.New = true
+	case "oldImage":
+		columns.Old = true
+	case "newAndOldImages":
+		columns.Old, columns.New = true, true
+	}
+	return columns, nil
+}
+
+// changefeedRecord is a record of a changefeed in the JSON format, updates of the updates mode
+// hold only changed columns
+type changefeedRecord struct {
+	Key      []any          `json:"key"`
+	Update   map[string]any `json:"update"`
+	Erase    map[string]any `json:"erase"`
+	OldImage map[string]any `json:"oldImage"`
+	NewImage map[string]any `json:"newImage"`
+}
+
+// changefeedFields appends the operation and values of keys and images of records to the frame
+func (p *topicParser) changefeedFields(frame *data.Frame, messages []topicMessage) {
+	columns := p.changefeed
+	records := make([]changefeedRecord, len(messages))
+	operations := make([]*string, len(messages))
+	for i, m := range messages {
+		if err := decodeJSON(m.Data, &records[i]); err != nil {
+			continue
+		}
+		operation := changefeedUpdate
+		if records[i].Erase != nil {
+			operation = changefeedErase
+		}
+		operations[i] = &operation
+	}
+	frame.Fields = append(frame.Fields, data.NewField(changefeedOperationField, nil, operations))
+
+	for k, key := range columns.Keys {
+		frame.Fields = append(frame.Fields, p.field(changefeedKeyPrefix+key, changefeedValues(records, func(r changefeedRecord) any {
+			if k < len(r.Key) {
+				return r.Key[k]
+			}
+			return nil
+		})))
+	}
+	if columns.Old {
+		for _, column := range columns.Columns {
+			frame.Fields = append(frame.Fields, p.field(changefeedOldPrefix+column, changefeedValues(records, func(r changefeedRecord) any {
+				return r.OldImage[column]
+			})))
+		}
+	}
+	if columns.New {
+		for _, column := range columns.Columns {
+			frame.Fields = append(frame.Fields, p.field(changefeedNewPrefix+column, changefeedValues(records, func(r changefeedRecord) any {
+				if r.NewImage != nil {
+					return r.NewImage[column]
+				}
+				return r.Update[column]
+			})))
+		}
+	}
+}
+
+func changefeedValues(records []changefeedRecord, value func(changefeedRecord) any) []any {
+	values := make([]any, len(records))
+	for i, r := range records {
+		values[i] = value(r)
+	}
+	return values
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ordersDescription = TableDescription{
+	Path:       "/local/orders",
+	Columns:    []ColumnDescription{{Name: "id", Type: "Uint64"}, {Name: "status", Type: "Utf8"}, {Name: "total", Type: "Double"}},
+	PrimaryKey: []string{"id"},
+	Changefeeds: []ChangefeedDescription{
+		{Name: "updates", Mode: "newAndOldImages", Format: "json"},
+		{Name: "debezium", Mode: "newAndOldImages", Format: "debeziumJson"},
+	},
+}
+
+func TestParseQueryModelChangefeed(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"version":1,"queryType":"topic","topic":{"table":"orders","changefeed":"updates"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, TopicParserCDC, query.Topic.Parser)
+}
+
+func TestNewChangefeedColumns(t *testing.T) {
+	columns, err := newChangefeedColumns(ordersDescription, "updates")
+	require.NoError(t, err)
+	assert.Equal(t, changefeedColumns{Keys: []string{"id"}, Columns: []string{"status", "total"}, Old: true, New: true}, columns)
+
+	_, err = newChangefeedColumns(ordersDescription, "debezium")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = newChangefeedColumns(ordersDescription, "missing")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestChangefeedTopicPath(t *testing.T) {
+	ds := &Datasource{schema: NewSchema(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136","dbLocation":"/local"}`),
+	})}
+	defer ds.schema.Close()
+	topicPath, err := ds.topicPath(topicOptions{Table: "orders", Changefeed: "updates"})
+	require.NoError(t, err)
+	assert.Equal(t, "/local/orders/updates", topicPath)
+	topicPath, err = ds.topicPath(topicOptions{Path: "events"})
+	require.NoError(t, err)
+	assert.Equal(t, "events", topicPath)
+}
+
+func TestChangefeedStream(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	topic := &fakeTopic{batches: [][]topicMessage{{
+		{Offset: 1, WrittenAt: t0, Data: []byte(`{"key":[18446744073709551615],"update":{},"newImage":{"status":"paid","total":10.5},"oldImage":{"status":"new","total":10.5}}`)},
+		{Offset: 2, WrittenAt: t0, Data: []byte(`{"key":[2],"erase":{},"oldImage":{"status":"new","total":3}}`)},
+	}}}
+	options := topicOptions{Table: "orders", Changefeed: "updates", Parser: TopicParserCDC}
+	stream := newTopicStream(topic.open, options, QueryFormatTable)
+	assert.Equal(t, "orders/updates", stream.path, "changefeed streams are logged by their table and changefeed")
+	stream.describe = func(context.Context) (changefeedColumns, error) {
+		return newChangefeedColumns(ordersDescription, "updates")
+	}
+	subscriber := &packets{}
+	stream.subscribe(backend.NewStreamSender(subscriber))
+
+	require.NoError(t, stream.describeChangefeed(context.Background()))
+	reader, err := topic.open(context.Background(), t0)
+	require.NoError(t, err)
+	assert.ErrorIs(t, stream.read(context.Background(), reader), errTopicClosed)
+
+	require.Len(t, subscriber.data, 1)
+	frame := subscriber.frame(t, 0)
+	assert.Equal(t, []string{"time", "partition", "offset", "producer", "message", "operation", "key.id", "old.status", "old.total", "new.status", "new.total"}, fieldNames(frame))
+	assert.Equal(t, []any{"update", "erase"}, concreteValues(frame.Fields[5]))
+	assert.Equal(t, []any{uint64(18446744073709551615), uint64(2)}, concreteValues(frame.Fields[6]), "Uint64 keys aren't rounded")
+	assert.Equal(t, []any{"new", "new"}, concreteValues(frame.Fields[7]))
+	assert.Equal(t, "paid", *frame.Fields[9].At(0).(*string))
+	assert.Nil(t, frame.Fields[9].At(1), "erased rows have no new image")
+}
@@ -0,0 +1,321 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const maxCompletions = 200
+
+const (
+	CompletionKindTable    = "table"
+	CompletionKindColumn   = "column"
+	CompletionKindFunction = "function"
+	CompletionKindModule   = "module"
+	CompletionKindMacro    = "macro"
+)
+
+// Completion is a suggestion of the YQL editor
+type Completion struct {
+	Label      string `json:"label"`
+	Kind       string `json:"kind"`
+	Detail     string `json:"detail,omitempty"`
+	InsertText string `json:"insertText"`
+}
+
+// completionKinds orders kinds of equally matching suggestions
+var completionKinds = map[string]int{
+	CompletionKindColumn:   0,
+	CompletionKindTable:    1,
+	CompletionKindMacro:    2,
+	CompletionKindFunction: 3,
+	CompletionKindModule:   4,
+}
+
+// macroSignatures describes macros of Ydb.Macros and the default macros of sqlds
+var macroSignatures = map[string]string{
+	"$__timeFilter":    "$__timeFilter(column) filters the column by the dashboard time range",
+	"$__fromTimestamp": "$__fromTimestamp is the start of the dashboard time range",
+	"$__toTimestamp":   "$__toTimestamp is the end of the dashboard time range",
+	"$__varFallback":   "$__varFallback(fallback, $variable) uses the fallback when the variable is empty",
+	"$__timeFrom":      "$__timeFrom is the start of the dashboard time range",
+	"$__timeTo":        "$__timeTo is the end of the dashboard time range",
+	"$__timeGroup":     "$__timeGroup(column, interval) groups the column by the interval",
+	"$__table":         "$__table is the table of the query",
+	"$__column":        "$__column is the column of the query",
+}
+
+// builtinFunctions lists signatures of commonly used YQL built-in functions
+var builtinFunctions = map[string]string{
+	"COUNT":                   "COUNT(*|expr) -> Uint64",
+	"COUNT_IF":                "COUNT_IF(condition) -> Uint64",
+	"SUM":                     "SUM(expr)",
+	"SUM_IF":                  "SUM_IF(expr, condition)",
+	"AVG":                     "AVG(expr) -> Double",
+	"AVG_IF":                  "AVG_IF(expr, condition) -> Double",
+	"MIN":                     "MIN(expr)",
+	"MAX":                     "MAX(expr)",
+	"MIN_BY":                  "MIN_BY(value, key)",
+	"MAX_BY":                  "MAX_BY(value, key)",
+	"SOME":                    "SOME(expr)",
+	"MEDIAN":                  "MEDIAN(expr)",
+	"PERCENTILE":              "PERCENTILE(expr, percentile)",
+	"HISTOGRAM":               "HISTOGRAM(expr)",
+	"AGGREGATE_LIST":          "AGGREGATE_LIST(expr[, limit]) -> List",
+	"AGGREGATE_LIST_DISTINCT": "AGGREGATE_LIST_DISTINCT(expr[, limit]) -> List",
+	"TOP":                     "TOP(expr, count) -> List",
+	"BOTTOM":                  "BOTTOM(expr, count) -> List",
+	"COALESCE":                "COALESCE(expr, ...)",
+	"NVL":                     "NVL(expr, ...)",
+	"IF":                      "IF(condition, then[, else])",
+	"CAST":                    "CAST(expr AS Type)",
+	"LENGTH":                  "LENGTH(string) -> Uint32",
+	"SUBSTRING":               "SUBSTRING(string, position[, length])",
+	"FIND":                    "FIND(string, substring[, position]) -> Uint32?",
+	"RFIND":                   "RFIND(string, substring[, position]) -> Uint32?",
+	"StartsWith":              "StartsWith(string, prefix) -> Bool",
+	"EndsWith":                "EndsWith(string, suffix) -> Bool",
+	"Greatest":                "Greatest(expr, ...)",
+	"Least":                   "Least(expr, ...)",
+	"Unwrap":                  "Unwrap(optional[, message])",
+	"Just":                    "Just(expr) -> Optional",
+	"Nothing":                 "Nothing(Type)",
+	"Ensure":                  "Ensure(value, condition[, message])",
+	"Random":                  "Random(expr) -> Double",
+	"RandomNumber":            "RandomNumber(expr) -> Uint64",
+	"CurrentUtcDate":          "CurrentUtcDate() -> Date",
+	"CurrentUtcDatetime":      "CurrentUtcDatetime() -> Datetime",
+	"CurrentUtcTimestamp":     "CurrentUtcTimestamp() -> Timestamp",
+	"Date":                    "Date(string) -> Date",
+	"Datetime":                "Datetime(string) -> Datetime",
+	"Timestamp":               "Timestamp(string) -> Timestamp",
+	"Interval":                "Interval(string) -> Interval",
+	"AsList":                  "AsList(expr, ...) -> List",
+	"AsDict":                  "AsDict(AsTuple(key, value), ...) -> Dict",
+	"AsStruct":                "AsStruct(expr AS name, ...) -> Struct",
+	"AsTuple":                 "AsTuple(expr, ...) -> Tuple",
+	"ListLength":              "ListLength(list) -> Uint64",
+	"ListHas":                 "ListHas(list, value) -> Bool",
+	"DictKeys":                "DictKeys(dict) -> List",
+	"ToBytes":                 "ToBytes(expr) -> String",
+	"JSON_VALUE":              "JSON_VALUE(json, path)",
+	"JSON_EXISTS":             "JSON_EXISTS(json, path) -> Bool",
+	"JSON_QUERY":              "JSON_QUERY(json, path) -> Json",
+	"TableName":               "TableName() -> String",
+}
+
+// udfModules lists functions of YQL UDF modules called as Module::Function
+var udfModules = map[string][]string{
+	"DateTime": {"Split", "Format", "Parse", "ParseIso8601", "ParseRfc822", "ParseHttp", "ParseX509",
+		"MakeDate", "MakeDatetime", "MakeTimestamp", "StartOf", "StartOfDay", "StartOfWeek", "StartOfMonth",
+		"StartOfQuarter", "StartOfYear", "EndOfMonth", "ShiftMonths", "ShiftYears", "GetYear", "GetMonth",
+		"GetDayOfMonth", "GetDayOfWeek", "GetHour", "GetMinute", "GetSecond", "ToSeconds", "ToMilliseconds",
+		"ToMicroseconds", "FromSeconds", "FromMilliseconds", "FromMicroseconds", "IntervalFromDays",
+		"IntervalFromHours", "IntervalFromMinutes", "IntervalFromSeconds"},
+	"String": {"Contains", "HasPrefix", "HasSuffix", "ToLower", "ToUpper", "Strip", "ReplaceAll", "ReplaceFirst",
+		"SplitToList", "JoinFromList", "LevensteinDistance", "Base64Encode", "Base64Decode", "HexEncode",
+		"HexDecode", "EscapeC", "UnescapeC", "CollapseText", "LeftPad", "RightPad"},
+	"Unicode": {"Normalize", "IsUtf", "GetLength", "Find", "RFind", "Substring", "ToLower", "ToUpper", "Strip",
+		"Reverse", "SplitToList", "JoinFromList"},
+	"Url": {"Parse", "Normalize", "Encode", "Decode", "GetScheme", "GetHost", "GetPort", "GetPath", "GetTail",
+		"GetDomain", "GetCGIParam", "CutScheme", "CutWWW"},
+	"Ip":        {"FromString", "ToString", "IsIPv4", "IsIPv6", "ConvertToIPv6", "GetSubnet"},
+	"Math":      {"Abs", "Ceil", "Floor", "Round", "Sqrt", "Exp", "Log", "Log2", "Log10", "Pow", "Pi", "E", "IsNaN", "IsInf"},
+	"Re2":       {"Grep", "Match", "Capture", "FindAndSplit", "Replace", "Count", "Options"},
+	"Json":      {"Parse", "From", "Serialize", "GetField", "ConvertToString", "ConvertToInt64", "ConvertToDouble"},
+	"Yson":      {"Parse", "From", "Serialize", "SerializeJson", "ConvertTo", "LookupString", "LookupInt64"},
+	"Digest":    {"Crc32c", "Crc64", "Fnv32", "Fnv64", "MurMurHash", "CityHash", "Md5Hex", "Sha1", "Sha256", "IntHash64"},
+	"Hyperscan": {"Grep", "Match", "Capture", "Replace"},
+}
+
+var (
+	// completionPrefix matches the word being typed: a macro, a module function, a quoted path or an identifier
+	completionPrefix = regexp.MustCompile("(\\$\\w*|\\w+::\\w*|`[^`]*|[\\w/.-]*)$")
+	previousKeyword  = regexp.MustCompile(`(\w+)\s*$`)
+	referencedTable  = regexp.MustCompile("(?i)\\b(?:FROM|JOIN)\\s+(?:`([^`]+)`|([\\w/.-]+))")
+)
+
+// RetrieveCompletions returns suggestions for the query at the cursor, the cursor is a UTF-16 offset
+// as in the editor model, the end of the query by default
+func (s *Schema) RetrieveCompletions(ctx context.Context, query string, cursor string) (respData []byte, err error) {
+	defer func() {
+		if err != nil {
+			log.DefaultLogger.Error("Getting completions failed", "error", err.Error())
+		}
+	}()
+
+	text := utf16.Encode([]rune(query))
+	offset := len(text)
+	if cursor != "" {
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 || offset > len(text) {
+			return nil, fmt.Errorf("%w: cursor %q", ErrInvalidParameter, cursor)
+		}
+	}
+	completions, err := s.Completions(ctx, query, string(utf16.Decode(text[:offset])))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(completions)
+}
+
+// Completions returns ranked suggestions for the word before the cursor
+func (s *Schema) Completions(ctx context.Context, query string, beforeCursor string) ([]Completion, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	prefix := completionPrefix.FindString(beforeCursor)
+	keyword := previousKeyword.FindStringSubmatch(beforeCursor[:len(beforeCursor)-len(prefix)])
+
+	var candidates []Completion
+	switch {
+	case strings.HasPrefix(prefix, "$"):
+		candidates = macroCompletions()
+	case strings.Contains(prefix, "::"):
+		module, name, _ := strings.Cut(prefix, "::")
+		candidates = moduleFunctionCompletions(module)
+		prefix = name
+	case len(keyword) > 1 && (strings.EqualFold(keyword[1], "FROM") || strings.EqualFold(keyword[1], "JOIN")):
+		tables, err := s.tableCompletions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		candidates = tables
+		prefix = strings.TrimPrefix(strings.TrimPrefix(prefix, "`"), s.settings.DBLocation+"/")
+	default:
+		columns, err := s.columnCompletions(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(columns, macroCompletions()...)
+		candidates = append(candidates, functionCompletions()...)
+		candidates = append(candidates, moduleCompletions()...)
+	}
+	return rankCompletions(candidates, prefix), nil
+}
+
+// rankCompletions keeps suggestions containing the prefix, ones starting with the prefix go first
+func rankCompletions(candidates []Completion, prefix string) []Completion {
+	prefix = strings.ToLower(prefix)
+	type ranked struct {
+		Completion
+		rank int
+	}
+	var matches []ranked
+	for _, c := range candidates {
+		label := strings.ToLower(c.Label)
+		switch {
+		case strings.HasPrefix(label, prefix):
+			matches = append(matches, ranked{c, 0})
+		case strings.Contains(label, prefix):
+			matches = append(matches, ranked{c, 1})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		if matches[i].Kind != matches[j].Kind {
+			return completionKinds[matches[i].Kind] < completionKinds[matches[j].Kind]
+		}
+		return matches[i].Label < matches[j].Label
+	})
+	completions := make([]Completion, 0, min(len(matches), maxCompletions))
+	for _, m := range matches[:min(len(matches), maxCompletions)] {
+		completions = append(completions, m.Completion)
+	}
+	return completions
+}
+
+func (s *Schema) tableCompletions(ctx context.Context) ([]Completion, error) {
+	tables, err := s.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	completions := make([]Completion, 0, len(tables))
+	for _, table := range tables {
+		completions = append(completions, Completion{
+			Label:      strings.TrimPrefix(table, s.settings.DBLocation+"/"),
+			Kind:       CompletionKindTable,
+			Detail:     "Table",
+			InsertText: "`" + table + "`",
+		})
+	}
+	return completions, nil
+}
+
+// columnCompletions returns columns of tables referenced in FROM and JOIN clauses, missing tables are skipped
+func (s *Schema) columnCompletions(ctx context.Context, query string) ([]Completion, error) {
+	var completions []Completion
+	seen := map[string]bool{}
+	for _, match := range referencedTable.FindAllStringSubmatch(query, -1) {
+		table := match[1] + match[2]
+		p, err := s.tablePath(table)
+		if err != nil || seen[p] {
+			continue
+		}
+		seen[p] = true
+		fields, err := s.Fields(ctx, p)
+		if errors.Is(err, ErrTableNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			completions = append(completions, Completion{
+				Label:      field.Name,
+				Kind:       CompletionKindColumn,
+				Detail:     fmt.Sprintf("%s of %s", field.Type, table),
+				InsertText: field.Name,
+			})
+		}
+	}
+	return completions, nil
+}
+
+func macroCompletions() []Completion {
+	completions := make([]Completion, 0, len(macroSignatures))
+	for name, signature := range macroSignatures {
+		completions = append(completions, Completion{Label: name, Kind: CompletionKindMacro, Detail: signature, InsertText: name})
+	}
+	return completions
+}
+
+func functionCompletions() []Completion {
+	completions := make([]Completion, 0, len(builtinFunctions))
+	for name, signature := range builtinFunctions {
+		completions = append(completions, Completion{Label: name, Kind: CompletionKindFunction, Detail: signature, InsertText: name + "("})
+	}
+	return completions
+}
+
+func moduleCompletions() []Completion {
+	completions := make([]Completion, 0, len(udfModules))
+	for module := range udfModules {
+		completions = append(completions, Completion{Label: module, Kind: CompletionKindModule, Detail: "UDF module", InsertText: module + "::"})
+	}
+	return completions
+}
+
+// moduleFunctionCompletions returns functions of the module, the module name is case-sensitive in YQL
+func moduleFunctionCompletions(module string) []Completion {
+	var completions []Completion
+	for _, name := range udfModules[module] {
+		completions = append(completions, Completion{
+			Label:      name,
+			Kind:       CompletionKindFunction,
+			Detail:     module + "::" + name,
+			InsertText: name + "(",
+		})
+	}
+	return completions
+}
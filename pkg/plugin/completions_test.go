@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCachedSchema(t *testing.T) *Schema {
+	s := NewSchema(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"Anonymous","endpoint":"grpc://localhost:2136","dbLocation":"/local"}`),
+	})
+	require.NoError(t, s.err)
+	t.Cleanup(s.Close)
+	s.entries[entriesKey] = schemaEntry{loadedAt: time.Now(), value: []SchemeEntry{
+		{Path: "/local/logs", Type: EntryTypeTable},
+		{Path: "/local/metrics", Type: EntryTypeColumnTable},
+		{Path: "/local/events", Type: EntryTypeTopic},
+	}}
+	s.entries[fieldsKey("/local/logs")] = schemaEntry{loadedAt: time.Now(), value: []TableField{
+		{Name: "timestamp", Type: "Timestamp"},
+		{Name: "message", Type: "Utf8?"},
+	}}
+	return s
+}
+
+func labels(completions []Completion) (result []string) {
+	for _, c := range completions {
+		result = append(result, c.Label)
+	}
+	return result
+}
+
+func TestCompletions(t *testing.T) {
+	s := newCachedSchema(t)
+	ctx := context.Background()
+
+	completions, err := s.Completions(ctx, "SELECT * FROM ", "SELECT * FROM ")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logs", "metrics"}, labels(completions))
+	assert.Equal(t, "`/local/logs`", completions[0].InsertText)
+	completions, err = s.Completions(ctx, "SELECT * FROM `/local/me", "SELECT * FROM `/local/me")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metrics"}, labels(completions))
+
+	query := "SELECT mes FROM logs"
+	completions, err = s.Completions(ctx, query, "SELECT mes")
+	require.NoError(t, err)
+	require.NotEmpty(t, completions)
+	assert.Equal(t, Completion{Label: "message", Kind: CompletionKindColumn, Detail: "Utf8? of logs", InsertText: "message"}, completions[0])
+
+	completions, err = s.Completions(ctx, query, "SELECT DateTime::StartOfD")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"StartOfDay"}, labels(completions))
+
+	completions, err = s.Completions(ctx, query, "SELECT * FROM logs WHERE $__time")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"$__timeFilter", "$__timeFrom", "$__timeGroup", "$__timeTo"}, labels(completions))
+}
+
+func TestRetrieveCompletionsCursor(t *testing.T) {
+	s := newCachedSchema(t)
+	_, err := s.RetrieveCompletions(context.Background(), "SELECT", "7")
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	respData, err := s.RetrieveCompletions(context.Background(), "SELECT COUNT_I FROM logs", "14")
+	require.NoError(t, err)
+	assert.Contains(t, string(respData), `"label":"COUNT_IF"`)
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/credentials"
+	yc "github.com/ydb-platform/ydb-go-yc"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+func getCreds(settings *models.Settings) ydb.Option {
+	switch settings.AuthKind {
+	case "ServiceAccountKey":
+		return yc.WithServiceAccountKeyCredentials(settings.Secrets.ServiceAccAuthAccessKey)
+	case "AccessToken":
+		return ydb.WithAccessTokenCredentials(settings.Secrets.AccessToken)
+	case "UserPassword":
+		return ydb.WithStaticCredentials(settings.User, settings.Secrets.Password)
+	case "MetaData":
+		return yc.WithMetadataCredentials()
+	case "OAuth2TokenExchange", "JWT":
+		return ydb.WithCreateCredentialsFunc(func(context.Context) (credentials.Credentials, error) {
+			opts, err := Oauth2TokenExchangeOptions(settings)
+			if err != nil {
+				return nil, err
+			}
+			return credentials.NewOauth2TokenExchangeCredentials(opts...)
+		})
+	}
+	return ydb.WithAnonymousCredentials()
+}
+
+// Oauth2TokenExchangeOptions returns options of OAuth 2.0 token exchange credentials.
+// JWT auth kind exchanges a JWT signed with the private key, OAuth2TokenExchange uses a fixed subject token.
+func Oauth2TokenExchangeOptions(settings *models.Settings) ([]credentials.Oauth2TokenExchangeCredentialsOption, error) {
+	opts := []credentials.Oauth2TokenExchangeCredentialsOption{
+		credentials.WithTokenEndpoint(settings.TokenEndpoint),
+	}
+	if audience := splitList(settings.Audience, ","); len(audience) > 0 {
+		opts = append(opts, credentials.WithAudience(audience[0], audience[1:]...))
+	}
+	if scope := strings.Fields(settings.Scope); len(scope) > 0 {
+		opts = append(opts, credentials.WithScope(scope[0], scope[1:]...))
+	}
+	switch settings.AuthKind {
+	case "OAuth2TokenExchange":
+		opts = append(opts, credentials.WithFixedSubjectToken(settings.Secrets.SubjectToken, settings.SubjectTokenType))
+	case "JWT":
+		privateKey, err := models.ParsePrivateKey(settings.SigningMethod, settings.Secrets.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, credentials.WithJWTSubjectToken(
+			credentials.WithSigningMethodName(settings.SigningMethod),
+			credentials.WithPrivateKey(privateKey),
+			credentials.WithKeyID(settings.KeyID),
+			credentials.WithIssuer(settings.Issuer),
+			credentials.WithSubject(settings.Subject),
+		))
+	}
+	return opts, nil
+}
+
+func splitList(s string, sep string) (items []string) {
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
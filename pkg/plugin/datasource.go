@@ -4,7 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
-	"path"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
@@ -13,99 +14,63 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
 	"github.com/grafana/sqlds/v2"
 	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
-	"github.com/ydb-platform/ydb-go-sdk/v3/scheme"
 	"github.com/ydb-platform/ydb-go-sdk/v3/table"
 	"github.com/ydb-platform/ydb-go-sdk/v3/table/options"
 	"github.com/ydb-platform/ydb-go-sdk/v3/table/result"
 	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
-	yc "github.com/ydb-platform/ydb-go-yc"
 
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
 	"github.com/ydb/grafana-ydb-datasource/pkg/converters"
 	"github.com/ydb/grafana-ydb-datasource/pkg/macros"
 	"github.com/ydb/grafana-ydb-datasource/pkg/models"
 )
 
 func createDriver(ctx context.Context, settings *models.Settings) (db *ydb.Driver, err error) {
-	if settings.IsSecureConnection && settings.Secrets.Certificate != "" {
-		return createDriverWithCert(ctx, settings)
+	creds := getCreds(settings)
+	if settings.AuthKind == "ForwardOAuthIdentity" {
+		token, err := identityTokenFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		creds = ydb.WithAccessTokenCredentials(token)
 	}
-	return createDriverWithoutCert(ctx, settings)
+	return createDriverWithCreds(ctx, settings, creds)
 }
 
-func createDriverWithCert(ctx context.Context, settings *models.Settings) (*ydb.Driver, error) {
-	creds := getCreds(settings)
-	return ydb.Open(ctx, settings.Dsn, ydb.WithCertificatesFromPem([]byte(settings.Secrets.Certificate)), creds)
+func createDriverWithCreds(ctx context.Context, settings *models.Settings, creds ydb.Option) (db *ydb.Driver, err error) {
+	opts := append(sessionPoolOptions(settings), creds)
+	if settings.IsSecureConnection && settings.Secrets.Certificate != "" {
+		return createDriverWithCert(ctx, settings, opts...)
+	}
+	return createDriverWithoutCert(ctx, settings, opts...)
 }
 
-func createDriverWithoutCert(ctx context.Context, settings *models.Settings) (*ydb.Driver, error) {
-	creds := getCreds(settings)
-	return ydb.Open(ctx, settings.Dsn, creds)
+func createDriverWithCert(ctx context.Context, settings *models.Settings, opts ...ydb.Option) (*ydb.Driver, error) {
+	opts = append(opts, ydb.WithCertificatesFromPem([]byte(settings.Secrets.Certificate)))
+	return ydb.Open(ctx, settings.Dsn, opts...)
 }
 
-func getCreds(settings *models.Settings) ydb.Option {
-	switch settings.AuthKind {
-	case "ServiceAccountKey":
-		return yc.WithServiceAccountKeyCredentials(settings.Secrets.ServiceAccAuthAccessKey)
-	case "AccessToken":
-		return ydb.WithAccessTokenCredentials(settings.Secrets.AccessToken)
-	case "UserPassword":
-		return ydb.WithStaticCredentials(settings.User, settings.Secrets.Password)
-	case "MetaData":
-		return yc.WithMetadataCredentials()
-	}
-	return ydb.WithAnonymousCredentials()
+func createDriverWithoutCert(ctx context.Context, settings *models.Settings, opts ...ydb.Option) (*ydb.Driver, error) {
+	return ydb.Open(ctx, settings.Dsn, opts...)
 }
 
-// Ydb defines how to connect to a YDB datasource
-type Ydb struct{}
-
-// listTables returns list of all tables includes folder tables
-func listTables(ctx context.Context, db *ydb.Driver, folder string) (tables []string, _ error) {
-	dir, err := db.Scheme().ListDirectory(ctx, folder)
-	if err != nil {
-		return nil, err
+func sessionPoolOptions(settings *models.Settings) (opts []ydb.Option) {
+	if settings.SessionPoolSizeLimit > 0 {
+		opts = append(opts, ydb.WithSessionPoolSizeLimit(settings.SessionPoolSizeLimit))
 	}
-	for _, entity := range dir.Children {
-		entityPath := path.Join(folder, entity.Name)
-		switch entity.Type {
-		case scheme.EntryTable, scheme.EntryColumnTable:
-			tables = append(tables, entityPath)
-		case scheme.EntryDirectory:
-			entityTables, err := listTables(ctx, db, entityPath)
-			if err != nil {
-				return nil, err
-			}
-			tables = append(tables, entityTables...)
-		}
+	if settings.SessionIdleThresholdDuration > 0 {
+		// database/sql connections hold their own sessions, pooled sessions are used by schema queries
+		opts = append(opts,
+			ydb.WithSessionPoolIdleThreshold(settings.SessionIdleThresholdDuration),
+			ydb.WithSessionPoolSessionIdleTimeToLive(settings.SessionIdleThresholdDuration),
+		)
 	}
-	return tables, nil
+	return opts
 }
 
-func RetrieveListTablesForRoot(ctx context.Context, config backend.DataSourceInstanceSettings) (respData []byte, err error) {
-	defer func() {
-		if err != nil {
-			log.DefaultLogger.Error("Getting table list failed", "error", err.Error())
-		}
-	}()
-
-	settings, err := models.LoadSettings(config)
-	if err != nil {
-		return nil, err
-	}
-
-	ctx, cancel := context.WithTimeout(ctx, settings.TimeoutDuration)
-	defer cancel()
-
-	ydbDriver, err := createDriver(ctx, settings)
-	if err != nil {
-		return nil, err
-	}
-	data, err := listTables(ctx, ydbDriver, ydbDriver.Name())
-	if err != nil {
-		return nil, err
-	}
-
-	return json.Marshal(data)
+// Ydb defines how to connect to a YDB datasource
+type Ydb struct {
+	identities identities
 }
 
 type TableField struct {
@@ -129,38 +94,13 @@ func listFields(ctx context.Context, db *ydb.Driver, tableName string) (fields [
 			return
 		},
 	)
-	if err != nil {
-		return nil, err
-	}
-	return fields, nil
-}
-
-func RetrieveTableFields(ctx context.Context, config backend.DataSourceInstanceSettings, tableName string) (respData []byte, err error) {
-	defer func() {
-		if err != nil {
-			log.DefaultLogger.Error("Getting fields failed", "error", err.Error())
-		}
-	}()
-
-	settings, err := models.LoadSettings(config)
-	if err != nil {
-		return nil, err
-	}
-
-	ctx, cancel := context.WithTimeout(ctx, settings.TimeoutDuration)
-	defer cancel()
-
-	ydbDriver, err := createDriver(ctx, settings)
-	if err != nil {
-		return nil, err
+	if ydb.IsOperationErrorSchemeError(err) {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
 	}
-
-	fields, err := listFields(ctx, ydbDriver, tableName)
 	if err != nil {
 		return nil, err
 	}
-
-	return json.Marshal(fields)
+	return fields, nil
 }
 
 func resultSetMeta(resultSet result.Set) (meta map[string]types.Type) {
@@ -171,20 +111,67 @@ func resultSetMeta(resultSet result.Set) (meta map[string]types.Type) {
 	return meta
 }
 
-type queryModel struct {
-	RawSql string `json:"rawSql"`
-}
-
 func (h *Ydb) Settings(config backend.DataSourceInstanceSettings) sqlds.DriverSettings {
-	timeout := 60
+	timeout := 60 * time.Second
+	if settings, err := models.LoadSettings(config); err == nil {
+		timeout = settings.QueryTimeoutDuration
+	}
 	return sqlds.DriverSettings{
-		Timeout: time.Second * time.Duration(timeout),
+		Timeout: timeout,
 		FillMode: &data.FillMissing{
 			Mode: data.FillModeNull,
 		},
 	}
 }
 
+// MutateQuery compiles builder queries without rawSql, traces, system, supplementary and log context queries, wraps polls
+// of streams, limits rows, interpolates variables passed with the query, sets the sqlds format of the query format
+// and applies the query mode
+func (h *Ydb) MutateQuery(ctx context.Context, req backend.DataQuery) (context.Context, backend.DataQuery) {
+	query, err := queryModelFromContext(ctx, req)
+	if err != nil {
+		return ctx, req
+	}
+	rawSql := query.RawSql
+	switch {
+	case query.SupplementaryQueryType == SupplementaryQueryLogsVolume:
+		rawSql = builder.CompileLogsVolume(query.BuilderOptions, logsVolumeBucket(req))
+	case query.QueryType == queryTypeTraces:
+		rawSql = builder.CompileTraces(*query.Traces)
+	case query.QueryType == queryTypeSystem:
+		rawSql = builder.CompileSystem(*query.System)
+	case query.LogContext != nil:
+		rawSql = builder.CompileLogContext(query.BuilderOptions, *query.LogContext)
+	case query.QueryType == queryTypeBuilder && strings.TrimSpace(rawSql) == "":
+		rawSql = builder.Compile(query.BuilderOptions, query.QueryFormat)
+	}
+	if query.Stream != nil && query.Stream.Poll {
+		rawSql = builder.CompileStream(rawSql, *query.Stream)
+	}
+	if query.Options.RowLimit > 0 && query.Mode != QueryModeExplain {
+		// one more row tells the response that rows were truncated
+		rawSql = builder.CompileRowLimit(rawSql, query.Options.RowLimit+1)
+	}
+	rawSql = interpolateVariables(rawSql, query.Variables)
+	if rawSql != query.RawSql {
+		rawSqlJSON, _ := json.Marshal(rawSql)
+		if queryJSON, err := setQueryField(req.JSON, "rawSql", rawSqlJSON); err == nil {
+			req.JSON = queryJSON
+		}
+	}
+	formatJSON, _ := json.Marshal(query.sqldsFormat())
+	if queryJSON, err := setQueryField(req.JSON, "format", formatJSON); err == nil {
+		req.JSON = queryJSON
+	}
+	if txControl := query.txControl(); txControl != nil {
+		ctx = ydb.WithTxControl(ctx, txControl)
+	}
+	if query.Mode == QueryModeExplain {
+		ctx = ydb.WithQueryMode(ctx, ydb.ExplainQueryMode)
+	}
+	return ctx, req
+}
+
 // Connect opens a sql.DB connection using datasource settings
 func (h *Ydb) Connect(config backend.DataSourceInstanceSettings, message json.RawMessage) (_ *sql.DB, err error) {
 	defer func() {
@@ -196,23 +183,62 @@ func (h *Ydb) Connect(config backend.DataSourceInstanceSettings, message json.Ra
 	if err != nil {
 		return nil, err
 	}
-	connectionCtx, connectionCancel := context.WithTimeout(context.Background(), settings.TimeoutDuration)
+	connectionCtx, connectionCancel := context.WithTimeout(context.Background(), settings.ConnectTimeoutDuration)
 	defer connectionCancel()
 
+	if settings.AuthKind == "ForwardOAuthIdentity" {
+		return h.connectWithIdentity(connectionCtx, settings, message)
+	}
+
 	ydbDriver, err := createDriver(connectionCtx, settings)
 	if err != nil {
 		return nil, err
 	}
+	return openDB(connectionCtx, settings, ydbDriver)
+}
 
-	connector, err := ydb.Connector(ydbDriver, ydb.WithAutoDeclare(),
-		ydb.WithNumericArgs(), ydb.WithPositionalArgs(), ydb.WithQueryService(true),
-	)
+// connectWithIdentity opens the connection of the user passed in connection arguments, which connects
+// with the credentials of the current identity of the user
+func (h *Ydb) connectWithIdentity(ctx context.Context, settings *models.Settings, message json.RawMessage) (*sql.DB, error) {
+	if len(message) == 0 {
+		return openMissingIdentityDB(), nil
+	}
+	var args identityConnectionArgs
+	if err := json.Unmarshal(message, &args); err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(identityConnector{identities: &h.identities, key: args.Identity, settings: settings})
+	configureDB(settings, db)
+	h.identities.setDB(args.Identity, db, settings.MaxIdleConns)
+	return db, db.PingContext(ctx)
+}
+
+func openDB(ctx context.Context, settings *models.Settings, ydbDriver *ydb.Driver) (*sql.DB, error) {
+	connector, err := newConnector(ydbDriver)
 	if err != nil {
 		return nil, err
 	}
 	db := sql.OpenDB(connector)
+	configureDB(settings, db)
+	return db, db.PingContext(ctx)
+}
 
-	return db, db.PingContext(connectionCtx)
+func newConnector(ydbDriver *ydb.Driver) (ydb.SQLConnector, error) {
+	return ydb.Connector(ydbDriver, ydb.WithAutoDeclare(),
+		ydb.WithNumericArgs(), ydb.WithPositionalArgs(), ydb.WithQueryService(true),
+	)
+}
+
+func configureDB(settings *models.Settings, db *sql.DB) {
+	if settings.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(settings.MaxOpenConns)
+	}
+	if settings.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(settings.MaxIdleConns)
+	}
+	if settings.ConnMaxLifetimeDuration > 0 {
+		db.SetConnMaxLifetime(settings.ConnMaxLifetimeDuration)
+	}
 }
 
 // Converters defines list of data type converters
@@ -1,5 +1,15 @@
 package plugin
 
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
 // import (
 // 	"context"
 // 	"testing"
@@ -26,3 +36,35 @@ package plugin
 // 		t.Fatal("QueryData must return a response")
 // 	}
 // }
+
+func TestQueryTimeout(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"rawSql":"SELECT 1","timeout":"1h"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, query.timeout(2*time.Second))
+	assert.Equal(t, time.Hour, query.timeout(0))
+
+	query, err = parseQueryModel([]byte(`{"version":1,"rawSql":"SELECT 1","options":{"timeout":"1500ms"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 1500*time.Millisecond, query.timeout(time.Minute))
+
+	query, err = parseQueryModel([]byte(`{"rawSql":"SELECT 1"}`))
+	assert.NoError(t, err)
+	assert.Zero(t, query.timeout(time.Minute))
+}
+
+func TestMutateQueryCompilesBuilderQuery(t *testing.T) {
+	ydb := &Ydb{}
+	_, query := ydb.MutateQuery(context.Background(), backend.DataQuery{JSON: []byte(`{
+		"queryType":"builder","queryFormat":"table","rawSql":"",
+		"builderOptions":{"table":"logs","fields":["host"],"limit":10}
+	}`)})
+	var model queryModel
+	assert.NoError(t, json.Unmarshal(query.JSON, &model))
+	assert.Equal(t, "SELECT `host` \nFROM `logs` \nLIMIT 10", model.RawSql)
+
+	_, query = ydb.MutateQuery(context.Background(), backend.DataQuery{JSON: []byte(`{
+		"queryType":"builder","rawSql":"SELECT 1","builderOptions":{"table":"logs"}
+	}`)})
+	assert.NoError(t, json.Unmarshal(query.JSON, &model))
+	assert.Equal(t, "SELECT 1", model.RawSql)
+}
@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-genproto/protos/Ydb_Table"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/scheme"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/options"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+const (
+	StoreTypeRow    = "row"
+	StoreTypeColumn = "column"
+)
+
+// TableDescription is the full description of a table
+type TableDescription struct {
+	Path           string                  `json:"path"`
+	StoreType      string                  `json:"storeType"`
+	Columns        []ColumnDescription     `json:"columns"`
+	PrimaryKey     []string                `json:"primaryKey"`
+	Indexes        []IndexDescription      `json:"indexes"`
+	TTL            *TTLDescription         `json:"ttl,omitempty"`
+	Partitioning   PartitioningDescription `json:"partitioning"`
+	ColumnFamilies []ColumnFamily          `json:"columnFamilies"`
+	Changefeeds    []ChangefeedDescription `json:"changefeeds"`
+	Stats          *TableStats             `json:"stats,omitempty"`
+	Attributes     map[string]string       `json:"attributes,omitempty"`
+}
+
+type ColumnDescription struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+	Family   string `json:"family,omitempty"`
+	// KeyOrder is the position of the column in the primary key starting from 1, zero for non-key columns
+	KeyOrder int `json:"keyOrder,omitempty"`
+}
+
+type IndexDescription struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Status      string   `json:"status"`
+	Columns     []string `json:"columns"`
+	DataColumns []string `json:"dataColumns"`
+}
+
+type TTLDescription struct {
+	Column             string `json:"column"`
+	Mode               string `json:"mode"`
+	ExpireAfterSeconds uint32 `json:"expireAfterSeconds"`
+	Unit               string `json:"unit,omitempty"`
+}
+
+type PartitioningDescription struct {
+	BySize             bool   `json:"bySize"`
+	PartitionSizeMb    uint64 `json:"partitionSizeMb,omitempty"`
+	ByLoad             bool   `json:"byLoad"`
+	MinPartitionsCount uint64 `json:"minPartitionsCount,omitempty"`
+	MaxPartitionsCount uint64 `json:"maxPartitionsCount,omitempty"`
+}
+
+type ColumnFamily struct {
+	Name         string `json:"name"`
+	Media        string `json:"media,omitempty"`
+	Compression  string `json:"compression,omitempty"`
+	KeepInMemory bool   `json:"keepInMemory"`
+}
+
+type ChangefeedDescription struct {
+	Name   string `json:"name"`
+	Mode   string `json:"mode"`
+	Format string `json:"format"`
+	State  string `json:"state"`
+}
+
+type TableStats struct {
+	RowsEstimate     uint64    `json:"rowsEstimate"`
+	StoreSize        uint64    `json:"storeSize"`
+	Partitions       uint64    `json:"partitions"`
+	CreationTime     time.Time `json:"creationTime"`
+	ModificationTime time.Time `json:"modificationTime"`
+}
+
+func describeTable(ctx context.Context, db *ydb.Driver, tableName string) (description TableDescription, _ error) {
+	entry, err := db.Scheme().DescribePath(ctx, tableName)
+	if ydb.IsOperationErrorSchemeError(err) {
+		return description, fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+	}
+	if err != nil {
+		return description, err
+	}
+	if !entry.IsTable() && !entry.IsColumnTable() {
+		return description, fmt.Errorf("%w: %s is not a table", ErrInvalidParameter, tableName)
+	}
+	err = db.Table().Do(ctx,
+		func(ctx context.Context, s table.Session) (err error) {
+			desc, err := s.DescribeTable(ctx, tableName, options.WithTableStats())
+			if err != nil {
+				return
+			}
+			description = newTableDescription(tableName, desc)
+			return
+		},
+		table.WithIdempotent(),
+	)
+	if entry.Type == scheme.EntryColumnTable {
+		description.StoreType = StoreTypeColumn
+	}
+	return description, err
+}
+
+func newTableDescription(tableName string, desc options.Description) TableDescription {
+	description := TableDescription{
+		Path:           tableName,
+		StoreType:      StoreTypeRow,
+		Columns:        []ColumnDescription{},
+		PrimaryKey:     desc.PrimaryKey,
+		Indexes:        []IndexDescription{},
+		ColumnFamilies: []ColumnFamily{},
+		Changefeeds:    []ChangefeedDescription{},
+		Attributes:     desc.Attributes,
+		Partitioning: PartitioningDescription{
+			BySize:             desc.PartitioningSettings.PartitioningBySize == options.FeatureEnabled,
+			PartitionSizeMb:    desc.PartitioningSettings.PartitionSizeMb,
+			ByLoad:             desc.PartitioningSettings.PartitioningByLoad == options.FeatureEnabled,
+			MinPartitionsCount: desc.PartitioningSettings.MinPartitionsCount,
+			MaxPartitionsCount: desc.PartitioningSettings.MaxPartitionsCount,
+		},
+	}
+	keyOrder := make(map[string]int, len(desc.PrimaryKey))
+	for i, name := range desc.PrimaryKey {
+		keyOrder[name] = i + 1
+	}
+	for _, c := range desc.Columns {
+		nullable, _ := types.IsOptional(c.Type)
+		description.Columns = append(description.Columns, ColumnDescription{
+			Name:     c.Name,
+			Type:     c.Type.Yql(),
+			Nullable: nullable,
+			Family:   c.Family,
+			KeyOrder: keyOrder[c.Name],
+		})
+	}
+	for _, index := range desc.Indexes {
+		indexType := "global"
+		if index.Type == options.IndexTypeGlobalAsync {
+			indexType = "globalAsync"
+		}
+		description.Indexes = append(description.Indexes, IndexDescription{
+			Name:        index.Name,
+			Type:        indexType,
+			Status:      enumName(index.Status.String(), "STATUS_"),
+			Columns:     index.IndexColumns,
+			DataColumns: index.DataColumns,
+		})
+	}
+	if ttl := desc.TimeToLiveSettings; ttl != nil {
+		description.TTL = &TTLDescription{
+			Column:             ttl.ColumnName,
+			Mode:               "dateType",
+			ExpireAfterSeconds: ttl.ExpireAfterSeconds,
+		}
+		if ttl.Mode == options.TimeToLiveModeValueSinceUnixEpoch {
+			description.TTL.Mode = "valueSinceUnixEpoch"
+		}
+		if ttl.ColumnUnit != nil {
+			description.TTL.Unit = ttlUnits[*ttl.ColumnUnit]
+		}
+	}
+	for _, family := range desc.ColumnFamilies {
+		compression := ""
+		if family.Compression != options.ColumnFamilyCompressionUnknown {
+			compression = family.Compression.String()
+		}
+		description.ColumnFamilies = append(description.ColumnFamilies, ColumnFamily{
+			Name:         family.Name,
+			Media:        family.Data.Media,
+			Compression:  compression,
+			KeepInMemory: family.KeepInMemory == options.FeatureEnabled,
+		})
+	}
+	for _, changefeed := range desc.Changefeeds {
+		description.Changefeeds = append(description.Changefeeds, ChangefeedDescription{
+			Name:   changefeed.Name,
+			Mode:   enumName(Ydb_Table.ChangefeedMode_Mode(changefeed.Mode).String(), "MODE_"),
+			Format: enumName(Ydb_Table.ChangefeedFormat_Format(changefeed.Format).String(), "FORMAT_"),
+			State:  enumName(Ydb_Table.ChangefeedDescription_State(changefeed.State).String(), "STATE_"),
+		})
+	}
+	if stats := desc.Stats; stats != nil {
+		description.Stats = &TableStats{
+			RowsEstimate:     stats.RowsEstimate,
+			StoreSize:        stats.StoreSize,
+			Partitions:       stats.Partitions,
+			CreationTime:     stats.CreationTime,
+			ModificationTime: stats.ModificationTime,
+		}
+	}
+	return description
+}
+
+var ttlUnits = map[options.TimeToLiveUnit]string{
+	options.TimeToLiveUnitSeconds:      "seconds",
+	options.TimeToLiveUnitMilliseconds: "milliseconds",
+	options.TimeToLiveUnitMicroseconds: "microseconds",
+	options.TimeToLiveUnitNanoseconds:  "nanoseconds",
+}
+
+// enumName turns a proto enum name like MODE_NEW_AND_OLD_IMAGES into newAndOldImages
+func enumName(name string, prefix string) string {
+	words := strings.Split(strings.ToLower(strings.TrimPrefix(name, prefix)), "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
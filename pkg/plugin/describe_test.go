@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ydb-platform/ydb-go-genproto/protos/Ydb_Table"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/options"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
+)
+
+func TestNewTableDescription(t *testing.T) {
+	unit := options.TimeToLiveUnitSeconds
+	description := newTableDescription("/local/logs", options.Description{
+		Columns: []options.Column{
+			{Name: "ts", Type: types.TypeUint64},
+			{Name: "host", Type: types.TypeUTF8},
+			{Name: "message", Type: types.Optional(types.TypeUTF8)},
+		},
+		PrimaryKey: []string{"host", "ts"},
+		Indexes: []options.IndexDescription{{
+			Name:         "by_message",
+			IndexColumns: []string{"message"},
+			Status:       Ydb_Table.TableIndexDescription_STATUS_READY,
+			Type:         options.IndexTypeGlobalAsync,
+		}},
+		TimeToLiveSettings: &options.TimeToLiveSettings{
+			ColumnName:         "ts",
+			Mode:               options.TimeToLiveModeValueSinceUnixEpoch,
+			ExpireAfterSeconds: 3600,
+			ColumnUnit:         &unit,
+		},
+		PartitioningSettings: options.PartitioningSettings{PartitioningBySize: options.FeatureEnabled},
+		Changefeeds: []options.ChangefeedDescription{{
+			Name:   "updates",
+			Mode:   options.ChangefeedModeNewAndOldImages,
+			Format: options.ChangefeedFormatJSON,
+			State:  options.ChangefeedStateEnabled,
+		}},
+	})
+
+	assert.Equal(t, StoreTypeRow, description.StoreType)
+	require.Len(t, description.Columns, 3)
+	assert.Equal(t, ColumnDescription{Name: "ts", Type: "Uint64", KeyOrder: 2}, description.Columns[0])
+	assert.Equal(t, ColumnDescription{Name: "message", Type: "Optional<Utf8>", Nullable: true}, description.Columns[2])
+	assert.Equal(t, []IndexDescription{{Name: "by_message", Type: "globalAsync", Status: "ready", Columns: []string{"message"}}}, description.Indexes)
+	assert.Equal(t, &TTLDescription{Column: "ts", Mode: "valueSinceUnixEpoch", ExpireAfterSeconds: 3600, Unit: "seconds"}, description.TTL)
+	assert.True(t, description.Partitioning.BySize)
+	assert.Equal(t, []ChangefeedDescription{{Name: "updates", Mode: "newAndOldImages", Format: "json", State: "enabled"}}, description.Changefeeds)
+	assert.Nil(t, description.Stats)
+}
@@ -0,0 +1,293 @@
+package plugin
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-genproto/Ydb_Scheme_V1"
+	ydbProto "github.com/ydb-platform/ydb-go-genproto/protos/Ydb"
+	"github.com/ydb-platform/ydb-go-genproto/protos/Ydb_Scheme"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/retry"
+	"golang.org/x/sync/errgroup"
+)
+
+// listDirectoryWorkers limits concurrent ListDirectory calls of one listing
+const listDirectoryWorkers = 8
+
+// entryTypes names scheme entry types. The scheme client of ydb-go-sdk doesn't know
+// views, external tables, external data sources and sequences, so the scheme service is called directly.
+var entryTypes = map[Ydb_Scheme.Entry_Type]string{
+	Ydb_Scheme.Entry_DIRECTORY:            EntryTypeDirectory,
+	Ydb_Scheme.Entry_TABLE:                EntryTypeTable,
+	Ydb_Scheme.Entry_PERS_QUEUE_GROUP:     "PersQueueGroup",
+	Ydb_Scheme.Entry_DATABASE:             "Database",
+	Ydb_Scheme.Entry_RTMR_VOLUME:          "RtmrVolume",
+	Ydb_Scheme.Entry_BLOCK_STORE_VOLUME:   "BlockStoreVolume",
+	Ydb_Scheme.Entry_COORDINATION_NODE:    "CoordinationNode",
+	Ydb_Scheme.Entry_COLUMN_STORE:         "ColumnStore",
+	Ydb_Scheme.Entry_COLUMN_TABLE:         EntryTypeColumnTable,
+	Ydb_Scheme.Entry_SEQUENCE:             "Sequence",
+	Ydb_Scheme.Entry_REPLICATION:          "Replication",
+	Ydb_Scheme.Entry_TOPIC:                EntryTypeTopic,
+	Ydb_Scheme.Entry_EXTERNAL_TABLE:       "ExternalTable",
+	Ydb_Scheme.Entry_EXTERNAL_DATA_SOURCE: "ExternalDataSource",
+	Ydb_Scheme.Entry_VIEW:                 "View",
+}
+
+const (
+	EntryTypeDirectory   = "Directory"
+	EntryTypeTable       = "Table"
+	EntryTypeColumnTable = "ColumnTable"
+	EntryTypeTopic       = "Topic"
+	EntryTypeUnknown     = "Unknown"
+)
+
+// SchemePermissions lists permissions granted to the subject
+type SchemePermissions struct {
+	Subject         string   `json:"subject"`
+	PermissionNames []string `json:"permissionNames"`
+}
+
+// entryTypeName returns the type name matching the name case-insensitively
+func entryTypeName(name string) (string, bool) {
+	for _, entryType := range entryTypes {
+		if strings.EqualFold(entryType, name) {
+			return entryType, true
+		}
+	}
+	return "", false
+}
+
+// SchemeEntry describes an object of the database scheme
+type SchemeEntry struct {
+	Path                 string              `json:"path"`
+	Name                 string              `json:"name"`
+	Type                 string              `json:"type"`
+	Owner                string              `json:"owner"`
+	CreatedAt            *time.Time          `json:"createdAt,omitempty"`
+	SizeBytes            uint64              `json:"sizeBytes,omitempty"`
+	EffectivePermissions []SchemePermissions `json:"effectivePermissions"`
+}
+
+// IsTable reports whether rows of the entry can be selected as a table
+func (e SchemeEntry) IsTable() bool {
+	return e.Type == EntryTypeTable || e.Type == EntryTypeColumnTable
+}
+
+func newSchemeEntry(folder string, entry *Ydb_Scheme.Entry) SchemeEntry {
+	e := SchemeEntry{
+		Path:                 path.Join(folder, entry.GetName()),
+		Name:                 entry.GetName(),
+		Type:                 EntryTypeUnknown,
+		Owner:                entry.GetOwner(),
+		SizeBytes:            entry.GetSizeBytes(),
+		EffectivePermissions: []SchemePermissions{},
+	}
+	if name, ok := entryTypes[entry.GetType()]; ok {
+		e.Type = name
+	}
+	// plan step of the creation is milliseconds since epoch
+	if createdAt := entry.GetCreatedAt().GetPlanStep(); createdAt > 0 {
+		t := time.UnixMilli(int64(createdAt)).UTC()
+		e.CreatedAt = &t
+	}
+	for _, p := range entry.GetEffectivePermissions() {
+		e.EffectivePermissions = append(e.EffectivePermissions, SchemePermissions{
+			Subject:         p.GetSubject(),
+			PermissionNames: p.GetPermissionNames(),
+		})
+	}
+	return e
+}
+
+func listDirectory(ctx context.Context, client Ydb_Scheme_V1.SchemeServiceClient, folder string) (entries []SchemeEntry, _ error) {
+	err := retry.Retry(ctx, func(ctx context.Context) error {
+		response, err := client.ListDirectory(ctx, &Ydb_Scheme.ListDirectoryRequest{Path: folder})
+		if err != nil {
+			return err
+		}
+		operation := response.GetOperation()
+		if operation.GetStatus() != ydbProto.StatusIds_SUCCESS {
+			return fmt.Errorf("list directory %s: %s: %v", folder, operation.GetStatus(), operation.GetIssues())
+		}
+		var result Ydb_Scheme.ListDirectoryResult
+		if err := operation.GetResult().UnmarshalTo(&result); err != nil {
+			return err
+		}
+		entries = make([]SchemeEntry, 0, len(result.GetChildren()))
+		for _, child := range result.GetChildren() {
+			entries = append(entries, newSchemeEntry(folder, child))
+		}
+		return nil
+	}, retry.WithIdempotent(true))
+	return entries, err
+}
+
+// listEntries returns entries of the folder sorted by path, includes entries of nested folders.
+// Folders are listed level by level concurrently, depth limits levels (zero means no limit).
+func listEntries(ctx context.Context, db *ydb.Driver, folder string, depth int) (entries []SchemeEntry, _ error) {
+	client := Ydb_Scheme_V1.NewSchemeServiceClient(ydb.GRPCConn(db))
+	dirs := []string{folder}
+	for level := 1; len(dirs) > 0 && (depth <= 0 || level <= depth); level++ {
+		children := make([][]SchemeEntry, len(dirs))
+		g, ctx := errgroup.WithContext(ctx)
+		g.SetLimit(listDirectoryWorkers)
+		for i, dir := range dirs {
+			g.Go(func() (err error) {
+				children[i], err = listDirectory(ctx, client, dir)
+				return err
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return nil, err
+		}
+		var nextDirs []string
+		for _, dirChildren := range children {
+			for _, entry := range dirChildren {
+				entries = append(entries, entry)
+				if entry.Type == EntryTypeDirectory {
+					nextDirs = append(nextDirs, entry.Path)
+				}
+			}
+		}
+		dirs = nextDirs
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries, nil
+}
+
+// tablePaths returns paths of the entries which are tables
+func tablePaths(entries []SchemeEntry) []string {
+	tables := []string{}
+	for _, entry := range entries {
+		if entry.IsTable() {
+			tables = append(tables, entry.Path)
+		}
+	}
+	return tables
+}
+
+// walkItem is an entry or a folder which entries are not walked yet. Paths of folder entries
+// start with the folder path and a slash, so the folder is keyed by that prefix.
+type walkItem struct {
+	key     string
+	entry   *SchemeEntry
+	listing *folderListing
+	level   int
+}
+
+// folderListing lists entries of a folder, idle workers list folders ahead of the walk
+type folderListing struct {
+	folder   string
+	started  bool
+	done     chan struct{}
+	children []SchemeEntry
+	err      error
+}
+
+type walkQueue []walkItem
+
+func (q walkQueue) Len() int           { return len(q) }
+func (q walkQueue) Less(i, j int) bool { return q[i].key < q[j].key }
+func (q walkQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *walkQueue) Push(x any)        { *q = append(*q, x.(walkItem)) }
+func (q *walkQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// walkEntries returns entries of the folder sorted by path which follow the cursor and match,
+// at most limit entries (zero means no limit). Entries are walked in path order, while at most
+// listDirectoryWorkers folders are listed concurrently, the first folders in path order first.
+// Folders before the cursor are not listed, listings of folders after the last returned entry are cancelled.
+func walkEntries(ctx context.Context, list func(ctx context.Context, folder string) ([]SchemeEntry, error),
+	folder string, depth int, cursor string, limit int, match func(SchemeEntry) bool,
+) (entries []SchemeEntry, _ error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var g errgroup.Group
+	g.SetLimit(listDirectoryWorkers)
+	defer func() {
+		cancel()
+		_ = g.Wait()
+	}()
+	// start lists the folder with a worker, waiting for an idle one unless it's a prefetch
+	start := func(l *folderListing, wait bool) bool {
+		if l.started {
+			return true
+		}
+		run := func() error {
+			defer close(l.done)
+			l.children, l.err = list(ctx, l.folder)
+			return nil
+		}
+		if wait {
+			g.Go(run)
+		} else if !g.TryGo(run) {
+			return false
+		}
+		l.started = true
+		return true
+	}
+
+	queue := &walkQueue{}
+	// pending are folders to walk in path order, listed by idle workers
+	pending := &walkQueue{}
+	addFolder := func(folder string, level int) {
+		key := folder + "/"
+		if cursor != "" && key < cursor && !strings.HasPrefix(cursor, key) {
+			// all entries of the folder precede the cursor
+			return
+		}
+		item := walkItem{key: key, listing: &folderListing{folder: folder, done: make(chan struct{})}, level: level}
+		heap.Push(queue, item)
+		heap.Push(pending, item)
+	}
+	prefetch := func() {
+		for pending.Len() > 0 && start((*pending)[0].listing, false) {
+			heap.Pop(pending)
+		}
+	}
+
+	addFolder(folder, 1)
+	for queue.Len() > 0 {
+		prefetch()
+		item := heap.Pop(queue).(walkItem)
+		if l := item.listing; l != nil {
+			start(l, true)
+			select {
+			case <-l.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if l.err != nil {
+				return nil, l.err
+			}
+			for i := range l.children {
+				child := &l.children[i]
+				heap.Push(queue, walkItem{key: child.Path, entry: child, level: item.level})
+				if child.Type == EntryTypeDirectory && (depth <= 0 || item.level < depth) {
+					addFolder(child.Path, item.level+1)
+				}
+			}
+			continue
+		}
+		entry := *item.entry
+		if entry.Path > cursor && match(entry) {
+			entries = append(entries, entry)
+			if limit > 0 && len(entries) == limit {
+				break
+			}
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,24 @@
+package plugin
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrInvalidParameter = errors.New("invalid parameter")
+	ErrTableNotFound    = errors.New("table not found")
+	ErrSchemaClosed     = errors.New("schema is closed")
+)
+
+// HTTPStatus returns the status code of the resource response failed with the error
+func HTTPStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidParameter):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrTableNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
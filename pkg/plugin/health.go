@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/sqlds/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// Datasource extends the sqlds datasource with YDB specific handlers
+type Datasource struct {
+	*sqlds.SQLDatasource
+	ydb     *Ydb
+	schema  *Schema
+	streams streams
+}
+
+func NewDatasource(ds *sqlds.SQLDatasource, ydb *Ydb, schema *Schema) *Datasource {
+	return &Datasource{SQLDatasource: ds, ydb: ydb, schema: schema}
+}
+
+// Dispose stops the schema cache and pollers of streams of the instance, when settings are changed
+func (ds *Datasource) Dispose() {
+	ds.schema.Close()
+	ds.streams.close()
+	ds.SQLDatasource.Dispose()
+}
+
+// QueryData runs valid queries and reads metrics of topic lag queries, frames are truncated to row limits
+// of queries and converted to query formats, frames of variable queries are converted to variable values
+func (ds *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	valid, invalid, models := validQueries(req)
+	valid, lag := ds.topicLagResponses(ctx, valid, models)
+	resp, err := ds.sqlQueryData(withQueryModels(ctx, models), valid, models)
+	if err != nil {
+		return nil, err
+	}
+	for refID, response := range invalid {
+		resp.Responses[refID] = response
+	}
+	for refID, response := range lag {
+		resp.Responses[refID] = response
+	}
+	limitResponses(models, resp)
+	formatResponses(req, models, resp)
+	variableResponses(models, resp)
+	return resp, nil
+}
+
+// sqlQueryData runs queries with sqlds. Queries with a timeout run in batches of the same timeout
+// with contexts released when their batch is done
+func (ds *Datasource) sqlQueryData(ctx context.Context, req *backend.QueryDataRequest, models queryModels) (*backend.QueryDataResponse, error) {
+	var maxTimeout time.Duration
+	if ds.schema.settings != nil {
+		maxTimeout = ds.schema.settings.QueryTimeoutDuration
+	}
+	batches := map[time.Duration][]backend.DataQuery{}
+	for _, q := range req.Queries {
+		var timeout time.Duration
+		if query, ok := models[q.RefID]; ok {
+			timeout = query.timeout(maxTimeout)
+		}
+		batches[timeout] = append(batches[timeout], q)
+	}
+
+	resp := backend.NewQueryDataResponse()
+	var mu sync.Mutex
+	var g errgroup.Group
+	for timeout, queries := range batches {
+		g.Go(func() error {
+			ctx := ctx
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			batch := *req
+			batch.Queries = queries
+			batchResp, err := ds.SQLDatasource.QueryData(ctx, &batch)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for refID, response := range batchResp.Responses {
+				resp.Responses[refID] = response
+			}
+			return nil
+		})
+	}
+	return resp, g.Wait()
+}
+
+// CheckHealth pings the database and reports the usage of database/sql connections,
+// each open connection holds one YDB session
+func (ds *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	db, err := ds.GetDBFromQuery(&sqlds.Query{}, dataSourceUID(req.PluginContext))
+	if err == nil {
+		err = db.PingContext(ctx)
+	}
+	return healthResult(db, err), nil
+}
+
+type poolStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+func healthResult(db *sql.DB, err error) *backend.CheckHealthResult {
+	if err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: err.Error(),
+		}
+	}
+	stats := db.Stats()
+	details, _ := json.Marshal(map[string]poolStats{
+		"connections": {
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		},
+	})
+	return &backend.CheckHealthResult{
+		Status:      backend.HealthStatusOk,
+		Message:     "Data source is working",
+		JSONDetails: details,
+	}
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+// packets collects packets sent to a stream subscriber
+type packets struct {
+	mu   sync.Mutex
+	data []json.RawMessage
+}
+
+func (p *packets) Send(packet *backend.StreamPacket) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.data = append(p.data, packet.Data)
+	return nil
+}
+
+func (p *packets) frame(t *testing.T, i int) *data.Frame {
+	frame := &data.Frame{}
+	require.NoError(t, json.Unmarshal(p.data[i], frame))
+	return frame
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func concreteValues(field *data.Field) []any {
+	values := make([]any, field.Len())
+	for i := range values {
+		values[i], _ = field.ConcreteAt(i)
+	}
+	return values
+}
+
+func fieldNames(frame *data.Frame) []string {
+	names := make([]string, len(frame.Fields))
+	for i, field := range frame.Fields {
+		names[i] = field.Name
+	}
+	return names
+}
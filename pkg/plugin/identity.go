@@ -0,0 +1,518 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/sqlds/v2"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+type identityTokenKey struct{}
+
+// WithIdentityToken stores the Grafana user's OAuth identity token in the context
+func WithIdentityToken(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, identityTokenKey{}, strings.TrimPrefix(header, "Bearer "))
+}
+
+func identityTokenFromContext(ctx context.Context) (string, error) {
+	token, _ := ctx.Value(identityTokenKey{}).(string)
+	if token == "" {
+		return "", fmt.Errorf("%w", models.ErrOAuthIdentityTokenEmpty)
+	}
+	return token, nil
+}
+
+// forwardedCredentials returns the latest token which Grafana passed for the user
+type forwardedCredentials struct {
+	mu    sync.RWMutex
+	token string
+}
+
+func (c *forwardedCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.token == "" {
+		return "", fmt.Errorf("%w", models.ErrOAuthIdentityTokenEmpty)
+	}
+	return c.token, nil
+}
+
+func (c *forwardedCredentials) setToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Limits of forwarded identities, an identity unused for the idle timeout or the least recently used one
+// beyond the limit is dropped and its connections are closed
+const (
+	identityIdleTimeout = 30 * time.Minute
+	maxIdentities       = 1000
+)
+
+// defaultMaxIdleConns is the database/sql limit of idle connections of databases without the setting
+const defaultMaxIdleConns = 2
+
+// identity is the forwarded identity of a Grafana user with connections opened for it
+type identity struct {
+	key            string
+	creds          *forwardedCredentials
+	connectionArgs json.RawMessage
+	lastUsed       time.Time
+	// refs counts running requests and streams of the identity, which is never dropped while used
+	refs int
+
+	mu        sync.Mutex
+	driver    *ydb.Driver
+	connector ydb.SQLConnector
+	closed    bool
+}
+
+// ydbDriver returns the driver of the identity for queries, schema and topic requests, it is opened once
+func (id *identity) ydbDriver(ctx context.Context, settings *models.Settings) (*ydb.Driver, error) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	return id.ydbDriverLocked(ctx, settings)
+}
+
+func (id *identity) ydbDriverLocked(ctx context.Context, settings *models.Settings) (*ydb.Driver, error) {
+	if id.closed {
+		return nil, fmt.Errorf("%w", models.ErrOAuthIdentityExpired)
+	}
+	if id.driver != nil {
+		return id.driver, nil
+	}
+	connectionCtx, connectionCancel := context.WithTimeout(ctx, settings.ConnectTimeoutDuration)
+	defer connectionCancel()
+	driver, err := createDriverWithCreds(connectionCtx, settings, ydb.WithCredentials(id.creds))
+	if err != nil {
+		return nil, err
+	}
+	id.driver = driver
+	return driver, nil
+}
+
+// sqlConnector returns the database/sql connector of the identity, it is opened once
+func (id *identity) sqlConnector(ctx context.Context, settings *models.Settings) (driver.Connector, error) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if id.connector != nil && !id.closed {
+		return id.connector, nil
+	}
+	ydbDriver, err := id.ydbDriverLocked(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+	if id.connector, err = newConnector(ydbDriver); err != nil {
+		return nil, err
+	}
+	return id.connector, nil
+}
+
+func (id *identity) close() {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	id.closed = true
+	if id.connector != nil {
+		id.connector.Close()
+	}
+	if id.driver != nil {
+		id.driver.Close(context.Background())
+	}
+}
+
+// identityDB is the connection of a user opened by sqlds
+type identityDB struct {
+	db           *sql.DB
+	maxIdleConns int
+}
+
+// closeIdle closes idle connections of the dropped identity, new connections are opened with the next identity
+func (db identityDB) closeIdle() {
+	db.db.SetMaxIdleConns(0)
+	db.db.SetMaxIdleConns(db.maxIdleConns)
+}
+
+// identities keeps credentials of each Grafana user, so a connection opened for the user
+// keeps working after Grafana refreshes the user's token. Connection arguments of a user are stable,
+// so sqlds keeps one connection per user, which connects with the current identity of the user
+type identities struct {
+	mu      sync.Mutex
+	entries map[string]*identity
+	dbs     map[string]identityDB
+}
+
+type identityConnectionArgs struct {
+	Identity string `json:"identity"`
+}
+
+// forward remembers the user's token and returns connection arguments identifying the user,
+// the identity is kept until the returned function is called
+func (i *identities) forward(ctx context.Context, user *backend.User) (json.RawMessage, func(), error) {
+	token, err := identityTokenFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := identityKey(user, token)
+
+	i.mu.Lock()
+	if i.entries == nil {
+		i.entries = make(map[string]*identity)
+	}
+	id, ok := i.entries[key]
+	if !ok {
+		connectionArgs, err := json.Marshal(identityConnectionArgs{Identity: key})
+		if err != nil {
+			i.mu.Unlock()
+			return nil, nil, err
+		}
+		id = &identity{key: key, creds: &forwardedCredentials{}, connectionArgs: connectionArgs}
+		i.entries[key] = id
+	}
+	id.creds.setToken(token)
+	release := i.acquireLocked(id)
+	evicted := i.evictLocked(time.Now())
+	var idle []identityDB
+	for _, e := range evicted {
+		if db, ok := i.dbs[e.key]; ok {
+			idle = append(idle, db)
+		}
+	}
+	i.mu.Unlock()
+
+	for _, db := range idle {
+		db.closeIdle()
+	}
+	for _, e := range evicted {
+		e.close()
+	}
+	return id.connectionArgs, release, nil
+}
+
+// get returns the identity of connection arguments
+func (i *identities) get(connectionArgs json.RawMessage) (*identity, error) {
+	var args identityConnectionArgs
+	if err := json.Unmarshal(connectionArgs, &args); err != nil {
+		return nil, err
+	}
+	return i.entry(args.Identity)
+}
+
+// entry returns the current identity of the key, identities dropped since the user's last request are expired
+func (i *identities) entry(key string) (*identity, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	id, ok := i.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("%w", models.ErrOAuthIdentityExpired)
+	}
+	return id, nil
+}
+
+// setDB remembers the connection which sqlds opened for the user, so it is closed with identities
+func (i *identities) setDB(key string, db *sql.DB, maxIdleConns int) {
+	if maxIdleConns <= 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.dbs == nil {
+		i.dbs = make(map[string]identityDB)
+	}
+	i.dbs[key] = identityDB{db: db, maxIdleConns: maxIdleConns}
+}
+
+// acquire returns the identity of connection arguments, it is kept until the returned function is called
+func (i *identities) acquire(connectionArgs json.RawMessage) (*identity, func(), error) {
+	id, err := i.get(connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return id, i.acquireLocked(id), nil
+}
+
+func (i *identities) acquireLocked(id *identity) func() {
+	id.refs++
+	id.lastUsed = time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			i.mu.Lock()
+			defer i.mu.Unlock()
+			id.refs--
+			id.lastUsed = time.Now()
+		})
+	}
+}
+
+// evictLocked drops unused identities idle for the idle timeout and the least recently used ones beyond the limit
+func (i *identities) evictLocked(now time.Time) (evicted []*identity) {
+	for key, id := range i.entries {
+		if id.refs == 0 && now.Sub(id.lastUsed) > identityIdleTimeout {
+			delete(i.entries, key)
+			evicted = append(evicted, id)
+		}
+	}
+	for len(i.entries) > maxIdentities {
+		oldestKey := ""
+		for key, id := range i.entries {
+			if id.refs == 0 && (oldestKey == "" || id.lastUsed.Before(i.entries[oldestKey].lastUsed)) {
+				oldestKey = key
+			}
+		}
+		if oldestKey == "" {
+			break
+		}
+		evicted = append(evicted, i.entries[oldestKey])
+		delete(i.entries, oldestKey)
+	}
+	return evicted
+}
+
+// driver returns the YDB driver of the identity of connection arguments
+func (i *identities) driver(ctx context.Context, settings *models.Settings, connectionArgs json.RawMessage) (*ydb.Driver, error) {
+	id, err := i.get(connectionArgs)
+	if err != nil {
+		return nil, err
+	}
+	return id.ydbDriver(ctx, settings)
+}
+
+// user returns connection arguments of the user who forwarded a token with a previous request,
+// streams have no requests with the user's token. The identity is kept until the returned function is called
+func (i *identities) user(user *backend.User) (json.RawMessage, func(), error) {
+	if user == nil || user.Login == "" {
+		return nil, nil, fmt.Errorf("%w", models.ErrOAuthIdentityTokenEmpty)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	id, ok := i.entries[identityKey(user, "")]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w", models.ErrOAuthIdentityExpired)
+	}
+	return id.connectionArgs, i.acquireLocked(id), nil
+}
+
+// close closes connections of all users and identities, when the datasource is disposed
+func (i *identities) close() {
+	i.mu.Lock()
+	entries, dbs := i.entries, i.dbs
+	i.entries, i.dbs = nil, nil
+	i.mu.Unlock()
+	for _, db := range dbs {
+		db.db.Close()
+	}
+	for _, id := range entries {
+		id.close()
+	}
+}
+
+func identityKey(user *backend.User, token string) string {
+	if user != nil && user.Login != "" {
+		return "user:" + user.Login
+	}
+	hash := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(hash[:])
+}
+
+// identityConnector backs the connection of a user, connections are opened with the current identity of the user
+type identityConnector struct {
+	identities *identities
+	key        string
+	settings   *models.Settings
+}
+
+func (c identityConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	id, err := c.identities.entry(c.key)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := id.sqlConnector(ctx, c.settings)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (c identityConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c identityConnector) Open(string) (driver.Conn, error) {
+	return c.Connect(context.Background())
+}
+
+// missingIdentityConnector backs the default connection when the user's identity is forwarded,
+// since there is no identity to connect with before the first request
+type missingIdentityConnector struct{}
+
+func (c missingIdentityConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, fmt.Errorf("%w", models.ErrOAuthIdentityTokenEmpty)
+}
+
+func (c missingIdentityConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c missingIdentityConnector) Open(string) (driver.Conn, error) {
+	return c.Connect(context.Background())
+}
+
+// IdentityDatasource runs queries and health checks with the Grafana user's OAuth identity
+type IdentityDatasource struct {
+	*Datasource
+}
+
+// NewIdentityDatasource returns the datasource whose schema requests use drivers of forwarded identities
+func NewIdentityDatasource(ds *sqlds.SQLDatasource, ydb *Ydb, schema *Schema) *IdentityDatasource {
+	schema.identities = &ydb.identities
+	return &IdentityDatasource{Datasource: NewDatasource(ds, ydb, schema)}
+}
+
+// Dispose closes connections of forwarded identities
+func (ds *IdentityDatasource) Dispose() {
+	ds.Datasource.Dispose()
+	ds.ydb.identities.close()
+}
+
+// QueryData binds each query to the connection of the user who sent the request
+func (ds *IdentityDatasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	ctx = WithIdentityToken(ctx, req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName))
+	connectionArgs, release, err := ds.ydb.identities.forward(ctx, req.PluginContext.User)
+	if err != nil {
+		response := backend.NewQueryDataResponse()
+		for _, q := range req.Queries {
+			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusUnauthorized, err.Error())
+		}
+		return response, nil
+	}
+	defer release()
+	for i, q := range req.Queries {
+		q.JSON, err = setQueryField(q.JSON, "connectionArgs", connectionArgs)
+		if err != nil {
+			return nil, err
+		}
+		req.Queries[i] = q
+	}
+	return ds.Datasource.QueryData(ctx, req)
+}
+
+// CheckHealth pings the database with the identity of the user who tests the datasource
+func (ds *IdentityDatasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	ctx = WithIdentityToken(ctx, req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName))
+	connectionArgs, release, err := ds.ydb.identities.forward(ctx, req.PluginContext.User)
+	if err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: err.Error(),
+		}, nil
+	}
+	defer release()
+	db, err := ds.GetDBFromQuery(&sqlds.Query{ConnectionArgs: connectionArgs}, dataSourceUID(req.PluginContext))
+	if err == nil {
+		err = db.PingContext(ctx)
+	}
+	return healthResult(db, err), nil
+}
+
+// SubscribeStream allows subscriptions of users whose identity is known from their previous queries
+// to channels of their own identity
+func (ds *IdentityDatasource) SubscribeStream(ctx context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if !identityStreamPath(req.Path, req.PluginContext.User) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusPermissionDenied}, nil
+	}
+	_, release, err := ds.ydb.identities.user(req.PluginContext.User)
+	if err != nil {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusPermissionDenied}, nil
+	}
+	release()
+	return ds.Datasource.SubscribeStream(ctx, req)
+}
+
+// RunStream polls the query of the stream with the connection of the user, channels are per user,
+// so pollers are shared only by subscribers of the same user
+func (ds *IdentityDatasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	if !identityStreamPath(req.Path, req.PluginContext.User) {
+		return fmt.Errorf("%w: stream %q belongs to another user", ErrInvalidParameter, req.Path)
+	}
+	connectionArgs, release, err := ds.ydb.identities.user(req.PluginContext.User)
+	if err != nil {
+		return err
+	}
+	defer release()
+	var request streamRequest
+	if err := json.Unmarshal(req.Data, &request); err != nil {
+		return fmt.Errorf("%w: stream: %s", ErrInvalidParameter, err.Error())
+	}
+	if request.Query, err = setQueryField(request.Query, "connectionArgs", connectionArgs); err != nil {
+		return err
+	}
+	if req.Data, err = json.Marshal(request); err != nil {
+		return err
+	}
+	return ds.Datasource.RunStream(ctx, req, sender)
+}
+
+// identityStreamPath checks that the path of the stream is a channel of the user, like stream/<user>/<query>
+func identityStreamPath(path string, user *backend.User) bool {
+	if user == nil || user.Login == "" {
+		return false
+	}
+	rest, ok := strings.CutPrefix(path, "stream/"+identityChannel(user.Login)+"/")
+	return ok && rest != "" && !strings.Contains(rest, "/")
+}
+
+// identityChannel returns the 64-bit FNV-1a hash of UTF-16 code units of the login,
+// the same as hashString of the frontend names channels of the user
+func identityChannel(login string) string {
+	const (
+		offset64 = 0xcbf29ce484222325
+		prime64  = 0x100000001b3
+	)
+	hash := uint64(offset64)
+	for _, unit := range utf16.Encode([]rune(login)) {
+		hash ^= uint64(unit)
+		hash *= prime64
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+func dataSourceUID(pluginContext backend.PluginContext) string {
+	settings := pluginContext.DataSourceInstanceSettings
+	if settings.UID != "" {
+		return settings.UID
+	}
+	return fmt.Sprintf("%d", settings.ID)
+}
+
+// setQueryField replaces the field of the query JSON, connection arguments are replaced
+// so the frontend can't pick another identity
+func setQueryField(query json.RawMessage, name string, value json.RawMessage) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if len(query) > 0 {
+		if err := json.Unmarshal(query, &fields); err != nil {
+			return nil, err
+		}
+	}
+	fields[name] = value
+	return json.Marshal(fields)
+}
+
+func openMissingIdentityDB() *sql.DB {
+	return sql.OpenDB(missingIdentityConnector{})
+}
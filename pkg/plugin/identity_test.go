@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/sqlds/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+func TestIdentityDatasourceRequiresToken(t *testing.T) {
+	ydbDriver := &Ydb{}
+	ds := NewIdentityDatasource(sqlds.NewDatasource(ydbDriver), ydbDriver, NewSchema(backend.DataSourceInstanceSettings{}))
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"rawSql":"SELECT 1"}`)}},
+	})
+	require.NoError(t, err)
+	require.Contains(t, resp.Responses, "A")
+	assert.Equal(t, backend.StatusUnauthorized, resp.Responses["A"].Status)
+	assert.ErrorContains(t, resp.Responses["A"].Error, models.ErrOAuthIdentityTokenEmpty.Error())
+}
+
+func TestForwardOAuthIdentityRequiresPassThru(t *testing.T) {
+	_, err := models.LoadSettings(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"authKind":"ForwardOAuthIdentity","endpoint":"grpc://localhost:2136","dbLocation":"/local"}`),
+	})
+	assert.ErrorIs(t, err, models.ErrOAuthPassThruDisabled)
+}
+
+func TestIdentitiesEviction(t *testing.T) {
+	var ids identities
+	forward := func(login string) (json.RawMessage, func()) {
+		ctx := WithIdentityToken(context.Background(), "Bearer token-"+login)
+		connectionArgs, release, err := ids.forward(ctx, &backend.User{Login: login})
+		require.NoError(t, err)
+		return connectionArgs, release
+	}
+
+	idle, release := forward("idle")
+	release()
+	running, _ := forward("running")
+	ids.entries["user:idle"].lastUsed = time.Now().Add(-2 * identityIdleTimeout)
+	ids.entries["user:running"].lastUsed = time.Now().Add(-2 * identityIdleTimeout)
+
+	_, release = forward("next")
+	release()
+	_, err := ids.get(idle)
+	assert.ErrorIs(t, err, models.ErrOAuthIdentityExpired)
+	_, err = identityConnector{identities: &ids, key: "user:idle"}.Connect(context.Background())
+	assert.ErrorIs(t, err, models.ErrOAuthIdentityExpired, "connections of dropped identities are not opened")
+	_, err = ids.get(running)
+	assert.NoError(t, err, "identities of running requests are kept")
+
+	// a dropped identity comes back with the same connection arguments, so sqlds keeps one connection per user
+	again, release := forward("idle")
+	release()
+	assert.Equal(t, idle, again)
+	_, err = ids.get(again)
+	assert.NoError(t, err)
+}
+
+func TestIdentitiesLimit(t *testing.T) {
+	var ids identities
+	for i := 0; i <= maxIdentities; i++ {
+		ctx := WithIdentityToken(context.Background(), fmt.Sprintf("token-%d", i))
+		_, release, err := ids.forward(ctx, nil)
+		require.NoError(t, err)
+		release()
+	}
+	assert.Len(t, ids.entries, maxIdentities)
+}
+
+func TestIdentityChannel(t *testing.T) {
+	// the same hashes as hashString of the frontend
+	assert.Equal(t, "cbf29ce484222325", identityChannel(""))
+	assert.Equal(t, "af63dc4c8601ec8c", identityChannel("a"))
+	assert.Equal(t, "85944171f73967e8", identityChannel("foobar"))
+	assert.Equal(t, "0a759c07b6bbb215", identityChannel("ü€"))
+}
+
+func TestIdentityStreamsPerUser(t *testing.T) {
+	ydbDriver := &Ydb{}
+	ds := NewIdentityDatasource(sqlds.NewDatasource(ydbDriver), ydbDriver, NewSchema(backend.DataSourceInstanceSettings{}))
+	for _, login := range []string{"alice", "bob"} {
+		ctx := WithIdentityToken(context.Background(), "Bearer token-"+login)
+		_, release, err := ds.ydb.identities.forward(ctx, &backend.User{Login: login})
+		require.NoError(t, err)
+		release()
+	}
+	streamData := []byte(`{"query":{"refId":"A","rawSql":"SELECT 1","stream":{"column":"ts"}}}`)
+	subscribe := func(login, path string) backend.SubscribeStreamStatus {
+		resp, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: login}},
+			Path:          path,
+			Data:          streamData,
+		})
+		require.NoError(t, err)
+		return resp.Status
+	}
+
+	alicePath := "stream/" + identityChannel("alice") + "/0123456789abcdef"
+	assert.Equal(t, backend.SubscribeStreamStatusOK, subscribe("alice", alicePath))
+	assert.Equal(t, backend.SubscribeStreamStatusPermissionDenied, subscribe("bob", alicePath))
+	assert.Equal(t, backend.SubscribeStreamStatusPermissionDenied, subscribe("bob", "stream/0123456789abcdef"))
+	assert.Equal(t, backend.SubscribeStreamStatusPermissionDenied, subscribe("carol", "stream/"+identityChannel("carol")+"/0123456789abcdef"))
+
+	err := ds.RunStream(context.Background(), &backend.RunStreamRequest{
+		PluginContext: backend.PluginContext{User: &backend.User{Login: "bob"}},
+		Path:          alicePath,
+		Data:          streamData,
+	}, backend.NewStreamSender(&packets{}))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
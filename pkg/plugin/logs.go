@@ -0,0 +1,235 @@
+package plugin
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Names of fields of the logs frame expected by Grafana
+const (
+	logTimestampField = "timestamp"
+	logBodyField      = "body"
+	logSeverityField  = "severity"
+	logIDField        = "id"
+)
+
+// builderLogLevelField and builderLogLineField are aliases of log columns selected by builder queries
+const (
+	builderLogLevelField = "level"
+	builderLogLineField  = "logLine"
+)
+
+// Columns of SQL queries used as the body and the level of logs, when the query doesn't choose them
+var (
+	logBodyColumns  = []string{"body", "logline", "message", "msg"}
+	logLevelColumns = []string{"level", "severity", "loglevel"}
+)
+
+// Grafana log levels
+const (
+	logLevelCritical = "critical"
+	logLevelError    = "error"
+	logLevelWarning  = "warning"
+	logLevelInfo     = "info"
+	logLevelDebug    = "debug"
+	logLevelTrace    = "trace"
+	logLevelUnknown  = "unknown"
+)
+
+// numericLogLevels are Grafana levels of YDB log priorities, from EMERG to TRACE
+var numericLogLevels = []string{
+	logLevelCritical, logLevelCritical, logLevelCritical, logLevelError, logLevelWarning,
+	logLevelInfo, logLevelInfo, logLevelDebug, logLevelTrace,
+}
+
+var logLevels = map[string]string{
+	"emerg": logLevelCritical, "emergency": logLevelCritical, "alert": logLevelCritical, "crit": logLevelCritical,
+	"critical": logLevelCritical, "fatal": logLevelCritical, "panic": logLevelCritical,
+	"err": logLevelError, "error": logLevelError, "eror": logLevelError,
+	"warn": logLevelWarning, "warning": logLevelWarning,
+	"notice": logLevelInfo, "info": logLevelInfo, "information": logLevelInfo,
+	"debug": logLevelDebug, "dbug": logLevelDebug, "dbg": logLevelDebug,
+	"trace": logLevelTrace,
+}
+
+// logColumns are columns chosen for the time, the level and the body of logs
+type logColumns struct {
+	time  string
+	level string
+	body  string
+}
+
+// logColumns returns columns chosen by the builder query, SQL queries choose them by names
+func (q queryModel) logColumns() (columns logColumns) {
+	if q.QueryType != queryTypeBuilder {
+		return columns
+	}
+	if q.BuilderOptions.LogTimeField != nil {
+		columns.time = q.BuilderOptions.LogTimeField.Name
+	}
+	if q.BuilderOptions.LogLevelField != "" {
+		columns.level = builderLogLevelField
+	}
+	if len(q.BuilderOptions.LoglineFields) > 0 {
+		columns.body = builderLogLineField
+	}
+	return columns
+}
+
+// logsFrame maps the time, the level and the body of logs to the fields expected by Grafana,
+// normalizes levels and adds ids of rows, other fields are kept. Rows without time are dropped.
+func logsFrame(frame *data.Frame, columns logColumns) *data.Frame {
+	timeIndex := logFieldIndex(frame, []string{columns.time}, data.FieldTypeTime, data.FieldTypeNullableTime)
+	if timeIndex < 0 {
+		timeIndex = logFieldIndex(frame, nil, data.FieldTypeTime, data.FieldTypeNullableTime)
+	}
+	if timeIndex < 0 {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.PreferredVisualization = data.VisTypeLogs
+		return frame
+	}
+	levelIndex := logFieldIndex(frame, append([]string{columns.level}, logLevelColumns...))
+	bodyIndex := logFieldIndex(frame, append([]string{columns.body}, logBodyColumns...), data.FieldTypeString, data.FieldTypeNullableString)
+	if bodyIndex < 0 {
+		for i, field := range frame.Fields {
+			if i != levelIndex && (field.Type() == data.FieldTypeString || field.Type() == data.FieldTypeNullableString) {
+				bodyIndex = i
+				break
+			}
+		}
+	}
+	var rest []int
+	for i := range frame.Fields {
+		if i != timeIndex && i != levelIndex && i != bodyIndex {
+			rest = append(rest, i)
+		}
+	}
+
+	timestamps := data.NewField(logTimestampField, nil, []time.Time{})
+	bodies := data.NewField(logBodyField, nil, []string{})
+	severities := data.NewField(logSeverityField, nil, []string{})
+	ids := data.NewField(logIDField, nil, []string{})
+	fields := data.Fields{timestamps, bodies}
+	if levelIndex >= 0 {
+		fields = append(fields, severities)
+	}
+	fields = append(fields, ids)
+	restFields := make(data.Fields, len(rest))
+	for i, index := range rest {
+		field := frame.Fields[index]
+		restFields[i] = data.NewFieldFromFieldType(field.Type(), 0)
+		restFields[i].Name = field.Name
+		restFields[i].Labels = field.Labels.Copy()
+		restFields[i].Config = field.Config
+	}
+
+	seen := map[string]int{}
+	for row := 0; row < frame.Fields[timeIndex].Len(); row++ {
+		value, ok := frame.Fields[timeIndex].ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		timestamp := value.(time.Time)
+		body := logBody(frame, row, bodyIndex, rest)
+		severity := ""
+		if levelIndex >= 0 {
+			severity = normalizeLogLevel(fieldString(frame.Fields[levelIndex], row))
+		}
+		restValues := make([]string, len(rest))
+		for i, index := range rest {
+			restFields[i].Append(frame.Fields[index].CopyAt(row))
+			restValues[i] = fieldString(frame.Fields[index], row)
+		}
+		id := logID(timestamp, body, severity, restValues)
+		if n := seen[id]; n > 0 {
+			seen[id]++
+			id = fmt.Sprintf("%s_%d", id, n)
+		} else {
+			seen[id] = 1
+		}
+
+		timestamps.Append(timestamp)
+		bodies.Append(body)
+		severities.Append(severity)
+		ids.Append(id)
+	}
+
+	logs := data.NewFrame(frame.Name, append(fields, restFields...)...)
+	logs.RefID = frame.RefID
+	logs.Meta = frame.Meta
+	if logs.Meta == nil {
+		logs.Meta = &data.FrameMeta{}
+	}
+	logs.Meta.Type = data.FrameTypeLogLines
+	logs.Meta.TypeVersion = data.FrameTypeVersion{0, 0}
+	logs.Meta.PreferredVisualization = data.VisTypeLogs
+	return logs
+}
+
+// logFieldIndex returns the index of the first field of the names, matched case-insensitively,
+// or of the first field of the types when names are empty
+func logFieldIndex(frame *data.Frame, names []string, types ...data.FieldType) int {
+	matches := func(field *data.Field) bool {
+		return len(types) == 0 || slices.Contains(types, field.Type())
+	}
+	if len(names) == 0 {
+		return slices.IndexFunc(frame.Fields, matches)
+	}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		index := slices.IndexFunc(frame.Fields, func(field *data.Field) bool {
+			return strings.EqualFold(field.Name, name) && matches(field)
+		})
+		if index >= 0 {
+			return index
+		}
+	}
+	return -1
+}
+
+// logBody returns the body field or, without it, name=value pairs of other fields
+func logBody(frame *data.Frame, row int, bodyIndex int, rest []int) string {
+	if bodyIndex >= 0 {
+		return fieldString(frame.Fields[bodyIndex], row)
+	}
+	pairs := make([]string, len(rest))
+	for i, index := range rest {
+		pairs[i] = frame.Fields[index].Name + "=" + fieldString(frame.Fields[index], row)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// normalizeLogLevel maps names of levels in any case and YDB log priorities to Grafana levels
+func normalizeLogLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if n, err := strconv.Atoi(level); err == nil {
+		if n < 0 || n >= len(numericLogLevels) {
+			return logLevelUnknown
+		}
+		return numericLogLevels[n]
+	}
+	if normalized, ok := logLevels[level]; ok {
+		return normalized
+	}
+	return logLevelUnknown
+}
+
+// logID hashes the row, so ids stay the same when logs are queried again
+func logID(timestamp time.Time, body string, severity string, values []string) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d\x00%s\x00%s", timestamp.UnixNano(), body, severity)
+	for _, value := range values {
+		fmt.Fprintf(h, "\x00%s", value)
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsFrame(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	frame := data.NewFrame("A",
+		data.NewField("host", nil, []string{"a", "a", "b", "a"}),
+		data.NewField("ts", nil, []*time.Time{&t0, &t0, nil, &t0}),
+		data.NewField("message", nil, []string{"started", "started", "lost", "stopped"}),
+		data.NewField("Level", nil, []int32{6, 6, 3, 4}),
+	)
+
+	logs := logsFrame(frame, logColumns{})
+	assert.Equal(t, data.FrameTypeLogLines, logs.Meta.Type)
+	assert.Equal(t, data.VisType(data.VisTypeLogs), logs.Meta.PreferredVisualization)
+	require.Equal(t, 3, logs.Rows())
+	assert.Equal(t, []string{"timestamp", "body", "severity", "id", "host"}, fieldNames(logs))
+	assert.Equal(t, []any{"started", "started", "stopped"}, concreteValues(logs.Fields[1]))
+	assert.Equal(t, []any{"info", "info", "warning"}, concreteValues(logs.Fields[2]))
+
+	ids := concreteValues(logs.Fields[3])
+	assert.Equal(t, ids[0].(string)+"_1", ids[1])
+	assert.NotEqual(t, ids[0], ids[2])
+	assert.Equal(t, ids, concreteValues(logsFrame(frame, logColumns{}).Fields[3]))
+}
+
+func TestLogsFrameBuilderColumns(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	frame := data.NewFrame("A",
+		data.NewField("created", nil, []time.Time{t0}),
+		data.NewField("updated", nil, []time.Time{t0.Add(time.Hour)}),
+		data.NewField("code", nil, []int64{500}),
+	)
+	query, err := parseQueryModel([]byte(`{"version":1,"queryType":"builder","queryFormat":"logs",
+		"builderOptions":{"table":"logs","logTimeField":{"name":"updated"}}}`))
+	require.NoError(t, err)
+
+	logs := logsFrame(frame, query.logColumns())
+	assert.Equal(t, t0.Add(time.Hour), logs.Fields[0].At(0))
+	assert.Equal(t, "created="+t0.String()+", code=500", logs.Fields[1].At(0))
+	assert.Equal(t, "id", logs.Fields[2].Name)
+}
+
+func TestNormalizeLogLevel(t *testing.T) {
+	for level, expected := range map[string]string{
+		"0":       "critical",
+		"3":       "error",
+		"8":       "trace",
+		"9":       "unknown",
+		" WARN ":  "warning",
+		"Notice":  "info",
+		"FATAL":   "critical",
+		"verbose": "unknown",
+	} {
+		assert.Equal(t, expected, normalizeLogLevel(level), level)
+	}
+}
+
+func TestParseQueryModelLogContext(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"version":1,"queryType":"builder","queryFormat":"logs",
+		"builderOptions":{"table":"logs","logTimeField":{"name":"ts"}},"logContext":{"timeNs":"1","limit":5000}}`))
+	require.NoError(t, err)
+	assert.Equal(t, maxLogContextRows, query.LogContext.Limit)
+}
@@ -0,0 +1,438 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/sqlds/v2"
+	"github.com/ydb-platform/ydb-go-sdk/v3/table"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+// queryModelVersion is the version of queries saved by the current frontend, queries
+// of older versions are migrated on load
+const queryModelVersion = 1
+
+const (
+	queryTypeSQL     = "sql"
+	queryTypeBuilder = "builder"
+)
+
+const (
+	QueryFormatTable      = "table"
+	QueryFormatTimeSeries = "timeseries"
+	QueryFormatLogs       = "logs"
+	QueryFormatTraces     = "traces"
+)
+
+// Query modes select the transaction of the query or return its plan
+const (
+	QueryModeSerializable     = "serializable"
+	QueryModeSnapshotReadOnly = "snapshotReadOnly"
+	QueryModeOnlineReadOnly   = "onlineReadOnly"
+	QueryModeStaleReadOnly    = "staleReadOnly"
+	QueryModeExplain          = "explain"
+)
+
+// Rows of the log context read before or after the log row
+const (
+	defaultLogContextRows = 10
+	maxLogContextRows     = 1000
+)
+
+// Fill modes of missing values in time series
+const (
+	FillModeNull     = "null"
+	FillModePrevious = "previous"
+	FillModeValue    = "value"
+)
+
+var (
+	fillModes    = []string{FillModeNull, FillModePrevious, FillModeValue}
+	queryFormats = []string{QueryFormatTable, QueryFormatTimeSeries, QueryFormatLogs, QueryFormatTraces}
+	queryModes   = []string{QueryModeSerializable, QueryModeSnapshotReadOnly, QueryModeOnlineReadOnly, QueryModeStaleReadOnly, QueryModeExplain}
+)
+
+// sqldsFormats are formats of frames built by sqlds for the query formats,
+// time series are returned by sqlds as tables and converted by formatResponses
+var sqldsFormats = map[string]sqlds.FormatQueryOption{
+	QueryFormatTable:      sqlds.FormatOptionTable,
+	QueryFormatTimeSeries: sqlds.FormatOptionTable,
+	QueryFormatLogs:       sqlds.FormatOptionLogs,
+	QueryFormatTraces:     sqlds.FormatOptionTrace,
+}
+
+// legacyFormats are query formats of sqlds formats saved by frontends of version 0
+var legacyFormats = map[sqlds.FormatQueryOption]string{
+	sqlds.FormatOptionTimeSeries: QueryFormatTimeSeries,
+	sqlds.FormatOptionTable:      QueryFormatTable,
+	sqlds.FormatOptionLogs:       QueryFormatLogs,
+	sqlds.FormatOptionTrace:      QueryFormatTraces,
+}
+
+type queryOptions struct {
+	// RowLimit truncates frames of the query, zero means no limit
+	RowLimit int    `json:"rowLimit,omitempty"`
+	Timeout  string `json:"timeout,omitempty"`
+	// FillMode fills values missing in some of time series, null by default
+	FillMode  string  `json:"fillMode,omitempty"`
+	FillValue float64 `json:"fillValue,omitempty"`
+}
+
+type queryModel struct {
+	Version     int          `json:"version"`
+	RawSql      string       `json:"rawSql"`
+	QueryType   string       `json:"queryType,omitempty"`
+	QueryFormat string       `json:"queryFormat,omitempty"`
+	Mode        string       `json:"mode,omitempty"`
+	Options     queryOptions `json:"options"`
+	// builder queries saved without rawSql, e.g. by provisioning, are compiled by the backend
+	BuilderOptions builder.Options `json:"builderOptions"`
+	// VariableQuery returns __text and __value pairs of a template variable
+	VariableQuery bool                     `json:"variableQuery,omitempty"`
+	VariableLimit int                      `json:"variableLimit,omitempty"`
+	Variables     map[string]VariableValue `json:"variables,omitempty"`
+	// Traces reads spans of a trace or searches traces in a table of spans
+	Traces *builder.TraceOptions `json:"traces,omitempty"`
+	// LogContext reads rows around a log row of the builder logs query
+	LogContext *builder.LogContext `json:"logContext,omitempty"`
+	// SupplementaryQueryType derives the supplementary query of Explore from the builder query
+	SupplementaryQueryType string `json:"supplementaryQueryType,omitempty"`
+	// Annotation converts rows of the query to annotations of dashboards
+	Annotation bool `json:"annotation,omitempty"`
+	// Stream polls new rows of the query for live streams
+	Stream *builder.StreamOptions `json:"stream,omitempty"`
+	// System reads a system view of the catalog
+	System *builder.SystemOptions `json:"system,omitempty"`
+	// Topic streams messages of a topic or, in topic lag queries, selects the topic and the consumer
+	Topic *topicOptions `json:"topic,omitempty"`
+
+	// Format is the sqlds format which frontends of version 0 computed from the query format
+	Format *sqlds.FormatQueryOption `json:"format,omitempty"`
+	// Timeout is the query timeout of version 0
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// parseQueryModel reads the query, migrates it to the current version and validates it
+func parseQueryModel(raw json.RawMessage) (query queryModel, _ error) {
+	if err := json.Unmarshal(raw, &query); err != nil {
+		return query, fmt.Errorf("%w: query: %s", ErrInvalidParameter, err.Error())
+	}
+	query.migrate()
+	if query.QueryType == queryTypeTraces {
+		if err := query.validateTraces(); err != nil {
+			return query, err
+		}
+	}
+	if query.QueryType == queryTypeTopic {
+		if err := query.validateTopic(); err != nil {
+			return query, err
+		}
+	}
+	if query.QueryType == queryTypeSystem {
+		if err := query.validateSystem(); err != nil {
+			return query, err
+		}
+	}
+	if query.QueryType == queryTypeTopicLag {
+		if err := query.validateTopicLag(); err != nil {
+			return query, err
+		}
+	}
+	if !slices.Contains(queryFormats, query.QueryFormat) {
+		return query, fmt.Errorf("%w: queryFormat %q", ErrInvalidParameter, query.QueryFormat)
+	}
+	if query.Mode != "" && !slices.Contains(queryModes, query.Mode) {
+		return query, fmt.Errorf("%w: mode %q", ErrInvalidParameter, query.Mode)
+	}
+	if query.Options.FillMode != "" && !slices.Contains(fillModes, query.Options.FillMode) {
+		return query, fmt.Errorf("%w: fillMode %q", ErrInvalidParameter, query.Options.FillMode)
+	}
+	if query.SupplementaryQueryType != "" {
+		if err := query.validateSupplementary(); err != nil {
+			return query, err
+		}
+	}
+	if query.LogContext != nil {
+		if err := query.validateLogContext(); err != nil {
+			return query, err
+		}
+	}
+	if query.Stream != nil {
+		if err := query.validateStream(); err != nil {
+			return query, err
+		}
+	}
+	if query.Options.RowLimit < 0 {
+		return query, fmt.Errorf("%w: rowLimit %d", ErrInvalidParameter, query.Options.RowLimit)
+	}
+	if !models.TimeoutUnset(query.Options.Timeout) {
+		if _, err := models.ParseTimeout(query.Options.Timeout); err != nil {
+			return query, fmt.Errorf("%w: %s", ErrInvalidParameter, err.Error())
+		}
+	}
+	return query, nil
+}
+
+// validateSupplementary checks that the supplementary query can be derived from the query
+func (q queryModel) validateSupplementary() error {
+	if q.SupplementaryQueryType != SupplementaryQueryLogsVolume {
+		return fmt.Errorf("%w: supplementaryQueryType %q", ErrInvalidParameter, q.SupplementaryQueryType)
+	}
+	if q.QueryType != queryTypeBuilder || q.BuilderOptions.Table == "" ||
+		q.BuilderOptions.LogTimeField == nil || q.BuilderOptions.LogTimeField.Name == "" {
+		return fmt.Errorf("%w: log volume needs a builder query with a table and a log time field", ErrInvalidParameter)
+	}
+	return nil
+}
+
+// validateLogContext checks the log context and limits its rows
+func (q *queryModel) validateLogContext() error {
+	if q.QueryType != queryTypeBuilder || q.BuilderOptions.Table == "" ||
+		q.BuilderOptions.LogTimeField == nil || q.BuilderOptions.LogTimeField.Name == "" {
+		return fmt.Errorf("%w: log context needs a builder query with a table and a log time field", ErrInvalidParameter)
+	}
+	switch q.LogContext.Direction {
+	case "", builder.LogContextBackward, builder.LogContextForward:
+	default:
+		return fmt.Errorf("%w: log context direction %q", ErrInvalidParameter, q.LogContext.Direction)
+	}
+	if q.LogContext.Limit < 0 {
+		return fmt.Errorf("%w: log context limit %d", ErrInvalidParameter, q.LogContext.Limit)
+	}
+	if q.LogContext.Limit == 0 {
+		q.LogContext.Limit = defaultLogContextRows
+	}
+	q.LogContext.Limit = min(q.LogContext.Limit, maxLogContextRows)
+	return nil
+}
+
+func (q *queryModel) migrate() {
+	if q.Version < 1 {
+		if q.Options.Timeout == "" {
+			q.Options.Timeout = q.Timeout
+		}
+		if q.QueryFormat == "" && q.Format != nil {
+			q.QueryFormat = legacyFormats[*q.Format]
+		}
+		if q.QueryType == "" {
+			q.QueryType = queryTypeSQL
+			if q.RawSql == "" && q.BuilderOptions.Table != "" {
+				q.QueryType = queryTypeBuilder
+			}
+		}
+	}
+	q.Version = queryModelVersion
+	if q.QueryFormat == "" {
+		q.QueryFormat = QueryFormatTable
+	}
+}
+
+// sqldsFormat returns the format sqlds builds frames in
+func (q queryModel) sqldsFormat() sqlds.FormatQueryOption {
+	if q.SupplementaryQueryType != "" {
+		return sqlds.FormatOptionTable
+	}
+	return sqldsFormats[q.QueryFormat]
+}
+
+// fillMissing returns the fill mode of time series
+func (q queryModel) fillMissing() *data.FillMissing {
+	switch q.Options.FillMode {
+	case FillModePrevious:
+		return &data.FillMissing{Mode: data.FillModePrevious}
+	case FillModeValue:
+		return &data.FillMissing{Mode: data.FillModeValue, Value: q.Options.FillValue}
+	default:
+		return &data.FillMissing{Mode: data.FillModeNull}
+	}
+}
+
+// durationUnit returns the unit of durations of spans
+func (q queryModel) durationUnit() time.Duration {
+	if q.Traces == nil {
+		return time.Millisecond
+	}
+	unit, _ := q.Traces.DurationUnitLength()
+	return unit
+}
+
+// timeout returns the timeout of the query capped by the datasource query timeout, zero when the query
+// has no timeout. Timeouts of queries are validated when queries are parsed
+func (q queryModel) timeout(max time.Duration) time.Duration {
+	if models.TimeoutUnset(q.Options.Timeout) {
+		return 0
+	}
+	timeout, err := models.ParseTimeout(q.Options.Timeout)
+	if err != nil {
+		return 0
+	}
+	if max > 0 && timeout > max {
+		timeout = max
+	}
+	return timeout
+}
+
+// txControl returns the transaction of the query mode, nil for the default transaction
+func (q queryModel) txControl() *table.TransactionControl {
+	var txSettings table.TxOption
+	switch q.Mode {
+	case QueryModeSerializable:
+		txSettings = table.WithSerializableReadWrite()
+	case QueryModeSnapshotReadOnly:
+		txSettings = table.WithSnapshotReadOnly()
+	case QueryModeOnlineReadOnly:
+		txSettings = table.WithOnlineReadOnly()
+	case QueryModeStaleReadOnly:
+		txSettings = table.WithStaleReadOnly()
+	default:
+		return nil
+	}
+	return table.TxControl(table.BeginTx(txSettings), table.CommitTx())
+}
+
+// queryModels are models of valid queries of a request by their refIDs, queries are parsed once per request
+type queryModels map[string]queryModel
+
+type queryModelsKey struct{}
+
+// withQueryModels passes models of the request to MutateQuery, which sqlds calls with the query only
+func withQueryModels(ctx context.Context, models queryModels) context.Context {
+	return context.WithValue(ctx, queryModelsKey{}, models)
+}
+
+// queryModelFromContext returns the model of the query parsed by QueryData or parses the query
+func queryModelFromContext(ctx context.Context, q backend.DataQuery) (queryModel, error) {
+	if models, ok := ctx.Value(queryModelsKey{}).(queryModels); ok {
+		if query, ok := models[q.RefID]; ok {
+			return query, nil
+		}
+	}
+	return parseQueryModel(q.JSON)
+}
+
+// validQueries returns the request without queries failing validation, error responses for them
+// and models of valid queries
+func validQueries(req *backend.QueryDataRequest) (*backend.QueryDataRequest, backend.Responses, queryModels) {
+	invalid := backend.Responses{}
+	models := queryModels{}
+	queries := make([]backend.DataQuery, 0, len(req.Queries))
+	for _, q := range req.Queries {
+		query, err := parseQueryModel(q.JSON)
+		if err == nil && query.QueryType == queryTypeTopic {
+			err = fmt.Errorf("%w: topic queries are streamed over Grafana Live", ErrInvalidParameter)
+		}
+		if err != nil {
+			invalid[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+			continue
+		}
+		models[q.RefID] = query
+		queries = append(queries, q)
+	}
+	if len(invalid) == 0 {
+		return req, invalid, models
+	}
+	valid := *req
+	valid.Queries = queries
+	return &valid, invalid, models
+}
+
+// limitResponses truncates frames of queries with a row limit, the limit of a single statement query
+// is applied by the database which returns one row more to detect truncation
+func limitResponses(models queryModels, resp *backend.QueryDataResponse) {
+	for refID, query := range models {
+		if query.Options.RowLimit == 0 {
+			continue
+		}
+		response, ok := resp.Responses[refID]
+		if !ok {
+			continue
+		}
+		for _, frame := range response.Frames {
+			limitRows(frame, query.Options.RowLimit)
+		}
+	}
+}
+
+// limitRows removes rows of the frame after the limit and warns about it
+func limitRows(frame *data.Frame, limit int) {
+	rows, _ := frame.RowLen()
+	if rows <= limit {
+		return
+	}
+	for _, field := range frame.Fields {
+		for i := field.Len() - 1; i >= limit; i-- {
+			field.Delete(i)
+		}
+	}
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("Rows are limited to %d", limit),
+	})
+}
+
+// formatResponses converts frames of queries to their query format, variable queries are left as tables
+func formatResponses(req *backend.QueryDataRequest, models queryModels, resp *backend.QueryDataResponse) {
+	for _, q := range req.Queries {
+		query, ok := models[q.RefID]
+		if !ok || query.VariableQuery || query.QueryType == queryTypeTopicLag {
+			continue
+		}
+		response, ok := resp.Responses[q.RefID]
+		if !ok || response.Error != nil {
+			continue
+		}
+		if query.SupplementaryQueryType == SupplementaryQueryLogsVolume {
+			response.Frames = logsVolumeFrames(response.Frames, q.TimeRange)
+			resp.Responses[q.RefID] = response
+			continue
+		}
+		for i, frame := range response.Frames {
+			var err error
+			if query.Annotation {
+				if frame, err = annotationFrame(frame); err != nil {
+					response = backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("annotations: %s", err.Error()))
+					break
+				}
+				response.Frames[i] = frame
+				continue
+			}
+			if query.QueryType == queryTypeTraces && query.Traces.Mode == builder.TraceModeSearch {
+				response.Frames[i] = traceSearchFrame(frame, query, req.PluginContext.DataSourceInstanceSettings)
+				continue
+			}
+			if query.QueryType == queryTypeSystem {
+				frame, err = systemFrame(frame, query)
+				if err != nil {
+					response = backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("system: %s", err.Error()))
+					break
+				}
+				response.Frames[i] = frame
+				continue
+			}
+			switch query.QueryFormat {
+			case QueryFormatTimeSeries:
+				frame, err = timeSeriesFrame(frame, query.fillMissing())
+			case QueryFormatLogs:
+				frame = logsFrame(frame, query.logColumns())
+			case QueryFormatTraces:
+				frame, err = traceFrame(frame, query.durationUnit())
+			default:
+				continue
+			}
+			if err != nil {
+				response = backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("%s: %s", query.QueryFormat, err.Error()))
+				break
+			}
+			response.Frames[i] = frame
+		}
+		resp.Responses[q.RefID] = response
+	}
+}
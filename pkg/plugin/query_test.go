@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/sqlds/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryModelMigratesVersion0(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"rawSql":"SELECT 1","format":0,"timeout":"10s"}`))
+	require.NoError(t, err)
+	assert.Equal(t, queryModelVersion, query.Version)
+	assert.Equal(t, QueryFormatTimeSeries, query.QueryFormat)
+	assert.Equal(t, queryTypeSQL, query.QueryType)
+	assert.Equal(t, "10s", query.Options.Timeout)
+
+	query, err = parseQueryModel([]byte(`{"rawSql":"","builderOptions":{"table":"logs"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, QueryFormatTable, query.QueryFormat)
+	assert.Equal(t, queryTypeBuilder, query.QueryType)
+
+	query, err = parseQueryModel([]byte(`{"version":1,"rawSql":"SELECT 1","queryFormat":"logs","timeout":"10s"}`))
+	require.NoError(t, err)
+	assert.Equal(t, QueryFormatLogs, query.QueryFormat)
+	assert.Empty(t, query.Options.Timeout)
+}
+
+func TestParseQueryModel(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		raw   string
+		valid bool
+	}{
+		{"system view with window", `{"version":1,"queryType":"system","queryFormat":"timeseries","system":{"view":"top_partitions","window":"hour"}}`, true},
+		{"topic lag", `{"version":1,"queryType":"topicLag","queryFormat":"timeseries","topic":{"path":"events"}}`, true},
+		{"unknown query format", `{"version":1,"queryFormat":"graph"}`, false},
+		{"unknown mode", `{"version":1,"mode":"readUncommitted"}`, false},
+		{"negative row limit", `{"version":1,"options":{"rowLimit":-1}}`, false},
+		{"sub-second timeout", `{"version":1,"options":{"timeout":"500ms"}}`, false},
+		{"invalid timeout", `{"version":1,"options":{"timeout":"soon"}}`, false},
+		{"zero timeout", `{"version":1,"options":{"timeout":"0"}}`, true},
+		{"non-string rawSql", `{"rawSql":1}`, false},
+		{"traces without options", `{"version":1,"queryType":"traces"}`, false},
+		{"traces without trace id", `{"version":1,"queryType":"traces","traces":{"table":"spans","columns":{"traceId":"trace_id","startTime":"start"}}}`, false},
+		{"traces with unknown duration unit", `{"version":1,"queryType":"traces","traces":{"table":"spans","mode":"search","durationUnit":"h","columns":{"traceId":"trace_id","startTime":"start"}}}`, false},
+		{"log context of sql query", `{"version":1,"queryType":"sql","logContext":{"timeNs":"1"}}`, false},
+		{"log context with unknown direction", `{"version":1,"queryType":"builder","builderOptions":{"table":"logs","logTimeField":{"name":"ts"}},"logContext":{"direction":"up"}}`, false},
+		{"stream without column", `{"version":1,"rawSql":"SELECT 1","stream":{}}`, false},
+		{"stream with too short interval", `{"version":1,"rawSql":"SELECT 1","stream":{"column":"ts","interval":"100ms"}}`, false},
+		{"stream of variable query", `{"version":1,"rawSql":"SELECT 1","variableQuery":true,"stream":{"column":"ts"}}`, false},
+		{"topic without path", `{"version":1,"queryType":"topic"}`, false},
+		{"topic with unknown parser", `{"version":1,"queryType":"topic","topic":{"path":"events","parser":"csv"}}`, false},
+		{"topic with invalid start", `{"version":1,"queryType":"topic","topic":{"path":"events","startFrom":"yesterday"}}`, false},
+		{"topic as time series", `{"version":1,"queryType":"topic","queryFormat":"timeseries","topic":{"path":"events"}}`, false},
+		{"changefeed without table", `{"version":1,"queryType":"topic","topic":{"changefeed":"updates"}}`, false},
+		{"changefeed with json parser", `{"version":1,"queryType":"topic","topic":{"table":"orders","changefeed":"updates","parser":"json"}}`, false},
+		{"cdc parser without changefeed", `{"version":1,"queryType":"topic","topic":{"path":"events","parser":"cdc"}}`, false},
+		{"system without view", `{"version":1,"queryType":"system"}`, false},
+		{"unknown system view", `{"version":1,"queryType":"system","system":{"view":"users"}}`, false},
+		{"system view without windows", `{"version":1,"queryType":"system","system":{"view":"query_metrics","window":"hour"}}`, false},
+		{"system view as logs", `{"version":1,"queryType":"system","queryFormat":"logs","system":{"view":"nodes"}}`, false},
+		{"topic lag without topic", `{"version":1,"queryType":"topicLag","queryFormat":"timeseries"}`, false},
+		{"topic lag as logs", `{"version":1,"queryType":"topicLag","queryFormat":"logs","topic":{"path":"events"}}`, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseQueryModel([]byte(tc.raw))
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidParameter)
+			}
+		})
+	}
+}
+
+func TestMutateQuerySetsFormatAndMode(t *testing.T) {
+	ydb := &Ydb{}
+	_, query := ydb.MutateQuery(context.Background(), backend.DataQuery{JSON: []byte(
+		`{"version":1,"rawSql":"SELECT 1","queryFormat":"traces","mode":"snapshotReadOnly"}`,
+	)})
+	var sqlQuery sqlds.Query
+	require.NoError(t, json.Unmarshal(query.JSON, &sqlQuery))
+	assert.Equal(t, sqlds.FormatOptionTrace, sqlQuery.Format)
+
+	model, err := parseQueryModel(query.JSON)
+	require.NoError(t, err)
+	assert.NotNil(t, model.txControl())
+}
+
+func TestMutateQueryLimitsRows(t *testing.T) {
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{
+		RefID: "A",
+		JSON:  []byte(`{"version":1,"rawSql":"SELECT * FROM logs","queryFormat":"table","options":{"rowLimit":100}}`),
+	}}}
+	_, _, models := validQueries(req)
+	// the model parsed by QueryData is used instead of the query JSON
+	q := backend.DataQuery{RefID: "A", JSON: []byte(`{}`)}
+	_, q = (&Ydb{}).MutateQuery(withQueryModels(context.Background(), models), q)
+	var sqlQuery sqlds.Query
+	require.NoError(t, json.Unmarshal(q.JSON, &sqlQuery))
+	assert.Equal(t, "SELECT * FROM logs\nLIMIT 101", sqlQuery.RawSQL)
+}
+
+func TestValidQueries(t *testing.T) {
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		{RefID: "A", JSON: []byte(`{"rawSql":"SELECT 1"}`)},
+		{RefID: "B", JSON: []byte(`{"version":1,"rawSql":"SELECT 1","mode":"dirty"}`)},
+	}}
+	valid, invalid, models := validQueries(req)
+	assert.Len(t, valid.Queries, 1)
+	assert.Contains(t, models, "A")
+	assert.NotContains(t, models, "B")
+	assert.Equal(t, "A", valid.Queries[0].RefID)
+	assert.Len(t, req.Queries, 2)
+	assert.Equal(t, backend.StatusBadRequest, invalid["B"].Status)
+}
+
+func TestLimitRows(t *testing.T) {
+	frame := data.NewFrame("", data.NewField("n", nil, []int64{1, 2, 3, 4}))
+	limitRows(frame, 2)
+	assert.Equal(t, 2, frame.Rows())
+	assert.Equal(t, int64(2), frame.Fields[0].At(1))
+	assert.Len(t, frame.Meta.Notices, 1)
+
+	limitRows(frame, 2)
+	assert.Len(t, frame.Meta.Notices, 1)
+}
@@ -0,0 +1,518 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/ydb-platform/ydb-go-genproto/Ydb_Scheme_V1"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+const entriesKey = "entries"
+
+// describeTableWorkers limits concurrent DescribeTable calls of one request
+const describeTableWorkers = 8
+
+type schemaEntry struct {
+	value    interface{}
+	loadedAt time.Time
+	// usedAt is the last time the entry was requested, unused entries are not refreshed
+	usedAt time.Time
+}
+
+// Schema serves scheme entries, tables and their fields of a datasource instance.
+// Entries are cached for the schema cache TTL and refreshed in background while they are used,
+// an expired entry is still served until its refresh completes.
+type Schema struct {
+	settings *models.Settings
+	err      error
+
+	mu      sync.Mutex
+	entries map[string]schemaEntry
+	// generation changes when cached entries are dropped, so loads started before aren't stored
+	generation uint64
+	loads      singleflight.Group
+
+	driverMu sync.Mutex
+	driver   *ydb.Driver
+	// identities keep drivers of forwarded identities of users
+	identities *identities
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewSchema(config backend.DataSourceInstanceSettings) *Schema {
+	settings, err := models.LoadSettings(config)
+	s := &Schema{
+		settings: settings,
+		err:      err,
+		entries:  make(map[string]schemaEntry),
+		done:     make(chan struct{}),
+	}
+	if s.cached() {
+		go s.refreshLoop()
+	}
+	return s
+}
+
+// cached reports whether the schema is cached. The schema of the forwarded user's identity
+// depends on the user's access rights, so it is always loaded with the user's token.
+func (s *Schema) cached() bool {
+	return s.err == nil && s.settings.SchemaCacheTTLDuration > 0 && s.settings.AuthKind != "ForwardOAuthIdentity"
+}
+
+func (s *Schema) RetrieveListTables(ctx context.Context, query TablesQuery) (respData []byte, err error) {
+	defer func() {
+		if err != nil {
+			log.DefaultLogger.Error("Getting table list failed", "error", err.Error())
+		}
+	}()
+
+	page, err := s.ListTables(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if query.Paginated() {
+		return json.Marshal(page)
+	}
+	return json.Marshal(page.Tables)
+}
+
+func (s *Schema) RetrieveListEntries(ctx context.Context, query TablesQuery) (respData []byte, err error) {
+	defer func() {
+		if err != nil {
+			log.DefaultLogger.Error("Getting entry list failed", "error", err.Error())
+		}
+	}()
+
+	page, err := s.ListEntries(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if query.Paginated() {
+		return json.Marshal(page)
+	}
+	return json.Marshal(page.Entries)
+}
+
+// RetrieveTableFields returns fields of the tables by their paths resolved relative to the database root
+func (s *Schema) RetrieveTableFields(ctx context.Context, tableNames []string) (respData []byte, err error) {
+	defer func() {
+		if err != nil {
+			log.DefaultLogger.Error("Getting fields failed", "error", err.Error())
+		}
+	}()
+
+	if len(tableNames) == 0 {
+		return nil, fmt.Errorf("%w: table is required", ErrInvalidParameter)
+	}
+	paths := make([]string, len(tableNames))
+	for i, tableName := range tableNames {
+		if paths[i], err = s.tablePath(tableName); err != nil {
+			return nil, err
+		}
+	}
+	fields := make([][]TableField, len(paths))
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(describeTableWorkers)
+	for i, p := range paths {
+		g.Go(func() (err error) {
+			fields[i], err = s.Fields(ctx, p)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	byTable := make(map[string][]TableField, len(paths))
+	for i, p := range paths {
+		byTable[p] = fields[i]
+	}
+	return json.Marshal(byTable)
+}
+
+func (s *Schema) RetrieveTableDescription(ctx context.Context, tableName string) (respData []byte, err error) {
+	defer func() {
+		if err != nil {
+			log.DefaultLogger.Error("Describing table failed", "error", err.Error())
+		}
+	}()
+
+	p, err := s.tablePath(tableName)
+	if err != nil {
+		return nil, err
+	}
+	description, err := s.Describe(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(description)
+}
+
+// RefreshSchema drops cached entries and returns the reloaded list of tables
+func (s *Schema) RefreshSchema(ctx context.Context) (respData []byte, err error) {
+	s.dropEntries()
+	return s.RetrieveListTables(ctx, TablesQuery{})
+}
+
+// dropEntries drops cached entries, entries of loads in flight are not stored
+func (s *Schema) dropEntries() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = make(map[string]schemaEntry)
+	s.generation++
+}
+
+// ListTables returns the page of tables of the query folder
+func (s *Schema) ListTables(ctx context.Context, query TablesQuery) (TablesPage, error) {
+	entries, err := s.folderEntries(ctx, query, query.matchesTable)
+	if err != nil {
+		return TablesPage{}, err
+	}
+	return query.page(tablePaths(entries)), nil
+}
+
+// ListEntries returns the page of scheme entries of the query folder
+func (s *Schema) ListEntries(ctx context.Context, query TablesQuery) (EntriesPage, error) {
+	entries, err := s.folderEntries(ctx, query, query.matchesEntry)
+	if err != nil {
+		return EntriesPage{}, err
+	}
+	return query.entriesPage(entries), nil
+}
+
+// folderEntries returns entries of the query folder. Cached entries are filtered,
+// otherwise only the requested folder is listed, a page lists only folders of entries after the cursor
+// until the page is filled with entries matching the query.
+func (s *Schema) folderEntries(ctx context.Context, query TablesQuery, match func(SchemeEntry) bool) ([]SchemeEntry, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	folder, err := folderPath(s.settings.DBLocation, query.Folder)
+	if err != nil {
+		return nil, err
+	}
+	if !s.cached() {
+		if query.Paginated() {
+			return s.walkEntries(ctx, folder, query, match)
+		}
+		return s.loadEntries(ctx, folder, query.Depth)
+	}
+	all, err := s.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var entries []SchemeEntry
+	for _, entry := range all {
+		if inFolder(entry.Path, folder, query.Depth) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Entries returns all scheme entries of the database
+func (s *Schema) Entries(ctx context.Context) ([]SchemeEntry, error) {
+	value, err := s.get(ctx, entriesKey)
+	if err != nil {
+		return nil, err
+	}
+	return value.([]SchemeEntry), nil
+}
+
+// Tables returns list of all tables includes folder tables
+func (s *Schema) Tables(ctx context.Context) ([]string, error) {
+	entries, err := s.Entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tablePaths(entries), nil
+}
+
+// Fields returns columns of the table
+func (s *Schema) Fields(ctx context.Context, tableName string) ([]TableField, error) {
+	value, err := s.get(ctx, fieldsKey(tableName))
+	if err != nil {
+		return nil, err
+	}
+	return value.([]TableField), nil
+}
+
+// Describe returns the full description of the table
+func (s *Schema) Describe(ctx context.Context, tableName string) (TableDescription, error) {
+	value, err := s.get(ctx, describeKey(tableName))
+	if err != nil {
+		return TableDescription{}, err
+	}
+	return value.(TableDescription), nil
+}
+
+// tablePath resolves the table path relative to the database root
+func (s *Schema) tablePath(tableName string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	return tablePath(s.settings.DBLocation, tableName)
+}
+
+func fieldsKey(tableName string) string {
+	return "fields:" + tableName
+}
+
+func describeKey(tableName string) string {
+	return "describe:" + tableName
+}
+
+func (s *Schema) get(ctx context.Context, key string) (interface{}, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	load := s.loader(key)
+	if !s.cached() {
+		return load(ctx)
+	}
+	return s.getCached(ctx, key, load)
+}
+
+// getCached returns the cached entry of the key or loads it once for concurrent requests. The load isn't
+// cancelled with the request which started it, since other requests wait for it too
+func (s *Schema) getCached(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[key]
+	if ok {
+		entry.usedAt = time.Now()
+		s.entries[key] = entry
+	}
+	generation := s.generation
+	s.mu.Unlock()
+	if ok {
+		if time.Since(entry.loadedAt) > s.settings.SchemaCacheTTLDuration {
+			go s.reload(key)
+		}
+		return entry.value, nil
+	}
+	results := s.loads.DoChan(loadKey(generation, key), func() (interface{}, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.settings.MetadataTimeoutDuration)
+		defer cancel()
+		return s.load(loadCtx, key, generation, load)
+	})
+	select {
+	case result := <-results:
+		return result.Val, result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load stores the loaded entry unless entries were dropped after the load started
+func (s *Schema) load(ctx context.Context, key string, generation uint64, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	value, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return value, nil
+	}
+	usedAt := now
+	if entry, ok := s.entries[key]; ok {
+		usedAt = entry.usedAt
+	}
+	s.entries[key] = schemaEntry{value: value, loadedAt: now, usedAt: usedAt}
+	return value, nil
+}
+
+// reload refreshes the entry in background, an entry which can't be loaded anymore is dropped
+func (s *Schema) reload(key string) {
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+	_, err, _ := s.loads.Do(loadKey(generation, key), func() (interface{}, error) {
+		return s.load(context.Background(), key, generation, s.loader(key))
+	})
+	if err != nil {
+		log.DefaultLogger.Warn("Schema refresh failed", "key", key, "error", err.Error())
+		s.mu.Lock()
+		if s.generation == generation {
+			delete(s.entries, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// loadKey identifies loads of the key, loads started before entries were dropped aren't shared
+func loadKey(generation uint64, key string) string {
+	return fmt.Sprintf("%d/%s", generation, key)
+}
+
+// refreshLoop keeps used entries fresh, so requests rarely get expired entries
+func (s *Schema) refreshLoop() {
+	ticker := time.NewTicker(s.settings.SchemaCacheTTLDuration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			for _, key := range s.refreshKeys(time.Now()) {
+				select {
+				case <-s.done:
+					return
+				default:
+				}
+				s.reload(key)
+			}
+		}
+	}
+}
+
+// refreshKeys returns keys of entries used within the last TTL, other entries are evicted
+func (s *Schema) refreshKeys(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key, entry := range s.entries {
+		if now.Sub(entry.usedAt) > s.settings.SchemaCacheTTLDuration {
+			delete(s.entries, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// loader returns the function which loads the entry of the key
+func (s *Schema) loader(key string) func(ctx context.Context) (interface{}, error) {
+	if key == entriesKey {
+		return func(ctx context.Context) (interface{}, error) {
+			return s.loadEntries(ctx, s.settings.DBLocation, 0)
+		}
+	}
+	if tableName, ok := strings.CutPrefix(key, describeKey("")); ok {
+		return func(ctx context.Context) (interface{}, error) {
+			return s.loadDescription(ctx, tableName)
+		}
+	}
+	tableName := strings.TrimPrefix(key, fieldsKey(""))
+	return func(ctx context.Context) (interface{}, error) {
+		return s.loadFields(ctx, tableName)
+	}
+}
+
+func (s *Schema) loadEntries(ctx context.Context, folder string, depth int) (entries []SchemeEntry, err error) {
+	err = s.withDriver(ctx, func(ctx context.Context, db *ydb.Driver) (err error) {
+		entries, err = listEntries(ctx, db, folder, depth)
+		return err
+	})
+	return entries, err
+}
+
+func (s *Schema) walkEntries(ctx context.Context, folder string, query TablesQuery, match func(SchemeEntry) bool) (entries []SchemeEntry, err error) {
+	err = s.withDriver(ctx, func(ctx context.Context, db *ydb.Driver) (err error) {
+		client := Ydb_Scheme_V1.NewSchemeServiceClient(ydb.GRPCConn(db))
+		list := func(ctx context.Context, dir string) ([]SchemeEntry, error) {
+			return listDirectory(ctx, client, dir)
+		}
+		// one more entry tells the page there is a next one
+		limit := 0
+		if query.Limit > 0 {
+			limit = query.Limit + 1
+		}
+		entries, err = walkEntries(ctx, list, folder, query.Depth, query.Cursor, limit, match)
+		return err
+	})
+	return entries, err
+}
+
+func (s *Schema) loadFields(ctx context.Context, tableName string) (fields []TableField, err error) {
+	err = s.withDriver(ctx, func(ctx context.Context, db *ydb.Driver) (err error) {
+		fields, err = listFields(ctx, db, tableName)
+		return err
+	})
+	return fields, err
+}
+
+func (s *Schema) loadDescription(ctx context.Context, tableName string) (description TableDescription, err error) {
+	err = s.withDriver(ctx, func(ctx context.Context, db *ydb.Driver) (err error) {
+		description, err = describeTable(ctx, db, tableName)
+		return err
+	})
+	return description, err
+}
+
+// withDriver calls f with the driver of the instance or, when the user's identity is forwarded,
+// with the driver of the user's identity
+func (s *Schema) withDriver(ctx context.Context, f func(ctx context.Context, db *ydb.Driver) error) error {
+	var db *ydb.Driver
+	if s.settings.AuthKind == "ForwardOAuthIdentity" {
+		if s.identities == nil {
+			return fmt.Errorf("%w", models.ErrOAuthIdentityTokenEmpty)
+		}
+		connectionArgs, release, err := s.identities.forward(ctx, backend.UserFromContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer release()
+		if db, err = s.identities.driver(ctx, s.settings, connectionArgs); err != nil {
+			return err
+		}
+	} else {
+		instanceDriver, err := s.instanceDriver(ctx)
+		if err != nil {
+			return err
+		}
+		db = instanceDriver
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.settings.MetadataTimeoutDuration)
+	defer cancel()
+	return f(ctx, db)
+}
+
+func (s *Schema) instanceDriver(ctx context.Context) (*ydb.Driver, error) {
+	s.driverMu.Lock()
+	defer s.driverMu.Unlock()
+	select {
+	case <-s.done:
+		// a refresh racing with Close must not open a driver nobody closes
+		return nil, ErrSchemaClosed
+	default:
+	}
+	if s.driver != nil {
+		return s.driver, nil
+	}
+	connectionCtx, connectionCancel := context.WithTimeout(ctx, s.settings.ConnectTimeoutDuration)
+	defer connectionCancel()
+	db, err := createDriver(connectionCtx, s.settings)
+	if err != nil {
+		return nil, err
+	}
+	s.driver = db
+	return db, nil
+}
+
+// Close stops background refresh and closes the driver of the instance
+func (s *Schema) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.driverMu.Lock()
+		defer s.driverMu.Unlock()
+		if s.driver != nil {
+			s.driver.Close(context.Background())
+			s.driver = nil
+		}
+	})
+}
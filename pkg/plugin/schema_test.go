@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/models"
+)
+
+func TestSchemaRefreshKeys(t *testing.T) {
+	now := time.Now()
+	s := &Schema{
+		settings: &models.Settings{SchemaCacheTTLDuration: time.Minute},
+		entries: map[string]schemaEntry{
+			entriesKey:        {loadedAt: now.Add(-2 * time.Minute), usedAt: now.Add(-30 * time.Second)},
+			fieldsKey("logs"): {loadedAt: now.Add(-2 * time.Minute), usedAt: now.Add(-2 * time.Minute)},
+		},
+	}
+	assert.Equal(t, []string{entriesKey}, s.refreshKeys(now))
+	assert.NotContains(t, s.entries, fieldsKey("logs"))
+}
+
+func TestSchemaClosed(t *testing.T) {
+	s := &Schema{settings: &models.Settings{}, done: make(chan struct{})}
+	s.Close()
+	_, err := s.instanceDriver(context.Background())
+	require.ErrorIs(t, err, ErrSchemaClosed)
+	assert.Nil(t, s.driver)
+}
+
+// blockingLoad loads the value after release is closed, started is closed when the load starts
+type blockingLoad struct {
+	started chan struct{}
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func newBlockingLoad() *blockingLoad {
+	return &blockingLoad{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (l *blockingLoad) load(ctx context.Context) (interface{}, error) {
+	if l.calls.Add(1) == 1 {
+		close(l.started)
+	}
+	<-l.release
+	return []string{"/local/logs"}, ctx.Err()
+}
+
+// newLoadingSchema returns a cached schema without entries
+func newLoadingSchema() *Schema {
+	return &Schema{
+		settings: &models.Settings{SchemaCacheTTLDuration: time.Minute, MetadataTimeoutDuration: time.Minute},
+		entries:  make(map[string]schemaEntry),
+	}
+}
+
+func TestSchemaLoadOutlivesCancelledRequest(t *testing.T) {
+	s := newLoadingSchema()
+	l := newBlockingLoad()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := s.getCached(ctx, entriesKey, l.load)
+		cancelled <- err
+	}()
+	<-l.started
+	cancel()
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+
+	waiting := make(chan error)
+	go func() {
+		value, err := s.getCached(context.Background(), entriesKey, l.load)
+		assert.Equal(t, []string{"/local/logs"}, value)
+		waiting <- err
+	}()
+	close(l.release)
+	require.NoError(t, <-waiting, "the load isn't cancelled with the request which started it")
+	assert.Equal(t, int32(1), l.calls.Load())
+	assert.Contains(t, s.entries, entriesKey)
+}
+
+func TestSchemaRefreshDuringLoad(t *testing.T) {
+	s := newLoadingSchema()
+	l := newBlockingLoad()
+	loaded := make(chan error)
+	go func() {
+		_, err := s.getCached(context.Background(), entriesKey, l.load)
+		loaded <- err
+	}()
+	<-l.started
+	s.dropEntries()
+	close(l.release)
+	require.NoError(t, <-loaded)
+	assert.NotContains(t, s.entries, entriesKey, "loads started before the refresh aren't stored")
+}
@@ -0,0 +1,448 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Poll intervals and rows of streams
+const (
+	defaultStreamInterval = 5 * time.Second
+	minStreamInterval     = time.Second
+	defaultStreamRange    = time.Hour
+	maxStreamRows         = 10000
+)
+
+// streamRequest is the data of subscriptions to live streams of queries
+type streamRequest struct {
+	Query json.RawMessage `json:"query"`
+	// RangeMs is the length of the dashboard time range, polls read the range ending at the time of the poll
+	RangeMs int64 `json:"rangeMs,omitempty"`
+}
+
+// validateStream checks the stream column and the poll interval, rows of polls are limited
+func (q *queryModel) validateStream() error {
+	s := q.Stream
+	if s.Column == "" {
+		return fmt.Errorf("%w: stream needs a column", ErrInvalidParameter)
+	}
+	if q.QueryType == queryTypeTraces || q.VariableQuery || q.Annotation || q.LogContext != nil || q.SupplementaryQueryType != "" {
+		return fmt.Errorf("%w: traces, variable, annotation, log context and supplementary queries can't be streamed", ErrInvalidParameter)
+	}
+	if _, err := q.streamInterval(); err != nil {
+		return err
+	}
+	if s.Limit < 0 {
+		return fmt.Errorf("%w: stream limit %d", ErrInvalidParameter, s.Limit)
+	}
+	if s.Limit > maxStreamRows {
+		s.Limit = maxStreamRows
+	}
+	return nil
+}
+
+// streamInterval returns the poll interval of the stream, 5 seconds by default
+func (q queryModel) streamInterval() (time.Duration, error) {
+	if q.Stream.Interval == "" {
+		return defaultStreamInterval, nil
+	}
+	interval, err := time.ParseDuration(q.Stream.Interval)
+	if err != nil || interval < minStreamInterval {
+		return 0, fmt.Errorf("%w: stream interval %q, at least %s", ErrInvalidParameter, q.Stream.Interval, minStreamInterval)
+	}
+	return interval, nil
+}
+
+// parseStreamRequest reads the subscription and the query of the stream
+func parseStreamRequest(raw json.RawMessage) (request streamRequest, query queryModel, _ error) {
+	if err := json.Unmarshal(raw, &request); err != nil {
+		return request, query, fmt.Errorf("%w: stream: %s", ErrInvalidParameter, err.Error())
+	}
+	query, err := parseQueryModel(request.Query)
+	if err != nil {
+		return request, query, err
+	}
+	if query.Stream == nil && query.QueryType != queryTypeTopic {
+		return request, query, fmt.Errorf("%w: query has no stream", ErrInvalidParameter)
+	}
+	return request, query, nil
+}
+
+// SubscribeStream allows subscriptions to streams of valid queries
+func (ds *Datasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if _, _, err := parseStreamRequest(req.Data); err != nil {
+		log.DefaultLogger.Warn("Stream subscription rejected", "path", req.Path, "error", err.Error())
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream rejects publications, streams are read only
+func (ds *Datasource) PublishStream(context.Context, *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream sends new rows of the query or messages of the topic until the stream is closed, subscribers
+// of the same query of the datasource share one poller or topic reader
+func (ds *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	request, query, err := parseStreamRequest(req.Data)
+	if err != nil {
+		return err
+	}
+	key := streamKey(dataSourceUID(req.PluginContext), request)
+	if query.QueryType == queryTypeTopic {
+		var args struct {
+			ConnectionArgs json.RawMessage `json:"connectionArgs"`
+		}
+		_ = json.Unmarshal(request.Query, &args)
+		return ds.streams.run(ctx, key, sender, func() streamSource {
+			stream := newTopicStream(ds.openTopic(*query.Topic, args.ConnectionArgs), *query.Topic, query.QueryFormat)
+			if topicPath, err := ds.topicPath(*query.Topic); err == nil {
+				stream.path = topicPath
+			}
+			if query.Topic.Changefeed != "" {
+				stream.describe = ds.describeChangefeed(*query.Topic, args.ConnectionArgs)
+			}
+			return stream
+		})
+	}
+	interval, _ := query.streamInterval()
+	return ds.streams.run(ctx, key, sender, func() streamSource {
+		poller := newStreamPoller(ds.streamPoll(req.PluginContext, request, query), query.Stream.Column, interval, query.Stream.Limit)
+		if query.QueryFormat == QueryFormatLogs {
+			columns := query.logColumns()
+			poller.convert = func(frame *data.Frame) *data.Frame {
+				return logsFrame(frame, columns)
+			}
+		}
+		return poller
+	})
+}
+
+// streamPoll runs the query of the stream over the time range ending at the time of the poll, the query format
+// is applied by the poller after the cursor is read from rows
+func (ds *Datasource) streamPoll(pluginContext backend.PluginContext, request streamRequest, query queryModel) pollFunc {
+	var ref struct {
+		RefID string `json:"refId"`
+	}
+	_ = json.Unmarshal(request.Query, &ref)
+	timeRange := defaultStreamRange
+	if request.RangeMs > 0 {
+		timeRange = time.Duration(request.RangeMs) * time.Millisecond
+	}
+	return func(ctx context.Context, cursor streamCursor) (*data.Frame, error) {
+		stream := *query.Stream
+		stream.Poll, stream.After, stream.AfterTime = true, cursor.After, cursor.AfterTime
+		streamJSON, err := json.Marshal(stream)
+		if err != nil {
+			return nil, err
+		}
+		queryJSON, err := setQueryField(request.Query, "stream", streamJSON)
+		if err != nil {
+			return nil, err
+		}
+		if queryJSON, err = setQueryField(queryJSON, "queryFormat", json.RawMessage(strconv.Quote(QueryFormatTable))); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		resp, err := ds.QueryData(ctx, &backend.QueryDataRequest{
+			PluginContext: pluginContext,
+			Queries: []backend.DataQuery{{
+				RefID:     ref.RefID,
+				JSON:      queryJSON,
+				TimeRange: backend.TimeRange{From: now.Add(-timeRange), To: now},
+			}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		response := resp.Responses[ref.RefID]
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		if len(response.Frames) == 0 {
+			return data.NewFrame(ref.RefID), nil
+		}
+		return response.Frames[0], nil
+	}
+}
+
+// streamKey identifies pollers by the datasource and the query, queries of panels differing
+// only in their refId share the poller
+func streamKey(uid string, request streamRequest) string {
+	query := map[string]json.RawMessage{}
+	_ = json.Unmarshal(request.Query, &query)
+	delete(query, "refId")
+	request.Query, _ = json.Marshal(query)
+	requestJSON, _ := json.Marshal(request)
+	hash := fnv.New64a()
+	hash.Write([]byte(uid))
+	hash.Write(requestJSON)
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+// streamCursor is the value of the stream column in the last row read
+type streamCursor struct {
+	After     json.RawMessage
+	AfterTime bool
+}
+
+type pollFunc func(ctx context.Context, cursor streamCursor) (*data.Frame, error)
+
+// streamSource produces frames of a stream shared by subscribers
+type streamSource interface {
+	start()
+	stop()
+	subscribe(sender *backend.StreamSender)
+	unsubscribe(sender *backend.StreamSender) int
+}
+
+// streams keeps sources of running streams
+type streams struct {
+	mu      sync.Mutex
+	sources map[string]streamSource
+}
+
+// run subscribes the sender to the source of the key until the context is done, the source is started
+// by the first subscriber and stopped after the last one
+func (s *streams) run(ctx context.Context, key string, sender *backend.StreamSender, newSource func() streamSource) error {
+	s.mu.Lock()
+	if s.sources == nil {
+		s.sources = make(map[string]streamSource)
+	}
+	source, ok := s.sources[key]
+	if !ok {
+		source = newSource()
+		s.sources[key] = source
+		source.start()
+	}
+	source.subscribe(sender)
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if source.unsubscribe(sender) == 0 {
+		source.stop()
+		if s.sources[key] == source {
+			delete(s.sources, key)
+		}
+	}
+	return nil
+}
+
+// close stops all sources, when the datasource is disposed
+func (s *streams) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, source := range s.sources {
+		source.stop()
+		delete(s.sources, key)
+	}
+}
+
+// streamLoop runs the loop of a source until the source is stopped
+type streamLoop struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (l *streamLoop) run(loop func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel, l.done = cancel, make(chan struct{})
+	go func() {
+		defer close(l.done)
+		loop(ctx)
+	}()
+}
+
+// stop cancels the loop and waits for it
+func (l *streamLoop) stop() {
+	if l.cancel == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
+
+// streamBroadcast sends frames to subscribers, the schema is sent once to each subscriber
+// and again after it changes. New subscribers get the last rows sent
+type streamBroadcast struct {
+	limit int
+
+	mu          sync.Mutex
+	subscribers map[*backend.StreamSender]bool
+	tail        *data.Frame
+}
+
+func newStreamBroadcast(limit int) streamBroadcast {
+	if limit <= 0 {
+		limit = maxStreamRows
+	}
+	return streamBroadcast{limit: limit, subscribers: make(map[*backend.StreamSender]bool)}
+}
+
+func (b *streamBroadcast) subscribe(sender *backend.StreamSender) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[sender] = false
+	if b.tail != nil && b.tail.Rows() > 0 {
+		b.send(sender, b.tail)
+	}
+}
+
+func (b *streamBroadcast) unsubscribe(sender *backend.StreamSender) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, sender)
+	return len(b.subscribers)
+}
+
+// publish sends rows of the frame to subscribers and keeps the last rows for new subscribers
+func (b *streamBroadcast) publish(frame *data.Frame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tail == nil || !sameSchema(b.tail, frame) {
+		b.tail = frame.EmptyCopy()
+		b.tail.Meta = frame.Meta
+		for sender := range b.subscribers {
+			b.subscribers[sender] = false
+		}
+	}
+	for row := 0; row < frame.Rows(); row++ {
+		b.tail.AppendRow(frame.RowCopy(row)...)
+	}
+	for b.tail.Rows() > b.limit {
+		b.tail.DeleteRow(0)
+	}
+	for sender := range b.subscribers {
+		b.send(sender, frame)
+	}
+}
+
+// send sends the schema with the first frame and only values of next frames
+func (b *streamBroadcast) send(sender *backend.StreamSender, frame *data.Frame) {
+	include := data.IncludeAll
+	if b.subscribers[sender] {
+		include = data.IncludeDataOnly
+	}
+	if err := sender.SendFrame(frame, include); err != nil {
+		log.DefaultLogger.Warn("Stream frame not sent", "error", err.Error())
+		return
+	}
+	b.subscribers[sender] = true
+}
+
+// streamPoller polls the query for rows after the last row read
+type streamPoller struct {
+	streamBroadcast
+	streamLoop
+	poll     pollFunc
+	column   string
+	interval time.Duration
+	convert  func(*data.Frame) *data.Frame
+	cursor   streamCursor
+}
+
+func newStreamPoller(poll pollFunc, column string, interval time.Duration, limit int) *streamPoller {
+	return &streamPoller{
+		streamBroadcast: newStreamBroadcast(limit),
+		poll:            poll,
+		column:          column,
+		interval:        interval,
+	}
+}
+
+func (p *streamPoller) start() {
+	p.run(func(ctx context.Context) {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			p.pollOnce(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+// pollOnce reads rows after the cursor, the first poll reads the last rows of the query
+func (p *streamPoller) pollOnce(ctx context.Context) {
+	frame, err := p.poll(ctx, p.cursor)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.DefaultLogger.Warn("Stream poll failed", "error", err.Error())
+		}
+		return
+	}
+	if frame.Rows() == 0 {
+		return
+	}
+	if len(p.cursor.After) == 0 {
+		frame = reverseRows(frame)
+	}
+	next, err := lastStreamCursor(frame, p.column)
+	if err != nil {
+		log.DefaultLogger.Warn("Stream poll failed", "error", err.Error())
+		return
+	}
+	p.cursor = next
+	if p.convert != nil {
+		frame = p.convert(frame)
+	}
+	p.publish(frame)
+}
+
+// lastStreamCursor returns the value of the column in the last row, times are microseconds since the epoch
+func lastStreamCursor(frame *data.Frame, column string) (streamCursor, error) {
+	index := logFieldIndex(frame, []string{column})
+	if index < 0 {
+		return streamCursor{}, fmt.Errorf("%w: stream column %q not found", ErrInvalidParameter, column)
+	}
+	value, ok := frame.Fields[index].ConcreteAt(frame.Rows() - 1)
+	if !ok {
+		return streamCursor{}, fmt.Errorf("%w: stream column %q is null", ErrInvalidParameter, column)
+	}
+	if t, ok := value.(time.Time); ok {
+		return streamCursor{After: json.RawMessage(strconv.FormatInt(t.UnixMicro(), 10)), AfterTime: true}, nil
+	}
+	after, err := json.Marshal(value)
+	if err != nil {
+		return streamCursor{}, err
+	}
+	return streamCursor{After: after}, nil
+}
+
+// reverseRows returns rows of the frame in the reverse order
+func reverseRows(frame *data.Frame) *data.Frame {
+	reversed := frame.EmptyCopy()
+	reversed.Meta = frame.Meta
+	for row := frame.Rows() - 1; row >= 0; row-- {
+		reversed.AppendRow(frame.RowCopy(row)...)
+	}
+	return reversed
+}
+
+func sameSchema(a *data.Frame, b *data.Frame) bool {
+	if len(a.Fields) != len(b.Fields) {
+		return false
+	}
+	for i := range a.Fields {
+		if a.Fields[i].Name != b.Fields[i].Name || a.Fields[i].Type() != b.Fields[i].Type() {
+			return false
+		}
+	}
+	return true
+}
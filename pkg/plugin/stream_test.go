@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueryModelStream(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"version":1,"rawSql":"SELECT 1","stream":{"column":"ts","limit":50000}}`))
+	require.NoError(t, err)
+	assert.Equal(t, maxStreamRows, query.Stream.Limit)
+	interval, err := query.streamInterval()
+	require.NoError(t, err)
+	assert.Equal(t, defaultStreamInterval, interval)
+}
+
+func TestStreamPoller(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var cursors []streamCursor
+	poll := func(_ context.Context, cursor streamCursor) (*data.Frame, error) {
+		cursors = append(cursors, cursor)
+		if len(cursor.After) == 0 {
+			return data.NewFrame("A",
+				data.NewField("ts", nil, []time.Time{t0.Add(time.Second), t0}),
+				data.NewField("message", nil, []string{"second", "first"}),
+			), nil
+		}
+		return data.NewFrame("A",
+			data.NewField("ts", nil, []time.Time{t0.Add(2 * time.Second)}),
+			data.NewField("message", nil, []string{"third"}),
+		), nil
+	}
+	poller := newStreamPoller(poll, "ts", time.Second, 2)
+	first := &packets{}
+	poller.subscribe(backend.NewStreamSender(first))
+
+	poller.pollOnce(context.Background())
+	poller.pollOnce(context.Background())
+	require.Len(t, cursors, 2)
+	assert.Equal(t, streamCursor{After: json.RawMessage("1704067201000000"), AfterTime: true}, cursors[1])
+	require.Len(t, first.data, 2)
+	assert.Equal(t, "message", first.frame(t, 0).Fields[1].Name)
+	assert.Equal(t, []any{"first", "second"}, concreteValues(first.frame(t, 0).Fields[1]))
+	assert.JSONEq(t, `{"data":{"values":[[1704067202000],["third"]]}}`, string(first.data[1]), "next frames carry only values")
+
+	second := &packets{}
+	poller.subscribe(backend.NewStreamSender(second))
+	require.Len(t, second.data, 1)
+	assert.Equal(t, []any{"second", "third"}, concreteValues(second.frame(t, 0).Fields[1]))
+}
+
+func TestStreamsSharePoller(t *testing.T) {
+	var pollers, polls atomic.Int32
+	newPoller := func() streamSource {
+		pollers.Add(1)
+		return newStreamPoller(func(context.Context, streamCursor) (*data.Frame, error) {
+			polls.Add(1)
+			return data.NewFrame("A"), nil
+		}, "ts", time.Hour, 0)
+	}
+	s := &streams{}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.run(ctx, "key", backend.NewStreamSender(&packets{}), newPoller))
+		}()
+	}
+	require.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.sources) == 1 && len(s.sources["key"].(*streamPoller).subscribers) == 3
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	wg.Wait()
+	assert.Equal(t, int32(1), pollers.Load())
+	assert.Equal(t, int32(1), polls.Load())
+	assert.Empty(t, s.sources)
+}
+
+func TestStreamKey(t *testing.T) {
+	key := func(query string) string {
+		return streamKey("uid", streamRequest{Query: json.RawMessage(query), RangeMs: 1000})
+	}
+	assert.Equal(t, key(`{"refId":"A","rawSql":"SELECT 1"}`), key(`{"refId":"B","rawSql":"SELECT 1"}`))
+	assert.NotEqual(t, key(`{"refId":"A","rawSql":"SELECT 1"}`), key(`{"refId":"A","rawSql":"SELECT 2"}`))
+}
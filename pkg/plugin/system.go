@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+)
+
+const queryTypeSystem = "system"
+
+// validateSystem checks the system query, rows of system views are returned as a table or as time series
+func (q *queryModel) validateSystem() error {
+	s := q.System
+	if s == nil {
+		return fmt.Errorf("%w: system query needs a system view", ErrInvalidParameter)
+	}
+	view, ok := builder.LookupSystemView(s.View)
+	if !ok {
+		return fmt.Errorf("%w: system view %q", ErrInvalidParameter, s.View)
+	}
+	if s.Window != "" && !slices.Contains(view.Windows, s.Window) {
+		return fmt.Errorf("%w: system view %s has no %q window", ErrInvalidParameter, s.View, s.Window)
+	}
+	if s.Limit < 0 {
+		return fmt.Errorf("%w: system limit %d", ErrInvalidParameter, s.Limit)
+	}
+	if q.QueryFormat != QueryFormatTable && q.QueryFormat != QueryFormatTimeSeries {
+		return fmt.Errorf("%w: system views are returned as a table or as time series, not as %s", ErrInvalidParameter, q.QueryFormat)
+	}
+	return nil
+}
+
+// systemFrame converts rows of the system view to time series of the time series format and sets units
+// of columns to fields, units are set after the conversion which drops configs of fields
+func systemFrame(frame *data.Frame, query queryModel) (*data.Frame, error) {
+	if query.QueryFormat == QueryFormatTimeSeries {
+		var err error
+		if frame, err = timeSeriesFrame(frame, query.fillMissing()); err != nil {
+			return nil, err
+		}
+	}
+	view, _ := builder.LookupSystemView(query.System.View)
+	for _, field := range frame.Fields {
+		i := slices.IndexFunc(view.Columns, func(c builder.SystemColumn) bool { return c.Name == field.Name })
+		if i < 0 || view.Columns[i].Unit == "" {
+			continue
+		}
+		if field.Config == nil {
+			field.Config = &data.FieldConfig{}
+		}
+		field.Config.Unit = view.Columns[i].Unit
+	}
+	return frame, nil
+}
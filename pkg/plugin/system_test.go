@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatResponsesSystem(t *testing.T) {
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{
+		RefID: "A",
+		JSON:  []byte(`{"version":1,"queryType":"system","queryFormat":"table","system":{"view":"partition_stats"}}`),
+	}}}
+	resp := backend.NewQueryDataResponse()
+	resp.Responses["A"] = backend.DataResponse{Frames: data.Frames{data.NewFrame("A",
+		data.NewField("Path", nil, []string{"/local/orders"}),
+		data.NewField("DataSize", nil, []uint64{1024}),
+	)}}
+	_, _, models := validQueries(req)
+	formatResponses(req, models, resp)
+	frame := resp.Responses["A"].Frames[0]
+	assert.Nil(t, frame.Fields[0].Config)
+	assert.Equal(t, "bytes", frame.Fields[1].Config.Unit)
+}
+
+func TestFormatResponsesSystemTimeSeries(t *testing.T) {
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{{
+		RefID: "A",
+		JSON:  []byte(`{"version":1,"queryType":"system","queryFormat":"timeseries","system":{"view":"query_metrics"}}`),
+	}}}
+	now := time.Now()
+	resp := backend.NewQueryDataResponse()
+	resp.Responses["A"] = backend.DataResponse{Frames: data.Frames{data.NewFrame("A",
+		data.NewField("IntervalEnd", nil, []time.Time{now, now}),
+		data.NewField("QueryText", nil, []string{"SELECT 1", "SELECT 2"}),
+		data.NewField("SumCPUTime", nil, []uint64{10, 20}),
+	)}}
+	_, _, models := validQueries(req)
+	formatResponses(req, models, resp)
+	frame := resp.Responses["A"].Frames[0]
+	require.Len(t, frame.Fields, 3)
+	assert.Equal(t, data.Labels{"QueryText": "SELECT 1"}, frame.Fields[1].Labels)
+	assert.Equal(t, "µs", frame.Fields[1].Config.Unit)
+	assert.Equal(t, "µs", frame.Fields[2].Config.Unit)
+}
@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const maxTablesLimit = 10000
+
+// TablesQuery selects a page of tables of the folder
+type TablesQuery struct {
+	// Folder is a path absolute or relative to the database root
+	Folder string
+	// Depth limits levels of nested folders, zero means no limit
+	Depth  int
+	Limit  int
+	Cursor string
+	Search string
+	// Types filters entries by type names, empty means all types
+	Types []string
+}
+
+// TablesPage is a page of tables, NextCursor is empty on the last page
+type TablesPage struct {
+	Tables     []string `json:"tables"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// EntriesPage is a page of scheme entries, NextCursor is empty on the last page
+type EntriesPage struct {
+	Entries    []SchemeEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+// Paginated reports whether a page was requested, otherwise all tables are returned as a plain list
+func (q TablesQuery) Paginated() bool {
+	return q.Limit > 0 || q.Cursor != ""
+}
+
+func ParseTablesQuery(values url.Values) (q TablesQuery, err error) {
+	q.Folder = values.Get("folder")
+	q.Search = values.Get("search")
+	if q.Depth, err = parseNonNegative(values, "depth", 0); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseNonNegative(values, "limit", maxTablesLimit); err != nil {
+		return q, err
+	}
+	if cursor := values.Get("cursor"); cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return q, fmt.Errorf("%w: cursor %q", ErrInvalidParameter, cursor)
+		}
+		q.Cursor = string(decoded)
+	}
+	if types := values.Get("type"); types != "" {
+		for _, name := range splitList(types, ",") {
+			entryType, ok := entryTypeName(name)
+			if !ok {
+				return q, fmt.Errorf("%w: type %q", ErrInvalidParameter, name)
+			}
+			q.Types = append(q.Types, entryType)
+		}
+	}
+	return q, nil
+}
+
+func parseNonNegative(values url.Values, name string, max int) (int, error) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || (max > 0 && n > max) {
+		return 0, fmt.Errorf("%w: %s %q", ErrInvalidParameter, name, value)
+	}
+	return n, nil
+}
+
+// absolutePath resolves the path relative to the database root
+func absolutePath(root string, p string) string {
+	if p == "" {
+		return root
+	}
+	if p == root || strings.HasPrefix(p, root+"/") {
+		return path.Clean(p)
+	}
+	return path.Join(root, p)
+}
+
+// tablePath resolves the table path relative to the database root, the path must stay inside the database
+func tablePath(root string, table string) (string, error) {
+	if strings.TrimSpace(table) == "" {
+		return "", fmt.Errorf("%w: table is required", ErrInvalidParameter)
+	}
+	resolved := absolutePath(root, table)
+	if !strings.HasPrefix(resolved, root+"/") || strings.ContainsFunc(table, unicode.IsControl) {
+		return "", fmt.Errorf("%w: table %q", ErrInvalidParameter, table)
+	}
+	return resolved, nil
+}
+
+// folderPath resolves the folder path relative to the database root, the folder must stay inside the database
+func folderPath(root string, folder string) (string, error) {
+	resolved := absolutePath(root, folder)
+	if (resolved != root && !strings.HasPrefix(resolved, root+"/")) || strings.ContainsFunc(folder, unicode.IsControl) {
+		return "", fmt.Errorf("%w: folder %q", ErrInvalidParameter, folder)
+	}
+	return resolved, nil
+}
+
+// inFolder reports whether the table is in the folder not deeper than depth levels
+func inFolder(table string, folder string, depth int) bool {
+	if !strings.HasPrefix(table, folder+"/") {
+		return false
+	}
+	return depth <= 0 || strings.Count(table[len(folder)+1:], "/") < depth
+}
+
+// page filters sorted tables by search and returns the page after the cursor
+func (q TablesQuery) page(tables []string) TablesPage {
+	result := TablesPage{}
+	result.Tables, result.NextCursor = paginate(q, tables, func(table string) string { return table })
+	return result
+}
+
+// entriesPage filters sorted entries by type and search and returns the page after the cursor
+func (q TablesQuery) entriesPage(entries []SchemeEntry) EntriesPage {
+	if len(q.Types) > 0 {
+		var filtered []SchemeEntry
+		for _, entry := range entries {
+			if slices.Contains(q.Types, entry.Type) {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	result := EntriesPage{}
+	result.Entries, result.NextCursor = paginate(q, entries, func(entry SchemeEntry) string { return entry.Path })
+	return result
+}
+
+// matchesSearch reports whether the path contains the search case-insensitively
+func (q TablesQuery) matchesSearch(p string) bool {
+	return q.Search == "" || strings.Contains(strings.ToLower(p), strings.ToLower(q.Search))
+}
+
+// matchesTable reports whether the entry is a table listed by the query
+func (q TablesQuery) matchesTable(entry SchemeEntry) bool {
+	return entry.IsTable() && q.matchesSearch(entry.Path)
+}
+
+// matchesEntry reports whether the entry is listed by the query
+func (q TablesQuery) matchesEntry(entry SchemeEntry) bool {
+	return (len(q.Types) == 0 || slices.Contains(q.Types, entry.Type)) && q.matchesSearch(entry.Path)
+}
+
+// paginate returns items after the cursor which path matches the search, items are sorted by path
+func paginate[T any](q TablesQuery, items []T, itemPath func(T) string) ([]T, string) {
+	start := 0
+	if q.Cursor != "" {
+		start = sort.Search(len(items), func(i int) bool { return itemPath(items[i]) >= q.Cursor })
+		if start < len(items) && itemPath(items[start]) == q.Cursor {
+			start++
+		}
+	}
+	result := []T{}
+	for _, item := range items[start:] {
+		if !q.matchesSearch(itemPath(item)) {
+			continue
+		}
+		if q.Limit > 0 && len(result) == q.Limit {
+			return result, base64.RawURLEncoding.EncodeToString([]byte(itemPath(result[len(result)-1])))
+		}
+		result = append(result, item)
+	}
+	return result, ""
+}
@@ -0,0 +1,166 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTablesQueryPage(t *testing.T) {
+	tables := []string{"/local/a", "/local/logs/b", "/local/logs/c", "/local/logs/old/d"}
+
+	query, err := ParseTablesQuery(url.Values{"limit": {"2"}})
+	require.NoError(t, err)
+	page := query.page(tables)
+	assert.Equal(t, []string{"/local/a", "/local/logs/b"}, page.Tables)
+	require.NotEmpty(t, page.NextCursor)
+
+	query, err = ParseTablesQuery(url.Values{"limit": {"2"}, "cursor": {page.NextCursor}})
+	require.NoError(t, err)
+	page = query.page(tables)
+	assert.Equal(t, []string{"/local/logs/c", "/local/logs/old/d"}, page.Tables)
+	assert.Empty(t, page.NextCursor)
+
+	query, err = ParseTablesQuery(url.Values{"search": {"OLD"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/local/logs/old/d"}, query.page(tables).Tables)
+}
+
+func TestTablesQueryInvalid(t *testing.T) {
+	_, err := ParseTablesQuery(url.Values{"depth": {"-1"}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+	_, err = ParseTablesQuery(url.Values{"cursor": {"%%"}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestInFolder(t *testing.T) {
+	folder := absolutePath("/local", "logs")
+	assert.Equal(t, "/local/logs", folder)
+	assert.Equal(t, "/local/logs", absolutePath("/local", "/local/logs/"))
+	assert.True(t, inFolder("/local/logs/b", folder, 1))
+	assert.False(t, inFolder("/local/logs/old/d", folder, 1))
+	assert.True(t, inFolder("/local/logs/old/d", folder, 0))
+	assert.False(t, inFolder("/local/logsarchive/e", folder, 0))
+}
+
+func TestEntriesPageFiltersTypes(t *testing.T) {
+	entries := []SchemeEntry{
+		{Path: "/local/events", Type: EntryTypeTopic},
+		{Path: "/local/logs", Type: EntryTypeDirectory},
+		{Path: "/local/logs/b", Type: EntryTypeTable},
+		{Path: "/local/logs/view", Type: "View"},
+	}
+
+	query, err := ParseTablesQuery(url.Values{"type": {"view, topic"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"View", "Topic"}, query.Types)
+	page := query.entriesPage(entries)
+	assert.Equal(t, []SchemeEntry{entries[0], entries[3]}, page.Entries)
+	assert.Equal(t, []string{"/local/logs/b"}, tablePaths(entries))
+
+	_, err = ParseTablesQuery(url.Values{"type": {"Index"}})
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestTablePath(t *testing.T) {
+	p, err := tablePath("/local", "logs/b")
+	require.NoError(t, err)
+	assert.Equal(t, "/local/logs/b", p)
+	p, err = tablePath("/local", "/local/logs/b")
+	require.NoError(t, err)
+	assert.Equal(t, "/local/logs/b", p)
+
+	for _, table := range []string{"", " ", "/local", "../other/t", "logs/\nb"} {
+		_, err = tablePath("/local", table)
+		assert.ErrorIs(t, err, ErrInvalidParameter, table)
+	}
+	assert.Equal(t, http.StatusBadRequest, HTTPStatus(err))
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(fmt.Errorf("%w: /local/t", ErrTableNotFound)))
+}
+
+func TestFolderPath(t *testing.T) {
+	for folder, expected := range map[string]string{"": "/local", "logs": "/local/logs", "/local/logs/": "/local/logs"} {
+		p, err := folderPath("/local", folder)
+		require.NoError(t, err)
+		assert.Equal(t, expected, p)
+	}
+	for _, folder := range []string{"../x", "/local/../x", "logs/\nb"} {
+		_, err := folderPath("/local", folder)
+		assert.ErrorIs(t, err, ErrInvalidParameter, folder)
+	}
+}
+
+func TestWalkEntries(t *testing.T) {
+	folders := map[string][]SchemeEntry{
+		"/local": {
+			{Path: "/local/a", Type: EntryTypeDirectory},
+			{Path: "/local/a-b", Type: EntryTypeTable},
+			{Path: "/local/c", Type: EntryTypeDirectory},
+		},
+		"/local/a":   {{Path: "/local/a/t", Type: EntryTypeTable}, {Path: "/local/a/u", Type: EntryTypeTable}},
+		"/local/c":   {{Path: "/local/c/d", Type: EntryTypeDirectory}},
+		"/local/c/d": {{Path: "/local/c/d/t", Type: EntryTypeTable}},
+	}
+	var (
+		mu     sync.Mutex
+		listed []string
+	)
+	list := func(_ context.Context, folder string) ([]SchemeEntry, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		listed = append(listed, folder)
+		return folders[folder], nil
+	}
+	query := TablesQuery{}
+
+	entries, err := walkEntries(context.Background(), list, "/local", 0, "", 3, query.matchesTable)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/local/a-b", "/local/a/t", "/local/a/u"}, tablePaths(entries))
+	// /local/c is listed ahead of the walk, its folders are not
+	assert.ElementsMatch(t, []string{"/local", "/local/a", "/local/c"}, listed)
+
+	listed = nil
+	entries, err = walkEntries(context.Background(), list, "/local", 0, "/local/c", 3, query.matchesTable)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/local/c/d/t"}, tablePaths(entries))
+	assert.ElementsMatch(t, []string{"/local", "/local/c", "/local/c/d"}, listed)
+
+	entries, err = walkEntries(context.Background(), list, "/local", 1, "", 0, query.matchesTable)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/local/a-b"}, tablePaths(entries))
+}
+
+func TestWalkEntriesConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	listed := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(listed)
+	}()
+	list := func(ctx context.Context, folder string) ([]SchemeEntry, error) {
+		if folder == "/local" {
+			return []SchemeEntry{{Path: "/local/a", Type: EntryTypeDirectory}, {Path: "/local/b", Type: EntryTypeDirectory}}, nil
+		}
+		// listings of both folders wait for each other, so a walk listing one folder at a time fails
+		wg.Done()
+		select {
+		case <-listed:
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("folders are listed one at a time")
+		}
+		return []SchemeEntry{{Path: folder + "/t", Type: EntryTypeTable}}, nil
+	}
+	query := TablesQuery{}
+	entries, err := walkEntries(context.Background(), list, "/local", 0, "", 0, query.matchesTable)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/local/a/t", "/local/b/t"}, tablePaths(entries))
+}
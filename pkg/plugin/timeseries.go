@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// timeSeriesFrame sorts rows of the frame by time and converts long frames, such as results of GROUP BY,
+// to wide frames where string columns are labels of series and missing values are filled by the fill mode
+func timeSeriesFrame(frame *data.Frame, fillMissing *data.FillMissing) (*data.Frame, error) {
+	schema := frame.TimeSeriesSchema()
+	if schema.Type == data.TimeSeriesTypeNot {
+		return frame, nil
+	}
+	frame = sortByTime(frame, schema.TimeIndex)
+	if schema.Type == data.TimeSeriesTypeLong && frame.Rows() > 0 {
+		wide, err := data.LongToWide(frame, fillMissing)
+		if err != nil {
+			return nil, err
+		}
+		frame = wide
+	}
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	frame.Meta.PreferredVisualization = data.VisTypeGraph
+	return frame, nil
+}
+
+// sortByTime returns a copy of the frame with rows sorted ascending by time, rows without time are dropped
+func sortByTime(frame *data.Frame, timeIndex int) *data.Frame {
+	timeField := frame.Fields[timeIndex]
+	times := make([]time.Time, timeField.Len())
+	rows := make([]int, 0, timeField.Len())
+	for i := range times {
+		value, ok := timeField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		times[i] = value.(time.Time)
+		rows = append(rows, i)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return times[rows[i]].Before(times[rows[j]])
+	})
+
+	sorted := frame.EmptyCopy()
+	sorted.Meta = frame.Meta
+	for i, field := range frame.Fields {
+		sorted.Fields[i].Config = field.Config
+	}
+	for _, row := range rows {
+		sorted.AppendRow(frame.RowCopy(row)...)
+	}
+	return sorted
+}
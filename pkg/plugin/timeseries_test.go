@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeSeriesFrameLongToWide(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	frame := data.NewFrame("A",
+		data.NewField("time", nil, []*time.Time{&t1, &t0, nil, &t0}),
+		data.NewField("host", nil, []string{"a", "a", "a", "b"}),
+		data.NewField("value", nil, []float64{2, 1, 3, 10}),
+	)
+
+	wide, err := timeSeriesFrame(frame, &data.FillMissing{Mode: data.FillModePrevious})
+	require.NoError(t, err)
+	assert.Equal(t, data.VisType(data.VisTypeGraph), wide.Meta.PreferredVisualization)
+	require.Len(t, wide.Fields, 3)
+	assert.Equal(t, []time.Time{t0, t1}, []time.Time{wide.Fields[0].At(0).(time.Time), wide.Fields[0].At(1).(time.Time)})
+	assert.Equal(t, data.Labels{"host": "a"}, wide.Fields[1].Labels)
+	assert.Equal(t, []any{1.0, 2.0}, concreteValues(wide.Fields[1]))
+	assert.Equal(t, data.Labels{"host": "b"}, wide.Fields[2].Labels)
+	assert.Equal(t, []any{10.0, 10.0}, concreteValues(wide.Fields[2]))
+}
+
+func TestTimeSeriesFrameKeepsTables(t *testing.T) {
+	frame := data.NewFrame("A", data.NewField("host", nil, []string{"a"}))
+	result, err := timeSeriesFrame(frame, nil)
+	require.NoError(t, err)
+	assert.Same(t, frame, result)
+}
+
+func TestFormatResponsesTimeSeries(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		{RefID: "A", JSON: []byte(`{"version":1,"queryFormat":"timeseries","options":{"fillMode":"value","fillValue":0}}`)},
+		{RefID: "B", JSON: []byte(`{"version":1,"queryFormat":"table"}`)},
+	}}
+	long := func() *data.Frame {
+		return data.NewFrame("",
+			data.NewField("time", nil, []time.Time{t0, t0.Add(time.Minute)}),
+			data.NewField("host", nil, []string{"a", "b"}),
+			data.NewField("value", nil, []int64{1, 2}),
+		)
+	}
+	resp := &backend.QueryDataResponse{Responses: backend.Responses{
+		"A": {Frames: data.Frames{long()}},
+		"B": {Frames: data.Frames{long()}},
+	}}
+
+	_, _, models := validQueries(req)
+	formatResponses(req, models, resp)
+	a := resp.Responses["A"].Frames[0]
+	require.Len(t, a.Fields, 3)
+	assert.Equal(t, []any{int64(1), int64(0)}, concreteValues(a.Fields[1]))
+	assert.Equal(t, []any{int64(0), int64(2)}, concreteValues(a.Fields[2]))
+	assert.Len(t, resp.Responses["B"].Frames[0].Fields, 3)
+	assert.Equal(t, "host", resp.Responses["B"].Frames[0].Fields[1].Name)
+}
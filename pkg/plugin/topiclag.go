@@ -0,0 +1,190 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topictypes"
+)
+
+const queryTypeTopicLag = "topicLag"
+
+// Names of fields of topic lag metrics
+const (
+	topicLagTopicField     = "topic"
+	topicLagConsumerField  = "consumer"
+	topicLagWriteRate      = "writeRate"
+	topicLagCommitted      = "committedOffset"
+	topicLagEndOffset      = "endOffset"
+	topicLagMessages       = "lagMessages"
+	topicLagSeconds        = "lagSeconds"
+	topicLagPartitionField = "partition"
+)
+
+// validateTopicLag checks the topic lag query, metrics are returned as time series or as a table
+func (q *queryModel) validateTopicLag() error {
+	if q.Topic == nil || q.Topic.Path == "" {
+		return fmt.Errorf("%w: topic lag query needs a topic path", ErrInvalidParameter)
+	}
+	if q.QueryFormat != QueryFormatTimeSeries && q.QueryFormat != QueryFormatTable {
+		return fmt.Errorf("%w: topic lag metrics are returned as time series or as a table, not as %s", ErrInvalidParameter, q.QueryFormat)
+	}
+	return nil
+}
+
+// topicLagMetrics are metrics of a partition of the topic read by a consumer
+type topicLagMetrics struct {
+	Consumer  string
+	Partition int64
+	// WriteRate is the average rate of bytes written to the partition per second during the last minute
+	WriteRate       float64
+	CommittedOffset int64
+	EndOffset       int64
+	// LagMessages is the number of messages written after the committed offset of the consumer
+	LagMessages int64
+	// LagSeconds is the maximal time between writes and reads of messages read during the last minute or,
+	// when the consumer stopped reading unread messages, the time since its last read
+	LagSeconds float64
+}
+
+// topicLagResponses returns the request without topic lag queries and responses of them, statistics
+// of consumers are read with the driver of the instance or of the user's identity
+func (ds *Datasource) topicLagResponses(ctx context.Context, req *backend.QueryDataRequest, models queryModels) (*backend.QueryDataRequest, backend.Responses) {
+	responses := backend.Responses{}
+	queries := make([]backend.DataQuery, 0, len(req.Queries))
+	for _, q := range req.Queries {
+		query, ok := models[q.RefID]
+		if !ok || query.QueryType != queryTypeTopicLag {
+			queries = append(queries, q)
+			continue
+		}
+		descriptions, err := ds.describeConsumers(ctx, *query.Topic)
+		if err != nil {
+			responses[q.RefID] = backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("topic lag: %s", err.Error()))
+			continue
+		}
+		now := time.Now()
+		responses[q.RefID] = backend.DataResponse{Frames: topicLagFrames(query.Topic.Path, topicLag(descriptions, now), now, query.QueryFormat)}
+	}
+	if len(responses) == 0 {
+		return req, responses
+	}
+	rest := *req
+	rest.Queries = queries
+	return &rest, responses
+}
+
+// describeConsumers describes the consumer of the topic with statistics of partitions or, without a consumer,
+// all consumers of the topic
+func (ds *Datasource) describeConsumers(ctx context.Context, options topicOptions) (descriptions []topictypes.TopicConsumerDescription, _ error) {
+	if ds.schema.settings == nil {
+		return nil, ds.schema.err
+	}
+	err := ds.schema.withDriver(ctx, func(ctx context.Context, db *ydb.Driver) error {
+		consumers := []string{options.Consumer}
+		if options.Consumer == "" {
+			description, err := db.Topic().Describe(ctx, options.Path)
+			if err != nil {
+				return err
+			}
+			consumers = consumers[:0]
+			for _, consumer := range description.Consumers {
+				consumers = append(consumers, consumer.Name)
+			}
+		}
+		for _, consumer := range consumers {
+			description, err := db.Topic().DescribeTopicConsumer(ctx, options.Path, consumer, topicoptions.IncludeConsumerStats())
+			if err != nil {
+				return err
+			}
+			descriptions = append(descriptions, description)
+		}
+		return nil
+	})
+	return descriptions, err
+}
+
+// topicLag computes metrics of partitions of consumers
+func topicLag(descriptions []topictypes.TopicConsumerDescription, now time.Time) []topicLagMetrics {
+	var metrics []topicLagMetrics
+	for _, description := range descriptions {
+		for _, partition := range description.Partitions {
+			stats, consumerStats := partition.PartitionStats, partition.PartitionConsumerStats
+			m := topicLagMetrics{
+				Consumer:        description.Consumer.Name,
+				Partition:       partition.PartitionID,
+				WriteRate:       float64(stats.BytesWritten.PerMinute) / 60,
+				CommittedOffset: consumerStats.CommittedOffset,
+				EndOffset:       stats.PartitionsOffset.End,
+				LagMessages:     max(stats.PartitionsOffset.End-consumerStats.CommittedOffset, 0),
+			}
+			if consumerStats.MaxReadTimeLag != nil {
+				m.LagSeconds = consumerStats.MaxReadTimeLag.Seconds()
+			}
+			if m.LagMessages > 0 && consumerStats.LastReadTime != nil {
+				m.LagSeconds = max(m.LagSeconds, now.Sub(*consumerStats.LastReadTime).Seconds())
+			}
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// topicLagFrames returns a time series frame per partition of consumers labeled by the topic, the consumer
+// and the partition or a table of metrics of all partitions
+func topicLagFrames(path string, metrics []topicLagMetrics, now time.Time, format string) data.Frames {
+	if format == QueryFormatTable {
+		frame := data.NewFrame("",
+			data.NewField(topicLagTopicField, nil, []string{}),
+			data.NewField(topicLagConsumerField, nil, []string{}),
+			data.NewField(topicLagPartitionField, nil, []int64{}),
+			topicLagField(topicLagWriteRate, nil, []float64{}),
+			topicLagField(topicLagCommitted, nil, []int64{}),
+			topicLagField(topicLagEndOffset, nil, []int64{}),
+			topicLagField(topicLagMessages, nil, []int64{}),
+			topicLagField(topicLagSeconds, nil, []float64{}),
+		)
+		for _, m := range metrics {
+			frame.AppendRow(path, m.Consumer, m.Partition, m.WriteRate, m.CommittedOffset, m.EndOffset, m.LagMessages, m.LagSeconds)
+		}
+		return data.Frames{frame}
+	}
+
+	frames := make(data.Frames, 0, len(metrics))
+	for _, m := range metrics {
+		labels := data.Labels{
+			topicLagTopicField:     path,
+			topicLagConsumerField:  m.Consumer,
+			topicLagPartitionField: strconv.FormatInt(m.Partition, 10),
+		}
+		frame := data.NewFrame("",
+			data.NewField("time", nil, []time.Time{now}),
+			topicLagField(topicLagWriteRate, labels, []float64{m.WriteRate}),
+			topicLagField(topicLagCommitted, labels, []int64{m.CommittedOffset}),
+			topicLagField(topicLagEndOffset, labels, []int64{m.EndOffset}),
+			topicLagField(topicLagMessages, labels, []int64{m.LagMessages}),
+			topicLagField(topicLagSeconds, labels, []float64{m.LagSeconds}),
+		)
+		frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesWide}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// topicLagField returns the field of the metric with its unit
+func topicLagField(name string, labels data.Labels, values any) *data.Field {
+	field := data.NewField(name, labels, values)
+	switch name {
+	case topicLagWriteRate:
+		field.SetConfig(&data.FieldConfig{Unit: "Bps"})
+	case topicLagSeconds:
+		field.SetConfig(&data.FieldConfig{Unit: "s"})
+	}
+	return field
+}
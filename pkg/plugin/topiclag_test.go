@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topictypes"
+)
+
+func TestTopicLag(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lastRead := now.Add(-time.Minute)
+	readLag := 2 * time.Second
+	metrics := topicLag([]topictypes.TopicConsumerDescription{{
+		Consumer: topictypes.Consumer{Name: "indexer"},
+		Partitions: []topictypes.DescribeConsumerPartitionInfo{
+			{
+				PartitionID: 0,
+				PartitionStats: topictypes.PartitionStats{
+					PartitionsOffset: topictypes.OffsetRange{End: 100},
+					BytesWritten:     topictypes.MultipleWindowsStat{PerMinute: 600},
+				},
+				PartitionConsumerStats: topictypes.PartitionConsumerStats{
+					CommittedOffset: 90, LastReadTime: &lastRead, MaxReadTimeLag: &readLag,
+				},
+			},
+			{
+				PartitionID:            1,
+				PartitionStats:         topictypes.PartitionStats{PartitionsOffset: topictypes.OffsetRange{End: 50}},
+				PartitionConsumerStats: topictypes.PartitionConsumerStats{CommittedOffset: 50, LastReadTime: &lastRead, MaxReadTimeLag: &readLag},
+			},
+		},
+	}}, now)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, topicLagMetrics{
+		Consumer: "indexer", Partition: 0, WriteRate: 10, CommittedOffset: 90, EndOffset: 100, LagMessages: 10, LagSeconds: 60,
+	}, metrics[0], "consumers which stopped reading lag since their last read")
+	assert.Equal(t, 2.0, metrics[1].LagSeconds)
+
+	frames := topicLagFrames("events", metrics, now, QueryFormatTimeSeries)
+	require.Len(t, frames, 2)
+	assert.Equal(t, data.FrameTypeTimeSeriesWide, frames[0].Meta.Type)
+	lag, _ := frames[0].FieldByName(topicLagMessages)
+	assert.Equal(t, data.Labels{"topic": "events", "consumer": "indexer", "partition": "0"}, lag.Labels)
+	assert.Equal(t, int64(10), lag.At(0))
+
+	frames = topicLagFrames("events", metrics, now, QueryFormatTable)
+	require.Len(t, frames, 1)
+	assert.Equal(t, 2, frames[0].Rows())
+	assert.Equal(t, "s", frames[0].Fields[7].Config.Unit)
+}
@@ -0,0 +1,538 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	ydb "github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicreader"
+)
+
+const queryTypeTopic = "topic"
+
+// Parsers of payloads of topic messages
+const (
+	TopicParserText = "text"
+	TopicParserJSON = "json"
+	TopicParserCDC  = "cdc"
+)
+
+// Names of fields of topic messages
+const (
+	topicTimeField      = "time"
+	topicPartitionField = "partition"
+	topicOffsetField    = "offset"
+	topicProducerField  = "producer"
+	topicMessageField   = "message"
+)
+
+// topicRetryInterval is the pause before the topic reader is reopened after a failure
+const topicRetryInterval = 5 * time.Second
+
+// topicOptions streams messages of a YDB topic
+type topicOptions struct {
+	Path string `json:"path,omitempty"`
+	// Table and Changefeed read the changefeed of the table instead of the topic of the path,
+	// records of changefeeds are decoded by the cdc parser
+	Table      string `json:"table,omitempty"`
+	Changefeed string `json:"changefeed,omitempty"`
+	// Consumer reads the topic from offsets of the consumer without committing them, without a consumer every
+	// subscription reads all messages. Readers of a consumer share its partitions, so the consumer must be
+	// dedicated to Grafana, a stream reading with the consumer of an application takes its partitions away
+	Consumer string `json:"consumer,omitempty"`
+	// StartFrom is an RFC 3339 time or a duration before the subscription like 15m, messages written earlier are skipped.
+	// By default streams of consumers start from their offsets and streams without consumers start from the subscription
+	StartFrom string `json:"startFrom,omitempty"`
+	// Parser of payloads, text by default and cdc for changefeeds
+	Parser string `json:"parser,omitempty"`
+	// Fields are dot separated paths of values of JSON payloads, keys of the first message by default
+	Fields []string `json:"fields,omitempty"`
+	// RateLimit is the maximal rate of messages per second, unlimited by default
+	RateLimit float64 `json:"rateLimit,omitempty"`
+	// Limit is the number of the last messages sent to new subscribers
+	Limit int `json:"limit,omitempty"`
+}
+
+// validateTopic checks the topic query, messages are streamed as a table or as logs
+func (q *queryModel) validateTopic() error {
+	t := q.Topic
+	if t != nil && t.Changefeed != "" {
+		if t.Table == "" {
+			return fmt.Errorf("%w: changefeed %q needs a table", ErrInvalidParameter, t.Changefeed)
+		}
+		if t.Parser == "" {
+			t.Parser = TopicParserCDC
+		}
+	}
+	if t == nil || (t.Path == "" && t.Changefeed == "") {
+		return fmt.Errorf("%w: topic query needs a topic path", ErrInvalidParameter)
+	}
+	if t.Parser != "" && t.Parser != TopicParserText && t.Parser != TopicParserJSON && t.Parser != TopicParserCDC {
+		return fmt.Errorf("%w: topic parser %q", ErrInvalidParameter, t.Parser)
+	}
+	if (t.Parser == TopicParserCDC) != (t.Changefeed != "") {
+		return fmt.Errorf("%w: the cdc parser reads only changefeeds of tables", ErrInvalidParameter)
+	}
+	if _, err := t.startFrom(time.Now()); err != nil {
+		return err
+	}
+	if t.RateLimit < 0 || t.Limit < 0 {
+		return fmt.Errorf("%w: topic rate limit %g and limit %d", ErrInvalidParameter, t.RateLimit, t.Limit)
+	}
+	if t.Limit > maxStreamRows {
+		t.Limit = maxStreamRows
+	}
+	if q.QueryFormat != QueryFormatTable && q.QueryFormat != QueryFormatLogs {
+		return fmt.Errorf("%w: topic messages are streamed as a table or as logs, not as %s", ErrInvalidParameter, q.QueryFormat)
+	}
+	return nil
+}
+
+// startFrom returns the time of the first message read, zero when the reader starts from offsets of the consumer
+func (t topicOptions) startFrom(now time.Time) (time.Time, error) {
+	if t.StartFrom == "" {
+		if t.Consumer != "" {
+			return time.Time{}, nil
+		}
+		return now, nil
+	}
+	if start, err := time.Parse(time.RFC3339, t.StartFrom); err == nil {
+		return start, nil
+	}
+	d, err := time.ParseDuration(t.StartFrom)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("%w: topic startFrom %q, an RFC 3339 time or a duration like 15m", ErrInvalidParameter, t.StartFrom)
+	}
+	return now.Add(-d), nil
+}
+
+// topicMessage is a message of a topic with its payload
+type topicMessage struct {
+	PartitionID int64
+	Offset      int64
+	ProducerID  string
+	WrittenAt   time.Time
+	Data        []byte
+}
+
+// topicReader reads batches of messages of a topic
+type topicReader interface {
+	ReadMessages(ctx context.Context) ([]topicMessage, error)
+	Close(ctx context.Context) error
+}
+
+type openTopicFunc func(ctx context.Context, startFrom time.Time) (topicReader, error)
+
+// ydbTopicReader reads messages with the topic reader of ydb-go-sdk, the driver of the user is closed with the reader
+type ydbTopicReader struct {
+	reader      *topicreader.Reader
+	closeDriver func()
+}
+
+func (r ydbTopicReader) ReadMessages(ctx context.Context) ([]topicMessage, error) {
+	batch, err := r.reader.ReadMessagesBatch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]topicMessage, 0, len(batch.Messages))
+	for _, m := range batch.Messages {
+		payload, err := io.ReadAll(m)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, topicMessage{
+			PartitionID: m.PartitionID(),
+			Offset:      m.Offset,
+			ProducerID:  m.ProducerID,
+			WrittenAt:   m.WrittenAt,
+			Data:        payload,
+		})
+	}
+	return messages, nil
+}
+
+func (r ydbTopicReader) Close(ctx context.Context) error {
+	err := r.reader.Close(ctx)
+	r.closeDriver()
+	return err
+}
+
+// topicDriver returns the driver of the instance or, with connection arguments of the user's identity,
+// the driver of the user which is kept until the returned function is called
+func (ds *Datasource) topicDriver(ctx context.Context, connectionArgs json.RawMessage) (*ydb.Driver, func(), error) {
+	settings := ds.schema.settings
+	if settings == nil {
+		return nil, nil, ds.schema.err
+	}
+	if len(connectionArgs) == 0 {
+		driver, err := ds.schema.instanceDriver(ctx)
+		return driver, func() {}, err
+	}
+	id, release, err := ds.ydb.identities.acquire(connectionArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	driver, err := id.ydbDriver(ctx, settings)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return driver, release, nil
+}
+
+// openTopic opens readers of the topic or of the changefeed of the table with the driver of the instance or,
+// with connection arguments of the user's identity, with a driver of the user. Offsets of consumers are never committed
+func (ds *Datasource) openTopic(options topicOptions, connectionArgs json.RawMessage) openTopicFunc {
+	return func(ctx context.Context, startFrom time.Time) (topicReader, error) {
+		topicPath, err := ds.topicPath(options)
+		if err != nil {
+			return nil, err
+		}
+		driver, closeDriver, err := ds.topicDriver(ctx, connectionArgs)
+		if err != nil {
+			return nil, err
+		}
+
+		opts := []topicoptions.ReaderOption{topicoptions.WithReaderCommitMode(topicoptions.CommitModeNone)}
+		if options.Consumer == "" {
+			opts = append(opts, topicoptions.WithReaderWithoutConsumer(false))
+		}
+		selectors := topicoptions.ReadSelectors{{Path: topicPath, ReadFrom: startFrom}}
+		reader, err := driver.Topic().StartReader(options.Consumer, selectors, opts...)
+		if err != nil {
+			closeDriver()
+			return nil, err
+		}
+		return ydbTopicReader{reader: reader, closeDriver: closeDriver}, nil
+	}
+}
+
+// topicPath returns the path of the topic or of the changefeed of the table
+func (ds *Datasource) topicPath(options topicOptions) (string, error) {
+	if options.Changefeed == "" {
+		return options.Path, nil
+	}
+	tablePath, err := ds.schema.tablePath(options.Table)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(tablePath, options.Changefeed), nil
+}
+
+// topicStream reads messages of the topic and sends them to subscribers. Messages read again after
+// reconnections are skipped by offsets of partitions
+type topicStream struct {
+	streamBroadcast
+	streamLoop
+	open openTopicFunc
+	// describe reads columns of changefeeds before the first reader is opened
+	describe describeChangefeedFunc
+	options  topicOptions
+	// path is the path of the topic or of the changefeed in logs
+	path    string
+	parser  *topicParser
+	convert func(*data.Frame) *data.Frame
+	offsets map[int64]int64
+}
+
+func newTopicStream(open openTopicFunc, options topicOptions, format string) *topicStream {
+	s := &topicStream{
+		streamBroadcast: newStreamBroadcast(options.Limit),
+		open:            open,
+		options:         options,
+		path:            options.Path,
+		parser:          newTopicParser(options),
+		offsets:         make(map[int64]int64),
+	}
+	if options.Changefeed != "" {
+		s.path = path.Join(options.Table, options.Changefeed)
+	}
+	if format == QueryFormatLogs {
+		s.convert = func(frame *data.Frame) *data.Frame {
+			return logsFrame(frame, logColumns{time: topicTimeField, body: topicMessageField})
+		}
+	}
+	return s
+}
+
+func (s *topicStream) start() {
+	startFrom, _ := s.options.startFrom(time.Now())
+	s.run(func(ctx context.Context) {
+		log.DefaultLogger.Info("Topic stream started", "topic", s.path)
+		defer log.DefaultLogger.Info("Topic stream stopped", "topic", s.path)
+		for ctx.Err() == nil {
+			err := s.describeChangefeed(ctx)
+			var reader topicReader
+			if err == nil {
+				reader, err = s.open(ctx, startFrom)
+			}
+			if err == nil {
+				err = s.read(ctx, reader)
+				reader.Close(context.Background())
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.DefaultLogger.Warn("Topic read failed", "topic", s.path, "error", err.Error())
+			select {
+			case <-ctx.Done():
+			case <-time.After(topicRetryInterval):
+			}
+		}
+	})
+}
+
+// describeChangefeed sets columns of records of the changefeed to the parser
+func (s *topicStream) describeChangefeed(ctx context.Context) error {
+	if s.describe == nil || s.parser.changefeed != nil {
+		return nil
+	}
+	columns, err := s.describe(ctx)
+	if err != nil {
+		return err
+	}
+	s.parser.changefeed = &columns
+	return nil
+}
+
+// read sends batches of messages until the reader fails, messages waiting for the rate limit
+// are sent after messages read before them
+func (s *topicStream) read(ctx context.Context, reader topicReader) error {
+	limiter := newRateLimiter(s.options.RateLimit)
+	for {
+		messages, err := reader.ReadMessages(ctx)
+		if err != nil {
+			return err
+		}
+		batch := make([]topicMessage, 0, len(messages))
+		for _, m := range messages {
+			if offset, ok := s.offsets[m.PartitionID]; ok && m.Offset <= offset {
+				continue
+			}
+			s.offsets[m.PartitionID] = m.Offset
+			if delay := limiter.reserve(time.Now()); delay > 0 {
+				s.send(batch)
+				batch = batch[:0]
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+			}
+			batch = append(batch, m)
+		}
+		s.send(batch)
+	}
+}
+
+func (s *topicStream) send(messages []topicMessage) {
+	if len(messages) == 0 {
+		return
+	}
+	frame := s.parser.frame(messages)
+	if s.convert != nil {
+		frame = s.convert(frame)
+	}
+	s.publish(frame)
+}
+
+// rateLimiter spaces messages evenly to the rate per second
+type rateLimiter struct {
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rate float64) *rateLimiter {
+	if rate <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// reserve returns the delay before the next message may be sent
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	if l.interval == 0 {
+		return 0
+	}
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return delay
+}
+
+// topicParser converts messages to frames, payloads are kept as text or values of fields are extracted
+// from JSON payloads and changefeed records. Fields keep types of their first values, so frames of a stream
+// keep their schema
+type topicParser struct {
+	json       bool
+	fields     []string
+	changefeed *changefeedColumns
+	types      map[string]data.FieldType
+}
+
+func newTopicParser(options topicOptions) *topicParser {
+	return &topicParser{
+		json:   options.Parser == TopicParserJSON,
+		fields: options.Fields,
+		types:  make(map[string]data.FieldType),
+	}
+}
+
+func (p *topicParser) frame(messages []topicMessage) *data.Frame {
+	times := make([]time.Time, len(messages))
+	partitions := make([]int64, len(messages))
+	offsets := make([]int64, len(messages))
+	producers := make([]string, len(messages))
+	for i, m := range messages {
+		times[i], partitions[i], offsets[i], producers[i] = m.WrittenAt, m.PartitionID, m.Offset, m.ProducerID
+	}
+	frame := data.NewFrame("",
+		data.NewField(topicTimeField, nil, times),
+		data.NewField(topicPartitionField, nil, partitions),
+		data.NewField(topicOffsetField, nil, offsets),
+		data.NewField(topicProducerField, nil, producers),
+	)
+	if !p.json {
+		payloads := make([]string, len(messages))
+		for i, m := range messages {
+			payloads[i] = string(m.Data)
+		}
+		frame.Fields = append(frame.Fields, data.NewField(topicMessageField, nil, payloads))
+		if p.changefeed != nil {
+			p.changefeedFields(frame, messages)
+		}
+		return frame
+	}
+
+	values := make([]map[string]any, len(messages))
+	for i, m := range messages {
+		_ = decodeJSON(m.Data, &values[i])
+		if len(p.fields) == 0 && len(values[i]) > 0 {
+			for key := range values[i] {
+				p.fields = append(p.fields, key)
+			}
+			sort.Strings(p.fields)
+		}
+	}
+	for _, name := range p.fields {
+		column := make([]any, len(messages))
+		for i := range messages {
+			column[i] = jsonPath(values[i], name)
+		}
+		frame.Fields = append(frame.Fields, p.field(name, column))
+	}
+	return frame
+}
+
+// field returns the field of values of the JSON path, the type is the type of the first value of the path.
+// Integers are kept as int64 or, beyond it, as uint64, values not of the type of the field are null
+func (p *topicParser) field(name string, values []any) *data.Field {
+	fieldType, ok := p.types[name]
+	if !ok {
+		fieldType = data.FieldTypeNullableString
+		if i := slices.IndexFunc(values, func(v any) bool { return v != nil }); i >= 0 {
+			switch v := values[i].(type) {
+			case json.Number:
+				fieldType = numberType(v)
+			case bool:
+				fieldType = data.FieldTypeNullableBool
+			}
+			p.types[name] = fieldType
+		}
+	}
+	field := data.NewFieldFromFieldType(fieldType, len(values))
+	field.Name = name
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		switch fieldType {
+		case data.FieldTypeNullableInt64:
+			if n, err := strconv.ParseInt(numberText(value), 10, 64); err == nil {
+				field.Set(i, &n)
+			}
+		case data.FieldTypeNullableUint64:
+			if n, err := strconv.ParseUint(numberText(value), 10, 64); err == nil {
+				field.Set(i, &n)
+			}
+		case data.FieldTypeNullableFloat64:
+			if f, err := strconv.ParseFloat(numberText(value), 64); err == nil {
+				field.Set(i, &f)
+			}
+		case data.FieldTypeNullableBool:
+			if v, ok := value.(bool); ok {
+				field.Set(i, &v)
+			}
+		default:
+			s, ok := value.(string)
+			if !ok {
+				text, _ := json.Marshal(value)
+				s = string(text)
+			}
+			field.Set(i, &s)
+		}
+	}
+	return field
+}
+
+// numberType returns the type of fields of the JSON number, integers beyond int64 are uint64
+func numberType(n json.Number) data.FieldType {
+	if _, err := n.Int64(); err == nil {
+		return data.FieldTypeNullableInt64
+	}
+	if _, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		return data.FieldTypeNullableUint64
+	}
+	return data.FieldTypeNullableFloat64
+}
+
+// numberText returns the text of the JSON number or of the string holding a number
+func numberText(value any) string {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case string:
+		return v
+	}
+	return ""
+}
+
+// decodeJSON decodes the JSON payload keeping numbers as json.Number, so 64-bit integers aren't rounded to float64
+func decodeJSON(payload []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("%w: data after the JSON value", ErrInvalidParameter)
+	}
+	return nil
+}
+
+// jsonPath returns the value of the dot separated path of the JSON object
+func jsonPath(object map[string]any, path string) any {
+	var value any = object
+	for _, key := range strings.Split(path, ".") {
+		values, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = values[key]
+	}
+	return value
+}
@@ -0,0 +1,200 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/sqlds/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicoptions"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topictypes"
+	"github.com/ydb-platform/ydb-go-sdk/v3/topic/topicwriter"
+)
+
+var errTopicClosed = errors.New("topic closed")
+
+// fakeTopic stands in for a topic in unit tests, readers read written batches and then fail.
+// TestYdbTopic reads a real topic of a local YDB container
+type fakeTopic struct {
+	batches [][]topicMessage
+	opened  []time.Time
+}
+
+func (t *fakeTopic) open(_ context.Context, startFrom time.Time) (topicReader, error) {
+	t.opened = append(t.opened, startFrom)
+	return &fakeTopicReader{batches: t.batches}, nil
+}
+
+type fakeTopicReader struct {
+	batches [][]topicMessage
+}
+
+func (r *fakeTopicReader) ReadMessages(context.Context) ([]topicMessage, error) {
+	if len(r.batches) == 0 {
+		return nil, errTopicClosed
+	}
+	batch := r.batches[0]
+	r.batches = r.batches[1:]
+	return batch, nil
+}
+
+func (r *fakeTopicReader) Close(context.Context) error {
+	return nil
+}
+
+func TestParseQueryModelTopic(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"version":1,"queryType":"topic","queryFormat":"logs",
+		"topic":{"path":"events","startFrom":"15m","limit":20000}}`))
+	require.NoError(t, err)
+	assert.Equal(t, maxStreamRows, query.Topic.Limit)
+	now := time.Now()
+	start, err := query.Topic.startFrom(now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-15*time.Minute), start)
+
+	start, err = topicOptions{Path: "events", Consumer: "grafana"}.startFrom(now)
+	require.NoError(t, err)
+	assert.True(t, start.IsZero(), "consumers start from their offsets")
+
+	_, invalid, _ := validQueries(&backend.QueryDataRequest{Queries: []backend.DataQuery{{
+		RefID: "A",
+		JSON:  []byte(`{"version":1,"queryType":"topic","topic":{"path":"events"}}`),
+	}}})
+	assert.Contains(t, invalid["A"].Error.Error(), "Grafana Live")
+}
+
+func TestTopicParser(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	parser := newTopicParser(topicOptions{Parser: TopicParserJSON})
+	frame := parser.frame([]topicMessage{
+		{PartitionID: 1, Offset: 10, ProducerID: "api", WrittenAt: t0, Data: []byte(`{"user":{"id":"u1"},"latency":12.5,"ok":true}`)},
+		{PartitionID: 1, Offset: 11, ProducerID: "api", WrittenAt: t0, Data: []byte(`not json`)},
+	})
+	assert.Equal(t, []string{"time", "partition", "offset", "producer", "latency", "ok", "user"}, fieldNames(frame))
+	assert.Equal(t, 12.5, *frame.Fields[4].At(0).(*float64))
+	assert.Nil(t, frame.Fields[4].At(1))
+	assert.Equal(t, `{"id":"u1"}`, *frame.Fields[6].At(0).(*string))
+
+	parser = newTopicParser(topicOptions{Parser: TopicParserJSON, Fields: []string{"user.id", "latency"}})
+	frame = parser.frame([]topicMessage{{Data: []byte(`{"user":{"id":"u1"}}`)}})
+	assert.Equal(t, data.FieldTypeNullableString, frame.Fields[5].Type(), "fields without values are strings")
+	frame = parser.frame([]topicMessage{{Data: []byte(`{"user":{"id":"u2"},"latency":"7"}`)}, {Data: []byte(`{"latency":3}`)}})
+	assert.Equal(t, "u2", *frame.Fields[4].At(0).(*string))
+	assert.Nil(t, frame.Fields[4].At(1))
+	assert.Equal(t, data.FieldTypeNullableString, frame.Fields[5].Type(), "types of first values are kept")
+	assert.Equal(t, []any{"7", "3"}, concreteValues(frame.Fields[5]))
+
+	parser = newTopicParser(topicOptions{Parser: TopicParserJSON, Fields: []string{"id", "balance", "ratio"}})
+	frame = parser.frame([]topicMessage{
+		{Data: []byte(`{"id":9007199254740993,"balance":-9223372036854775808,"ratio":1.5,"user":{"id":9007199254740993}}`)},
+		{Data: []byte(`{"id":"7","balance":2.5,"ratio":2}`)},
+	})
+	assert.Equal(t, []any{int64(9007199254740993), int64(7)}, concreteValues(frame.Fields[4]), "integers aren't rounded")
+	assert.Equal(t, int64(-9223372036854775808), *frame.Fields[5].At(0).(*int64))
+	assert.Nil(t, frame.Fields[5].At(1), "fractions aren't integers")
+	assert.Equal(t, []any{1.5, 2.0}, concreteValues(frame.Fields[6]))
+	assert.Error(t, decodeJSON([]byte(`{"id":1} {}`), &map[string]any{}))
+
+	frame = newTopicParser(topicOptions{}).frame([]topicMessage{{Data: []byte("started")}})
+	assert.Equal(t, []any{"started"}, concreteValues(frame.Fields[4]))
+}
+
+func TestTopicStream(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	topic := &fakeTopic{batches: [][]topicMessage{
+		{{PartitionID: 0, Offset: 1, WrittenAt: t0, Data: []byte("a")}, {PartitionID: 1, Offset: 1, WrittenAt: t0, Data: []byte("b")}},
+		{{PartitionID: 0, Offset: 2, WrittenAt: t0, Data: []byte("c")}},
+	}}
+	stream := newTopicStream(topic.open, topicOptions{Path: "events", Limit: 2}, QueryFormatLogs)
+	subscriber := &packets{}
+	stream.subscribe(backend.NewStreamSender(subscriber))
+
+	for i := 0; i < 2; i++ {
+		reader, err := topic.open(context.Background(), t0)
+		require.NoError(t, err)
+		assert.ErrorIs(t, stream.read(context.Background(), reader), errTopicClosed)
+	}
+	require.Len(t, subscriber.data, 2, "messages read again after reconnections are skipped")
+	assert.Equal(t, data.FrameTypeLogLines, subscriber.frame(t, 0).Meta.Type)
+	assert.Equal(t, []any{"a", "b"}, concreteValues(subscriber.frame(t, 0).Fields[1]))
+
+	late := &packets{}
+	stream.subscribe(backend.NewStreamSender(late))
+	assert.Equal(t, []any{"b", "c"}, concreteValues(late.frame(t, 0).Fields[1]))
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := newRateLimiter(4)
+	assert.Equal(t, time.Duration(0), limiter.reserve(now))
+	assert.Equal(t, 250*time.Millisecond, limiter.reserve(now))
+	assert.Equal(t, 400*time.Millisecond, limiter.reserve(now.Add(100*time.Millisecond)))
+	assert.Equal(t, time.Duration(0), limiter.reserve(now.Add(time.Second)))
+	assert.Equal(t, time.Duration(0), newRateLimiter(0).reserve(now))
+}
+
+// TestYdbTopic writes and reads a topic of a local YDB container, like
+// docker run -d -p 2136:2136 ydbplatform/local-ydb
+// with YDB_TEST_ENDPOINT=grpc://localhost:2136 and YDB_TEST_DATABASE=/local by default
+func TestYdbTopic(t *testing.T) {
+	endpoint := os.Getenv("YDB_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("YDB_TEST_ENDPOINT is not set")
+	}
+	database := os.Getenv("YDB_TEST_DATABASE")
+	if database == "" {
+		database = "/local"
+	}
+	ydbDriver := &Ydb{}
+	schema := NewSchema(backend.DataSourceInstanceSettings{
+		JSONData: []byte(fmt.Sprintf(`{"authKind":"Anonymous","endpoint":%q,"dbLocation":%q}`, endpoint, database)),
+	})
+	require.NoError(t, schema.err)
+	ds := NewDatasource(sqlds.NewDatasource(ydbDriver), ydbDriver, schema)
+	defer ds.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	driver, err := schema.instanceDriver(ctx)
+	require.NoError(t, err)
+	topicPath := fmt.Sprintf("grafana_test_%d", time.Now().UnixNano())
+	require.NoError(t, driver.Topic().Create(ctx, topicPath, topicoptions.CreateWithConsumer(topictypes.Consumer{Name: "grafana"})))
+	defer driver.Topic().Drop(context.Background(), topicPath)
+
+	writer, err := driver.Topic().StartWriter(topicPath,
+		topicoptions.WithWriterProducerID("api"), topicoptions.WithWriterWaitServerAck(true))
+	require.NoError(t, err)
+	payloads := []string{`{"user":"u1"}`, `{"user":"u2"}`}
+	for _, payload := range payloads {
+		require.NoError(t, writer.Write(ctx, topicwriter.Message{Data: strings.NewReader(payload)}))
+	}
+	require.NoError(t, writer.Close(ctx))
+
+	for _, consumer := range []string{"", "grafana"} {
+		t.Run("consumer="+consumer, func(t *testing.T) {
+			reader, err := ds.openTopic(topicOptions{Path: topicPath, Consumer: consumer}, nil)(ctx, time.Time{})
+			require.NoError(t, err)
+			defer reader.Close(context.Background())
+			var messages []topicMessage
+			for len(messages) < len(payloads) {
+				batch, err := reader.ReadMessages(ctx)
+				require.NoError(t, err)
+				messages = append(messages, batch...)
+			}
+			for i, m := range messages {
+				assert.Equal(t, payloads[i], string(m.Data))
+				assert.Equal(t, int64(i), m.Offset)
+				assert.Equal(t, "api", m.ProducerID)
+				assert.False(t, m.WrittenAt.IsZero())
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+)
+
+const queryTypeTraces = "traces"
+
+// traceTag is a key-value pair of tags of spans in the Grafana trace view
+type traceTag struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// validateTraces checks the traces query, spans of a trace are returned in the traces format
+// and found traces are returned as a table
+func (q *queryModel) validateTraces() error {
+	t := q.Traces
+	if t == nil || t.Table == "" || t.Columns.TraceID == "" || t.Columns.StartTime == "" {
+		return fmt.Errorf("%w: traces query needs a table with trace id and start time columns", ErrInvalidParameter)
+	}
+	if _, ok := t.DurationUnitLength(); !ok {
+		return fmt.Errorf("%w: durationUnit %q", ErrInvalidParameter, t.DurationUnit)
+	}
+	switch t.Mode {
+	case "", builder.TraceModeTrace:
+		if t.TraceID == "" || t.Columns.SpanID == "" {
+			return fmt.Errorf("%w: trace needs a trace id and a span id column", ErrInvalidParameter)
+		}
+		q.QueryFormat = QueryFormatTraces
+	case builder.TraceModeSearch:
+		if t.Limit < 0 {
+			return fmt.Errorf("%w: trace search limit %d", ErrInvalidParameter, t.Limit)
+		}
+		q.QueryFormat = QueryFormatTable
+	default:
+		return fmt.Errorf("%w: traces mode %q", ErrInvalidParameter, t.Mode)
+	}
+	return nil
+}
+
+// traceFrame converts spans to the trace frame of Grafana, start times are milliseconds since the epoch and
+// durations are milliseconds. Tags are read from the Json object of the tags column and from other columns.
+func traceFrame(frame *data.Frame, unit time.Duration) (*data.Frame, error) {
+	index := func(name string) int {
+		return logFieldIndex(frame, []string{name})
+	}
+	traceIndex, spanIndex := index(builder.TraceIDColumn), index(builder.SpanIDColumn)
+	if traceIndex < 0 || spanIndex < 0 {
+		return nil, fmt.Errorf("%w: traces need %s and %s columns", ErrInvalidParameter, builder.TraceIDColumn, builder.SpanIDColumn)
+	}
+	parentIndex, serviceIndex := index(builder.ParentSpanIDColumn), index(builder.ServiceNameColumn)
+	operationIndex, startIndex := index(builder.OperationNameColumn), index(builder.StartTimeColumn)
+	durationIndex, tagsIndex := index(builder.DurationColumn), index(builder.TagsColumn)
+	mapped := []int{traceIndex, spanIndex, parentIndex, serviceIndex, operationIndex, startIndex, durationIndex, tagsIndex}
+
+	stringAt := func(i int, row int) string {
+		if i < 0 {
+			return ""
+		}
+		return fieldString(frame.Fields[i], row)
+	}
+	trace := data.NewFrame(frame.Name,
+		data.NewField(builder.TraceIDColumn, nil, []string{}),
+		data.NewField(builder.SpanIDColumn, nil, []string{}),
+		data.NewField(builder.ParentSpanIDColumn, nil, []string{}),
+		data.NewField(builder.OperationNameColumn, nil, []string{}),
+		data.NewField(builder.ServiceNameColumn, nil, []string{}),
+		data.NewField("serviceTags", nil, []json.RawMessage{}),
+		data.NewField(builder.StartTimeColumn, nil, []float64{}),
+		data.NewField(builder.DurationColumn, nil, []float64{}),
+		data.NewField(builder.TagsColumn, nil, []json.RawMessage{}),
+	)
+	for row := 0; row < frame.Fields[traceIndex].Len(); row++ {
+		var start, duration float64
+		if startIndex >= 0 {
+			start = epochMilliseconds(frame.Fields[startIndex], row)
+		}
+		if durationIndex >= 0 {
+			d, _ := strconv.ParseFloat(stringAt(durationIndex, row), 64)
+			duration = d * float64(unit) / float64(time.Millisecond)
+		}
+		tags := spanTags(stringAt(tagsIndex, row))
+		for i, field := range frame.Fields {
+			if slices.Contains(mapped, i) {
+				continue
+			}
+			if value, ok := field.ConcreteAt(row); ok {
+				tags = append(tags, traceTag{Key: field.Name, Value: value})
+			}
+		}
+		tagsJSON, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		trace.AppendRow(stringAt(traceIndex, row), stringAt(spanIndex, row), stringAt(parentIndex, row),
+			stringAt(operationIndex, row), stringAt(serviceIndex, row), json.RawMessage(`[]`),
+			start, duration, json.RawMessage(tagsJSON))
+	}
+	trace.RefID = frame.RefID
+	trace.Meta = frame.Meta
+	if trace.Meta == nil {
+		trace.Meta = &data.FrameMeta{}
+	}
+	trace.Meta.PreferredVisualization = data.VisTypeTrace
+	return trace, nil
+}
+
+// spanTags reads tags from the Json object, values of tags are kept as is
+func spanTags(tagsJSON string) []traceTag {
+	var values map[string]any
+	if err := json.Unmarshal([]byte(tagsJSON), &values); err != nil {
+		return []traceTag{}
+	}
+	tags := make([]traceTag, 0, len(values))
+	for key, value := range values {
+		tags = append(tags, traceTag{Key: key, Value: value})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	return tags
+}
+
+// epochMilliseconds returns milliseconds since the epoch of the time or the number
+func epochMilliseconds(field *data.Field, row int) float64 {
+	value, ok := field.ConcreteAt(row)
+	if !ok {
+		return 0
+	}
+	if t, ok := value.(time.Time); ok {
+		return float64(t.UnixMicro()) / 1000
+	}
+	ms, _ := strconv.ParseFloat(fmt.Sprint(value), 64)
+	return ms
+}
+
+// traceSearchFrame converts durations of found traces to milliseconds and links trace ids to their traces
+func traceSearchFrame(frame *data.Frame, query queryModel, settings *backend.DataSourceInstanceSettings) *data.Frame {
+	unit, _ := query.Traces.DurationUnitLength()
+	for i, field := range frame.Fields {
+		switch field.Name {
+		case builder.DurationColumn:
+			durations := make([]*float64, field.Len())
+			for row := range durations {
+				if d, err := strconv.ParseFloat(fieldString(field, row), 64); err == nil {
+					ms := d * float64(unit) / float64(time.Millisecond)
+					durations[row] = &ms
+				}
+			}
+			frame.Fields[i] = data.NewField(field.Name, field.Labels, durations).SetConfig(&data.FieldConfig{Unit: "ms"})
+		case builder.TraceIDColumn:
+			if settings == nil {
+				continue
+			}
+			traces := *query.Traces
+			traces.Mode, traces.TraceID = builder.TraceModeTrace, "${__value.raw}"
+			field.SetConfig(&data.FieldConfig{Links: []data.DataLink{{
+				Title: "Trace: ${__value.raw}",
+				Internal: &data.InternalDataLink{
+					DatasourceUID:  settings.UID,
+					DatasourceName: settings.Name,
+					Query: map[string]any{
+						"version":     queryModelVersion,
+						"queryType":   queryTypeTraces,
+						"queryFormat": QueryFormatTraces,
+						"traces":      traces,
+					},
+				},
+			}}})
+		}
+	}
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	frame.Meta.PreferredVisualization = data.VisTypeTable
+	return frame
+}
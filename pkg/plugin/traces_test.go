@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTraceFrame(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 1500000, time.UTC)
+	frame := data.NewFrame("A",
+		data.NewField("traceID", nil, []string{"t1", "t1"}),
+		data.NewField("spanID", nil, []string{"s1", "s2"}),
+		data.NewField("parentSpanID", nil, []*string{nil, ptr("s1")}),
+		data.NewField("startTime", nil, []time.Time{start, start}),
+		data.NewField("duration", nil, []uint64{2500, 1000}),
+		data.NewField("tags", nil, []string{`{"http.status":200,"b":"x"}`, ``}),
+		data.NewField("host", nil, []*string{ptr("a"), nil}),
+	)
+
+	trace, err := traceFrame(frame, time.Microsecond)
+	require.NoError(t, err)
+	assert.Equal(t, data.VisType(data.VisTypeTrace), trace.Meta.PreferredVisualization)
+	require.Equal(t, 2, trace.Rows())
+	row := trace.RowCopy(0)
+	assert.Equal(t, []any{"t1", "s1", "", "", ""}, row[:5])
+	assert.Equal(t, float64(start.UnixMilli())+0.5, row[6])
+	assert.Equal(t, 2.5, row[7])
+	assert.JSONEq(t, `[{"key":"b","value":"x"},{"key":"http.status","value":200},{"key":"host","value":"a"}]`, string(row[8].(json.RawMessage)))
+	assert.Equal(t, "s1", trace.Fields[2].At(1))
+	assert.JSONEq(t, `[]`, string(trace.Fields[8].At(1).(json.RawMessage)))
+
+	_, err = traceFrame(data.NewFrame("A", data.NewField("traceID", nil, []string{"t1"})), time.Millisecond)
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestParseQueryModelTraces(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"version":1,"queryType":"traces","queryFormat":"table","traces":{"table":"spans",
+		"traceId":"t1","columns":{"traceId":"trace_id","spanId":"span_id","startTime":"start"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, QueryFormatTraces, query.QueryFormat)
+
+	query, err = parseQueryModel([]byte(`{"version":1,"queryType":"traces","traces":{"table":"spans","mode":"search",
+		"columns":{"traceId":"trace_id","startTime":"start"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, QueryFormatTable, query.QueryFormat)
+}
+
+func TestTraceSearchFrame(t *testing.T) {
+	query, err := parseQueryModel([]byte(`{"version":1,"queryType":"traces","traces":{"table":"spans","mode":"search",
+		"durationUnit":"us","columns":{"traceId":"trace_id","spanId":"span_id","startTime":"start"}}}`))
+	require.NoError(t, err)
+	frame := data.NewFrame("A",
+		data.NewField("traceID", nil, []string{"t1"}),
+		data.NewField("duration", nil, []*int64{ptr(int64(1500))}),
+	)
+
+	frame = traceSearchFrame(frame, query, &backend.DataSourceInstanceSettings{UID: "ydb", Name: "YDB"})
+	assert.Equal(t, 1.5, *frame.Fields[1].At(0).(*float64))
+	link := frame.Fields[0].Config.Links[0]
+	assert.Equal(t, "ydb", link.Internal.DatasourceUID)
+	linkQuery, err := json.Marshal(link.Internal.Query)
+	require.NoError(t, err)
+	linked, err := parseQueryModel(linkQuery)
+	require.NoError(t, err)
+	assert.Equal(t, "${__value.raw}", linked.Traces.TraceID)
+	assert.Equal(t, QueryFormatTraces, linked.QueryFormat)
+}
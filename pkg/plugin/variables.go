@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+)
+
+// maxVariableValues caps values of a variable query when the query doesn't set a lower limit
+const maxVariableValues = 10000
+
+const (
+	variableTextField  = "__text"
+	variableValueField = "__value"
+)
+
+// VariableValue is a value of a template variable, multi-value variables have several values
+type VariableValue []string
+
+func (v *VariableValue) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err == nil {
+		*v = VariableValue{value}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("%w: variable value %s", ErrInvalidParameter, b)
+	}
+	*v = values
+	return nil
+}
+
+// variableReference matches $name, ${name} and [[name]] references of template variables
+var variableReference = regexp.MustCompile(`\$\{(\w+)\}|\[\[(\w+)\]\]|\$(\w+)`)
+
+// namedExpression matches declarations of YQL named expressions, actions, subqueries and parameters,
+// named expressions are assigned by statements
+var namedExpression = regexp.MustCompile(`(?i)\b(?:DECLARE|DEFINE\s+ACTION|DEFINE\s+SUBQUERY)\s+\$(\w+)|(?:^|;)\s*\$(\w+)\s*=[^=]`)
+
+// interpolateVariables replaces references of the variables with their quoted values, so a variable query
+// can depend on values of other variables. $name references of named expressions and parameters
+// declared by the query are kept, even when a variable has the same name
+func interpolateVariables(rawSql string, variables map[string]VariableValue) string {
+	if len(variables) == 0 {
+		return rawSql
+	}
+	declared := map[string]bool{}
+	for _, match := range namedExpression.FindAllStringSubmatch(rawSql, -1) {
+		declared[match[1]+match[2]] = true
+	}
+	return variableReference.ReplaceAllStringFunc(rawSql, func(reference string) string {
+		match := variableReference.FindStringSubmatch(reference)
+		if match[3] != "" && declared[match[3]] {
+			return reference
+		}
+		values, ok := variables[match[1]+match[2]+match[3]]
+		if !ok {
+			return reference
+		}
+		if len(values) == 0 {
+			return `""`
+		}
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = builder.QuoteString(value)
+		}
+		return strings.Join(quoted, ",")
+	})
+}
+
+// variableResponses turns frames of variable queries into __text and __value pairs
+func variableResponses(models queryModels, resp *backend.QueryDataResponse) {
+	if resp == nil {
+		return
+	}
+	for refID, query := range models {
+		if !query.VariableQuery {
+			continue
+		}
+		response, ok := resp.Responses[refID]
+		if !ok || response.Error != nil || len(response.Frames) == 0 {
+			continue
+		}
+		limit := maxVariableValues
+		if query.VariableLimit > 0 && query.VariableLimit < limit {
+			limit = query.VariableLimit
+		}
+		response.Frames = data.Frames{variableFrame(response.Frames[0], limit)}
+		resp.Responses[refID] = response
+	}
+}
+
+type variableOption struct {
+	text  string
+	value string
+}
+
+// variableFrame reads __text and __value fields or, by convention, the value from the first field
+// and the text from the second one. Options are deduplicated by value, sorted by text and capped by limit.
+func variableFrame(frame *data.Frame, limit int) *data.Frame {
+	textField, valueField := variableFields(frame)
+
+	var options []variableOption
+	seen := map[string]bool{}
+	if valueField != nil {
+		for i := 0; i < valueField.Len(); i++ {
+			option := variableOption{value: fieldString(valueField, i)}
+			option.text = option.value
+			// a NULL or empty text shows the value
+			if textField != nil {
+				if text := fieldString(textField, i); text != "" {
+					option.text = text
+				}
+			}
+			if seen[option.value] {
+				continue
+			}
+			seen[option.value] = true
+			options = append(options, option)
+		}
+	}
+	sortVariableOptions(options)
+
+	result := data.NewFrame(frame.Name,
+		data.NewField(variableTextField, nil, []string{}),
+		data.NewField(variableValueField, nil, []string{}),
+	)
+	if len(options) > limit {
+		result.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Variable values are limited to %d of %d", limit, len(options)),
+		})
+		options = options[:limit]
+	}
+	for _, option := range options {
+		result.AppendRow(option.text, option.value)
+	}
+	return result
+}
+
+func variableFields(frame *data.Frame) (textField *data.Field, valueField *data.Field) {
+	for _, field := range frame.Fields {
+		switch field.Name {
+		case variableTextField:
+			textField = field
+		case variableValueField:
+			valueField = field
+		}
+	}
+	switch {
+	case textField != nil || valueField != nil:
+		if valueField == nil {
+			valueField = textField
+		}
+	case len(frame.Fields) == 1:
+		valueField = frame.Fields[0]
+	case len(frame.Fields) > 1:
+		valueField, textField = frame.Fields[0], frame.Fields[1]
+	}
+	return textField, valueField
+}
+
+// sortVariableOptions sorts options numerically when all texts are numbers, otherwise alphabetically
+func sortVariableOptions(options []variableOption) {
+	numbers := make([]float64, len(options))
+	numeric := true
+	for i, option := range options {
+		n, err := strconv.ParseFloat(option.text, 64)
+		if err != nil {
+			numeric = false
+			break
+		}
+		numbers[i] = n
+	}
+	if numeric {
+		sort.Sort(byNumber{options, numbers})
+		return
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		return options[i].text < options[j].text
+	})
+}
+
+type byNumber struct {
+	options []variableOption
+	numbers []float64
+}
+
+func (s byNumber) Len() int           { return len(s.options) }
+func (s byNumber) Less(i, j int) bool { return s.numbers[i] < s.numbers[j] }
+func (s byNumber) Swap(i, j int) {
+	s.options[i], s.options[j] = s.options[j], s.options[i]
+	s.numbers[i], s.numbers[j] = s.numbers[j], s.numbers[i]
+}
+
+// fieldString formats the value of the field, null values are empty strings
+func fieldString(field *data.Field, i int) string {
+	value, ok := field.ConcreteAt(i)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func variableOptions(t *testing.T, frame *data.Frame) (options [][2]string) {
+	require.Len(t, frame.Fields, 2)
+	for i := 0; i < frame.Rows(); i++ {
+		options = append(options, [2]string{frame.Fields[0].At(i).(string), frame.Fields[1].At(i).(string)})
+	}
+	return options
+}
+
+func TestVariableFrame(t *testing.T) {
+	host := "db-2"
+	frame := variableFrame(data.NewFrame("",
+		data.NewField("id", nil, []int64{2, 1, 2, 3}),
+		data.NewField("host", nil, []*string{&host, nil, &host, nil}),
+	), 10)
+	assert.Equal(t, [][2]string{{"1", "1"}, {"3", "3"}, {"db-2", "2"}}, variableOptions(t, frame))
+
+	frame = variableFrame(data.NewFrame("",
+		data.NewField("__value", nil, []string{"a", "b", "c"}),
+		data.NewField("other", nil, []string{"x", "y", "z"}),
+		data.NewField("__text", nil, []string{"10", "9", "100"}),
+	), 2)
+	assert.Equal(t, [][2]string{{"9", "b"}, {"10", "a"}}, variableOptions(t, frame))
+	require.Len(t, frame.Meta.Notices, 1)
+}
+
+func TestVariableResponses(t *testing.T) {
+	req := &backend.QueryDataRequest{Queries: []backend.DataQuery{
+		{RefID: "A", JSON: []byte(`{"rawSql":"SELECT 1","variableQuery":true}`)},
+		{RefID: "B", JSON: []byte(`{"rawSql":"SELECT 1"}`)},
+	}}
+	frame := data.NewFrame("", data.NewField("name", nil, []string{"b", "a", "b"}))
+	resp := &backend.QueryDataResponse{Responses: backend.Responses{
+		"A": {Frames: data.Frames{frame}},
+		"B": {Frames: data.Frames{frame}},
+	}}
+	_, _, models := validQueries(req)
+	variableResponses(models, resp)
+	assert.Equal(t, [][2]string{{"a", "a"}, {"b", "b"}}, variableOptions(t, resp.Responses["A"].Frames[0]))
+	assert.Same(t, frame, resp.Responses["B"].Frames[0])
+}
+
+func TestInterpolateVariables(t *testing.T) {
+	var variables map[string]VariableValue
+	require.NoError(t, json.Unmarshal([]byte(`{"cluster":"eu","hosts":["a","b\""],"empty":[]}`), &variables))
+	assert.Equal(t,
+		`SELECT host FROM hosts WHERE cluster = "eu" AND host IN ("a","b\"") AND dc IN ("") AND $clusterName = $__timeFilter`,
+		interpolateVariables(`SELECT host FROM hosts WHERE cluster = ${cluster} AND host IN ([[hosts]]) AND dc IN ($empty) AND $clusterName = $__timeFilter`, variables),
+	)
+
+	// YQL named expressions and parameters of the query are not variables of the dashboard,
+	// values are quoted as YQL string literals
+	assert.Equal(t,
+		"DECLARE $hosts AS List<Utf8>;\n$cluster = "+`"eu\n\"west\""`+";\nSELECT * FROM t WHERE host IN $hosts AND cluster = $cluster AND dc = "+`"é\\\t"`,
+		interpolateVariables("DECLARE $hosts AS List<Utf8>;\n$cluster = $name;\nSELECT * FROM t WHERE host IN $hosts AND cluster = $cluster AND dc = $dc", map[string]VariableValue{
+			"hosts":   {"a"},
+			"cluster": {"us"},
+			"name":    {"eu\n\"west\""},
+			"dc":      {"é\\\t"},
+		}),
+	)
+}
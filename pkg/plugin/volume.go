@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/ydb/grafana-ydb-datasource/pkg/builder"
+)
+
+// SupplementaryQueryLogsVolume is the supplementary query of Explore counting logs per time bucket and level
+const SupplementaryQueryLogsVolume = "LogsVolume"
+
+// maxLogsVolumeBuckets caps buckets of the log volume when the query doesn't set max data points
+const maxLogsVolumeBuckets = 1000
+
+// logLevelColors are colors of levels used by Grafana in log volume histograms
+var logLevelColors = map[string]string{
+	logLevelCritical: "#705da0",
+	logLevelError:    "#e02f44",
+	logLevelWarning:  "#ff9900",
+	logLevelInfo:     "#7eb26d",
+	logLevelDebug:    "#1f78c1",
+	logLevelTrace:    "#6ed0e0",
+	logLevelUnknown:  "#8e8e8e",
+}
+
+// logsVolumeBucket returns the bucket of the log volume, not shorter than the query interval and a second
+func logsVolumeBucket(q backend.DataQuery) time.Duration {
+	points := q.MaxDataPoints
+	if points <= 0 || points > maxLogsVolumeBuckets {
+		points = maxLogsVolumeBuckets
+	}
+	bucket := q.TimeRange.Duration() / time.Duration(points)
+	bucket = max(bucket, q.Interval, time.Second)
+	return bucket.Truncate(time.Second)
+}
+
+// logsVolumeFrames turns counts of log volume queries into a frame per level in the log volume format of Grafana,
+// levels are normalized, so counts of levels with different names are summed up
+func logsVolumeFrames(frames data.Frames, timeRange backend.TimeRange) data.Frames {
+	counts := map[string]map[time.Time]float64{}
+	for _, frame := range frames {
+		timeIndex := logFieldIndex(frame, []string{builder.VolumeTimeAlias}, data.FieldTypeTime, data.FieldTypeNullableTime)
+		countIndex := logFieldIndex(frame, []string{builder.VolumeCountAlias})
+		levelIndex := logFieldIndex(frame, []string{builder.VolumeLevelAlias})
+		if timeIndex < 0 || countIndex < 0 {
+			continue
+		}
+		for row := 0; row < frame.Fields[timeIndex].Len(); row++ {
+			value, ok := frame.Fields[timeIndex].ConcreteAt(row)
+			if !ok {
+				continue
+			}
+			count, err := strconv.ParseFloat(fieldString(frame.Fields[countIndex], row), 64)
+			if err != nil {
+				continue
+			}
+			level := logLevelUnknown
+			if levelIndex >= 0 {
+				level = normalizeLogLevel(fieldString(frame.Fields[levelIndex], row))
+			}
+			if counts[level] == nil {
+				counts[level] = map[time.Time]float64{}
+			}
+			counts[level][value.(time.Time)] += count
+		}
+	}
+
+	levels := make([]string, 0, len(counts))
+	for level := range counts {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+	result := make(data.Frames, 0, len(levels))
+	for _, level := range levels {
+		times := make([]time.Time, 0, len(counts[level]))
+		for t := range counts[level] {
+			times = append(times, t)
+		}
+		slices.SortFunc(times, time.Time.Compare)
+		values := make([]float64, len(times))
+		for i, t := range times {
+			values[i] = counts[level][t]
+		}
+
+		valueField := data.NewField("Value", data.Labels{"level": level}, values)
+		valueField.Config = &data.FieldConfig{
+			DisplayNameFromDS: level,
+			Color:             map[string]interface{}{"mode": "fixed", "fixedColor": logLevelColors[level]},
+		}
+		frame := data.NewFrame("", data.NewField("Time", nil, times), valueField)
+		frame.Meta = &data.FrameMeta{
+			Type:                   data.FrameTypeTimeSeriesMulti,
+			PreferredVisualization: data.VisTypeGraph,
+			Custom: map[string]interface{}{
+				"logsVolumeType": "FullRange",
+				"absoluteRange": map[string]int64{
+					"from": timeRange.From.UnixMilli(),
+					"to":   timeRange.To.UnixMilli(),
+				},
+			},
+		}
+		result = append(result, frame)
+	}
+	return result
+}
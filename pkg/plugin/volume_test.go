@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogsVolumeBucket(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := backend.DataQuery{TimeRange: backend.TimeRange{From: t0, To: t0.Add(24 * time.Hour)}, MaxDataPoints: 100}
+	assert.Equal(t, 864*time.Second, logsVolumeBucket(q))
+
+	q.MaxDataPoints = 0
+	q.Interval = time.Hour
+	assert.Equal(t, time.Hour, logsVolumeBucket(q))
+
+	q.TimeRange.To = t0.Add(time.Minute)
+	q.Interval = 0
+	assert.Equal(t, time.Second, logsVolumeBucket(q))
+}
+
+func TestMutateQueryCompilesLogsVolume(t *testing.T) {
+	ydb := &Ydb{}
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, query := ydb.MutateQuery(context.Background(), backend.DataQuery{
+		TimeRange: backend.TimeRange{From: t0, To: t0.Add(time.Hour)},
+		Interval:  time.Minute,
+		JSON: []byte(`{"version":1,"queryType":"builder","queryFormat":"logs","supplementaryQueryType":"LogsVolume",
+			"rawSql":"SELECT * FROM logs LIMIT 100","builderOptions":{"table":"logs","logTimeField":{"name":"ts"}}}`),
+	})
+	var model queryModel
+	require.NoError(t, json.Unmarshal(query.JSON, &model))
+	assert.Contains(t, model.RawSql, "COUNT(*) AS `count`")
+	assert.NotContains(t, model.RawSql, "LIMIT")
+
+	_, err := parseQueryModel([]byte(`{"version":1,"queryType":"sql","supplementaryQueryType":"LogsVolume"}`))
+	assert.ErrorIs(t, err, ErrInvalidParameter)
+}
+
+func TestLogsVolumeFrames(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+	frame := data.NewFrame("",
+		data.NewField("time", nil, []*time.Time{&t1, &t0, &t0, &t1}),
+		data.NewField("level", nil, []*string{ptr("WARN"), ptr("info"), ptr("warning"), nil}),
+		data.NewField("count", nil, []uint64{1, 2, 3, 4}),
+	)
+
+	frames := logsVolumeFrames(data.Frames{frame}, backend.TimeRange{From: t0, To: t1})
+	require.Len(t, frames, 3)
+	assert.Equal(t, data.Labels{"level": "info"}, frames[0].Fields[1].Labels)
+	assert.Equal(t, data.Labels{"level": "unknown"}, frames[1].Fields[1].Labels)
+	warnings := frames[2]
+	assert.Equal(t, data.Labels{"level": "warning"}, warnings.Fields[1].Labels)
+	assert.Equal(t, []any{t0, t1}, concreteValues(warnings.Fields[0]))
+	assert.Equal(t, []any{3.0, 1.0}, concreteValues(warnings.Fields[1]))
+	assert.Equal(t, "FullRange", warnings.Meta.Custom.(map[string]interface{})["logsVolumeType"])
+}
@@ -0,0 +1,46 @@
+import { Select, InlineField } from '@grafana/ui';
+import { SelectableValue } from '@grafana/data';
+
+import { defaultInputWidth, defaultLabelWidth } from 'containers/QueryEditor/constants';
+
+import { selectors } from 'selectors';
+import { getSelectableValues } from 'containers/QueryEditor/helpers';
+
+export type LogContextFieldSelectProps = {
+  fields: string[];
+  logContextField?: string | null;
+  loading?: boolean;
+  error?: string;
+  onChange: (value: string | null) => void;
+};
+
+export function LogContextFieldSelect({
+  onChange,
+  logContextField,
+  fields,
+  loading,
+  error,
+}: LogContextFieldSelectProps) {
+  const selectableFields = getSelectableValues(fields);
+
+  const { label, tooltip } = selectors.components.QueryBuilder.LogContextField;
+
+  const handleChange = (e: SelectableValue<string>) => {
+    onChange(e?.value ?? null);
+  };
+
+  return (
+    <InlineField labelWidth={defaultLabelWidth} tooltip={tooltip} label={label} error={error} invalid={Boolean(error)}>
+      <Select
+        onChange={handleChange}
+        options={selectableFields}
+        value={logContextField}
+        menuPlacement={'bottom'}
+        isLoading={loading}
+        isClearable
+        isSearchable
+        width={defaultInputWidth}
+      />
+    </InlineField>
+  );
+}
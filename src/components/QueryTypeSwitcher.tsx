@@ -16,6 +16,10 @@ interface QueryTypeSwitcherProps {
 const options: Array<SelectableValue<QueryType>> = [
   { label: selectors.components.QueryEditor.Types.options.SQLEditor, value: 'sql' },
   { label: selectors.components.QueryEditor.Types.options.QueryBuilder, value: 'builder' },
+  { label: selectors.components.QueryEditor.Types.options.Traces, value: 'traces' },
+  { label: selectors.components.QueryEditor.Types.options.Topic, value: 'topic' },
+  { label: selectors.components.QueryEditor.Types.options.TopicLag, value: 'topicLag' },
+  { label: selectors.components.QueryEditor.Types.options.System, value: 'system' },
 ];
 
 export function QueryTypeSwitcher({ queryType, onChange, shouldConfirm = true }: QueryTypeSwitcherProps) {
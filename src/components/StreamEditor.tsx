@@ -0,0 +1,43 @@
+import * as React from 'react';
+import { InlineField, Input } from '@grafana/ui';
+
+import { defaultInputWidth, defaultLabelWidth } from 'containers/QueryEditor/constants';
+import { StreamOptions } from 'containers/QueryEditor/types';
+import { selectors } from 'selectors';
+
+interface StreamEditorProps {
+  stream?: StreamOptions;
+  onChange: (stream?: StreamOptions) => void;
+}
+
+export function StreamEditor({ stream, onChange }: StreamEditorProps) {
+  const { Column, Interval } = selectors.components.Stream;
+
+  const handleColumnChange = (e: React.FormEvent<HTMLInputElement>) => {
+    const column = e.currentTarget.value;
+    onChange(column ? { ...stream, column } : undefined);
+  };
+  const handleIntervalChange = (e: React.FormEvent<HTMLInputElement>) => {
+    if (stream) {
+      onChange({ ...stream, interval: e.currentTarget.value || undefined });
+    }
+  };
+
+  return (
+    <React.Fragment>
+      <InlineField labelWidth={defaultLabelWidth} label={Column.label} tooltip={Column.tooltip}>
+        <Input width={defaultInputWidth} value={stream?.column ?? ''} onChange={handleColumnChange} />
+      </InlineField>
+      {stream && (
+        <InlineField labelWidth={defaultLabelWidth} label={Interval.label} tooltip={Interval.tooltip}>
+          <Input
+            width={defaultInputWidth}
+            value={stream.interval ?? ''}
+            placeholder="5s"
+            onChange={handleIntervalChange}
+          />
+        </InlineField>
+      )}
+    </React.Fragment>
+  );
+}
@@ -1,8 +1,7 @@
 import * as React from 'react';
-import { RadioButtonGroup, FieldSet, InlineField, Input, SecretTextArea, SecretInput } from '@grafana/ui';
+import { RadioButtonGroup, FieldSet, InlineField, Input, SecretTextArea, SecretInput, Select } from '@grafana/ui';
 import {
   onUpdateDatasourceJsonDataOption,
-  onUpdateDatasourceJsonDataOptionSelect,
   onUpdateDatasourceSecureJsonDataOption,
   updateDatasourcePluginResetOption,
 } from '@grafana/data';
@@ -15,6 +14,8 @@ import {
   AuthenticationType,
   YdbSecureOptions,
   EditorProps,
+  SigningMethods,
+  YdbDataSourceOptions,
 } from './types';
 
 import { Components } from 'selectors';
@@ -28,6 +29,19 @@ const ConnectionTypesValues = Object.entries(AuthenticationOptions).map(([key, v
   value: key as AuthenticationType,
 }));
 
+const SigningMethodValues = SigningMethods.map((method) => ({ label: method, value: method }));
+
+type TextOption = {
+  [K in keyof YdbDataSourceOptions]-?: YdbDataSourceOptions[K] extends string | undefined ? K : never;
+}[keyof YdbDataSourceOptions];
+type NumberOption = 'maxOpenConns' | 'maxIdleConns' | 'sessionPoolSizeLimit';
+
+interface OptionSelector {
+  label: string;
+  tooltip?: string;
+  placeholder?: string;
+}
+
 export const ConfigEditor = (props: EditorProps) => {
   const { options } = props;
   const { jsonData, secureJsonFields = {} } = options;
@@ -35,6 +49,55 @@ export const ConfigEditor = (props: EditorProps) => {
   const kind = isValidConnectionType(jsonData.authKind) ? jsonData.authKind : 'ServiceAccountKey';
 
   const showCert = jsonData.endpoint?.startsWith('grpcs://');
+  const { ConfigEditor: Selectors } = Components;
+
+  const onUpdateJsonData = (value: Partial<YdbDataSourceOptions>) => {
+    props.onOptionsChange({ ...options, jsonData: { ...jsonData, ...value } });
+  };
+  // forwarding the Grafana user's identity needs OAuth pass-through of Grafana
+  const onAuthKindChange = (authKind: AuthenticationType) => {
+    onUpdateJsonData({ authKind, oauthPassThru: authKind === 'ForwardOAuthIdentity' });
+  };
+  const textField = (option: TextOption, selector: OptionSelector) => (
+    <InlineField label={selector.label} tooltip={selector.tooltip} labelWidth={defaultLabelWidth}>
+      <Input
+        name={YdbDataSourceOptionValues[option]}
+        value={jsonData[option] ?? ''}
+        placeholder={selector.placeholder}
+        width={defaultInputWidth}
+        onChange={onUpdateDatasourceJsonDataOption(props, option)}
+      />
+    </InlineField>
+  );
+  const numberField = (option: NumberOption, selector: OptionSelector) => (
+    <InlineField label={selector.label} tooltip={selector.tooltip} labelWidth={defaultLabelWidth}>
+      <Input
+        name={YdbDataSourceOptionValues[option]}
+        type="number"
+        min={0}
+        value={jsonData[option] ?? ''}
+        placeholder={selector.placeholder}
+        width={defaultInputWidth}
+        onChange={(e) => {
+          const value = e.currentTarget.value;
+          onUpdateJsonData({ [option]: value !== '' && !isNaN(Number(value)) ? Number(value) : undefined });
+        }}
+      />
+    </InlineField>
+  );
+  const secretTextArea = (option: 'subjectToken' | 'privateKey', selector: OptionSelector, rows: number) => (
+    <InlineField tooltip={selector.tooltip} label={selector.label} labelWidth={defaultLabelWidth}>
+      <SecretTextArea
+        rows={rows}
+        cols={defaultTextAreaWidth}
+        onReset={() => updateDatasourcePluginResetOption(props, YdbSecureOptions[option])}
+        isConfigured={Boolean(secureJsonFields[option])}
+        value={secureJsonData[option]}
+        onChange={onUpdateDatasourceSecureJsonDataOption(props, YdbSecureOptions[option])}
+        placeholder={selector.placeholder}
+      />
+    </InlineField>
+  );
 
   return (
     <React.Fragment>
@@ -90,9 +153,7 @@ export const ConfigEditor = (props: EditorProps) => {
           <RadioButtonGroup
             options={ConnectionTypesValues}
             value={kind}
-            onChange={(v) =>
-              onUpdateDatasourceJsonDataOptionSelect(props, YdbDataSourceOptionValues.authKind)({ value: v })
-            }
+            onChange={onAuthKindChange}
             size={'md'}
           />
         </InlineField>
@@ -157,7 +218,54 @@ export const ConfigEditor = (props: EditorProps) => {
             </InlineField>
           </React.Fragment>
         )}
+        {(kind === 'OAuth2TokenExchange' || kind === 'JWT') && (
+          <React.Fragment>
+            {textField('tokenEndpoint', Selectors.TokenEndpoint)}
+            {textField('audience', Selectors.Audience)}
+            {textField('scope', Selectors.Scope)}
+          </React.Fragment>
+        )}
+        {kind === 'OAuth2TokenExchange' && (
+          <React.Fragment>
+            {secretTextArea('subjectToken', Selectors.SubjectToken, 3)}
+            {textField('subjectTokenType', Selectors.SubjectTokenType)}
+          </React.Fragment>
+        )}
+        {kind === 'JWT' && (
+          <React.Fragment>
+            <InlineField
+              label={Selectors.SigningMethod.label}
+              tooltip={Selectors.SigningMethod.tooltip}
+              labelWidth={defaultLabelWidth}
+            >
+              <Select
+                width={defaultInputWidth}
+                options={SigningMethodValues}
+                value={jsonData.signingMethod ?? 'RS256'}
+                onChange={(e) => onUpdateJsonData({ signingMethod: e.value })}
+              />
+            </InlineField>
+            {secretTextArea('privateKey', Selectors.PrivateKey, 10)}
+            {textField('keyId', Selectors.KeyID)}
+            {textField('issuer', Selectors.Issuer)}
+            {textField('subject', Selectors.Subject)}
+          </React.Fragment>
+        )}
+        {kind === 'ForwardOAuthIdentity' && <p>{Selectors.ForwardOAuthIdentity.description}</p>}
       </div>
+      <FieldSet label="Timeouts">
+        {textField('connectTimeout', Selectors.ConnectTimeout)}
+        {textField('queryTimeout', Selectors.QueryTimeout)}
+        {textField('metadataTimeout', Selectors.MetadataTimeout)}
+      </FieldSet>
+      <FieldSet label="Connection pool">
+        {numberField('maxOpenConns', Selectors.MaxOpenConns)}
+        {numberField('maxIdleConns', Selectors.MaxIdleConns)}
+        {textField('connMaxLifetime', Selectors.ConnMaxLifetime)}
+        {numberField('sessionPoolSizeLimit', Selectors.SessionPoolSizeLimit)}
+        {textField('sessionIdleThreshold', Selectors.SessionIdleThreshold)}
+      </FieldSet>
+      <FieldSet label="Schema">{textField('schemaCacheTTL', Selectors.SchemaCacheTTL)}</FieldSet>
     </React.Fragment>
   );
 };
@@ -5,6 +5,24 @@ export interface YdbDataSourceOptions extends DataSourceJsonData {
   endpoint?: string;
   dbLocation?: string;
   user?: string;
+  tokenEndpoint?: string;
+  audience?: string;
+  scope?: string;
+  subjectTokenType?: string;
+  keyId?: string;
+  issuer?: string;
+  subject?: string;
+  signingMethod?: SigningMethod;
+  oauthPassThru?: boolean;
+  connectTimeout?: string;
+  queryTimeout?: string;
+  metadataTimeout?: string;
+  maxOpenConns?: number;
+  maxIdleConns?: number;
+  connMaxLifetime?: string;
+  sessionPoolSizeLimit?: number;
+  sessionIdleThreshold?: string;
+  schemaCacheTTL?: string;
 }
 
 export const AuthenticationOptions = {
@@ -13,8 +31,29 @@ export const AuthenticationOptions = {
   UserPassword: 'User/Password',
   MetaData: 'Metadata',
   Anonymous: 'Anonymous',
+  OAuth2TokenExchange: 'OAuth 2.0 token exchange',
+  JWT: 'JWT',
+  ForwardOAuthIdentity: 'Forward OAuth identity',
 } as const;
 
+// signing methods of JWT subject tokens supported by the backend
+export const SigningMethods = [
+  'RS256',
+  'RS384',
+  'RS512',
+  'PS256',
+  'PS384',
+  'PS512',
+  'ES256',
+  'ES384',
+  'ES512',
+  'HS256',
+  'HS384',
+  'HS512',
+] as const;
+
+export type SigningMethod = (typeof SigningMethods)[number];
+
 export type AuthenticationType = keyof typeof AuthenticationOptions;
 
 /**
@@ -26,6 +65,23 @@ export const YdbDataSourceOptionValues: Record<string, keyof YdbDataSourceOption
   endpoint: 'endpoint',
   dbLocation: 'dbLocation',
   user: 'user',
+  tokenEndpoint: 'tokenEndpoint',
+  audience: 'audience',
+  scope: 'scope',
+  subjectTokenType: 'subjectTokenType',
+  keyId: 'keyId',
+  issuer: 'issuer',
+  subject: 'subject',
+  signingMethod: 'signingMethod',
+  connectTimeout: 'connectTimeout',
+  queryTimeout: 'queryTimeout',
+  metadataTimeout: 'metadataTimeout',
+  maxOpenConns: 'maxOpenConns',
+  maxIdleConns: 'maxIdleConns',
+  connMaxLifetime: 'connMaxLifetime',
+  sessionPoolSizeLimit: 'sessionPoolSizeLimit',
+  sessionIdleThreshold: 'sessionIdleThreshold',
+  schemaCacheTTL: 'schemaCacheTTL',
 };
 
 /**
@@ -36,6 +92,8 @@ export interface YdbSecureJsonData {
   accessToken?: string;
   password?: string;
   certificate?: string;
+  subjectToken?: string;
+  privateKey?: string;
 }
 
 export const YdbSecureOptions: Record<string, keyof YdbSecureJsonData> = {
@@ -43,6 +101,8 @@ export const YdbSecureOptions: Record<string, keyof YdbSecureJsonData> = {
   accessToken: 'accessToken',
   password: 'password',
   certificate: 'certificate',
+  subjectToken: 'subjectToken',
+  privateKey: 'privateKey',
 };
 
 export function isValidConnectionType(type: unknown): type is AuthenticationType {
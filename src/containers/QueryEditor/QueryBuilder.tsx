@@ -7,6 +7,7 @@ import { FieldsSelect } from 'components/FieldsSelect';
 import { Limit } from 'components/Limit';
 import { SqlPreview } from 'components/SqlPreview';
 import { LogLevelFieldSelect } from 'components/LogLevelFieldSelect';
+import { LogContextFieldSelect } from 'components/LogContextFieldSelect';
 import { Filters } from 'components/Filters/Filters';
 import { Aggregations } from 'components/Aggregations/Aggregations';
 import { LogTimeFieldSelect } from 'components/LogTimeFieldSelect';
@@ -69,6 +70,8 @@ const TableDependableFields: Record<keyof Omit<SqlBuilderOptions, 'limit' | 'raw
   aggregations: undefined,
   logTimeField: undefined,
   orderBy: undefined,
+  logContextColumns: undefined,
+  logContextField: null,
 };
 
 export function QueryBuilder({ query, onChange }: QueryBuilderProps) {
@@ -89,6 +92,8 @@ export function QueryBuilder({ query, onChange }: QueryBuilderProps) {
       aggregations,
       logTimeField,
       orderBy = [],
+      logContextColumns,
+      logContextField,
     },
   } = query;
 
@@ -127,6 +132,12 @@ export function QueryBuilder({ query, onChange }: QueryBuilderProps) {
   const handleLogLevelFieldChange = (value: string | null) => {
     handleChangeBuilderOption({ logLevelField: value });
   };
+  const handleLogContextColumnsChange = (value: string[]) => {
+    handleChangeBuilderOption({ logContextColumns: value });
+  };
+  const handleLogContextFieldChange = (value: string | null) => {
+    handleChangeBuilderOption({ logContextField: value });
+  };
   const handleLogTimeFieldChange = (value: LogTimeField) => {
     //remove previous logTimeField name from orderBy
     const newOrderBy = orderBy.filter((el) => el.column !== logTimeField?.name);
@@ -198,6 +209,17 @@ export function QueryBuilder({ query, onChange }: QueryBuilderProps) {
                 onFieldsChange={handleLoglineFieldsChange}
                 selectors={selectors.components.QueryBuilder.LogLineFields}
               />
+              <FieldsSelect
+                {...commonFieldsProps}
+                selectedFields={logContextColumns}
+                onFieldsChange={handleLogContextColumnsChange}
+                selectors={selectors.components.QueryBuilder.LogContextColumns}
+              />
+              <LogContextFieldSelect
+                {...commonFieldsProps}
+                onChange={handleLogContextFieldChange}
+                logContextField={logContextField}
+              />
             </React.Fragment>
           )}
           {filtersActive && (
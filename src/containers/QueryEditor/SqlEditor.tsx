@@ -5,9 +5,8 @@ import { CodeEditor, InlineField, monacoTypes } from '@grafana/ui';
 import { OnChangeQueryAttribute, YDBSQLQuery } from './types';
 import { MONACO_LANGUAGE_SQL } from './constants';
 import { useEditorHeight } from './EditorSettingsContext';
-import { useTables } from './TablesContext';
-import { removeDatabaseFromTableName, useVariables } from './helpers';
-import { useDatabase, useDatasource } from './DatasourceContext';
+import { useVariables } from './helpers';
+import { useDatasource } from './DatasourceContext';
 
 import { createProvideSuggestionsFunction } from 'lib/sqlProvider';
 import { highlightErrors, unHighlightErrors } from 'lib/highlightErrors';
@@ -22,18 +21,11 @@ interface SqlEditorProps {
 export function SqlEditor({ onChange, query }: SqlEditorProps) {
   const datasource = useDatasource();
   const variables = useVariables();
-  const { tables } = useTables();
-  const database = useDatabase();
   const editorHeight = useEditorHeight();
   const monacoRef = React.useRef<typeof monacoTypes | null>(null);
   const editorRef = React.useRef<monacoEditor.IStandaloneCodeEditor | null>(null);
   const errorsHighlightingTimeoutIdRef = React.useRef<ReturnType<typeof setTimeout> | null>(null);
 
-  const tablesForSuggest = React.useMemo(
-    () => tables.map((t) => ({ label: removeDatabaseFromTableName(t, database), value: t })),
-    [tables, database]
-  );
-
   const registerCompletionProvider = React.useCallback(
     (monaco: typeof monacoTypes) => {
       if (completionProvider) {
@@ -41,10 +33,10 @@ export function SqlEditor({ onChange, query }: SqlEditorProps) {
       }
       completionProvider = monaco.languages.registerCompletionItemProvider(MONACO_LANGUAGE_SQL, {
         triggerCharacters: [' ', '\n', '', '$'],
-        provideCompletionItems: createProvideSuggestionsFunction(datasource, tablesForSuggest, variables),
+        provideCompletionItems: createProvideSuggestionsFunction(datasource, variables),
       });
     },
-    [datasource, tablesForSuggest, variables]
+    [datasource, variables]
   );
 
   React.useEffect(() => {
@@ -0,0 +1,106 @@
+import * as React from 'react';
+import { SelectableValue } from '@grafana/data';
+import { InlineField, Input, RadioButtonGroup, Select } from '@grafana/ui';
+
+import { selectors } from 'selectors';
+
+import { QueryFormatNames, defaultInputWidth, defaultLabelWidth, defaultNumberInputWidth } from './constants';
+import { useDatasource } from './DatasourceContext';
+import { OnChangeQueryAttribute, QueryFormat, SystemOptions, SystemView, SystemWindow, YDBSystemQuery } from './types';
+
+interface SystemEditorProps {
+  query: YDBSystemQuery;
+  onChange: OnChangeQueryAttribute<YDBSystemQuery>;
+}
+
+const formats: Array<SelectableValue<QueryFormat>> = (['table', 'timeseries'] as QueryFormat[]).map((format) => ({
+  label: QueryFormatNames[format],
+  value: format,
+}));
+
+const windowNames: Record<SystemWindow, string> = {
+  minute: 'Minute',
+  hour: 'Hour',
+};
+
+function useSystemViews() {
+  const datasource = useDatasource();
+  const [views, setViews] = React.useState<SystemView[]>([]);
+  const [loading, setLoading] = React.useState(true);
+  React.useEffect(() => {
+    datasource
+      .fetchSystemViews()
+      .then(setViews)
+      .catch(() => setViews([]))
+      .finally(() => setLoading(false));
+  }, [datasource]);
+
+  return [views, loading] as const;
+}
+
+export function SystemEditor({ query, onChange }: SystemEditorProps) {
+  const system: SystemOptions = query.system ?? { view: '' };
+  const { System, QueryBuilder } = selectors.components;
+  const [views, loading] = useSystemViews();
+  const view = views.find((v) => v.name === system.view);
+  const viewOptions: Array<SelectableValue<string>> = views.map((v) => ({
+    label: v.title,
+    value: v.name,
+    description: v.description,
+  }));
+  const windowOptions: Array<SelectableValue<SystemWindow>> = (view?.windows ?? []).map((window) => ({
+    label: windowNames[window],
+    value: window,
+  }));
+
+  const handleChange = (value: Partial<SystemOptions>) => {
+    onChange({ system: { ...system, ...value } });
+  };
+
+  return (
+    <React.Fragment>
+      <InlineField
+        labelWidth={defaultLabelWidth}
+        label={QueryBuilder.Format.label}
+        tooltip={QueryBuilder.Format.tooltip}
+      >
+        <RadioButtonGroup
+          options={formats}
+          value={query.queryFormat}
+          onChange={(queryFormat) => onChange({ queryFormat })}
+        />
+      </InlineField>
+      <InlineField labelWidth={defaultLabelWidth} label={System.View.label} tooltip={System.View.tooltip}>
+        <Select
+          width={defaultInputWidth}
+          options={viewOptions}
+          value={system.view || null}
+          isLoading={loading}
+          onChange={(e) => handleChange({ view: e?.value ?? '', window: undefined })}
+        />
+      </InlineField>
+      {windowOptions.length > 1 && (
+        <InlineField labelWidth={defaultLabelWidth} label={System.Window.label} tooltip={System.Window.tooltip}>
+          <RadioButtonGroup
+            options={windowOptions}
+            value={system.window ?? windowOptions[0].value}
+            onChange={(window) => handleChange({ window })}
+          />
+        </InlineField>
+      )}
+      <InlineField labelWidth={defaultLabelWidth} label={System.Limit.label} tooltip={System.Limit.tooltip}>
+        <Input
+          width={defaultNumberInputWidth}
+          type="number"
+          min={0}
+          value={system.limit ?? ''}
+          placeholder="100"
+          onChange={(e) => {
+            const limit = Number(e.currentTarget.value);
+            handleChange({ limit: e.currentTarget.value !== '' && !isNaN(limit) ? limit : undefined });
+          }}
+        />
+      </InlineField>
+    </React.Fragment>
+  );
+}
@@ -0,0 +1,187 @@
+import * as React from 'react';
+import { SelectableValue } from '@grafana/data';
+import { InlineField, Input, RadioButtonGroup, Select, TagsInput } from '@grafana/ui';
+
+import { TableSelect } from 'components/TableSelect';
+import { DataSource } from 'datasource';
+import { selectors } from 'selectors';
+
+import { QueryFormatNames, defaultInputWidth, defaultLabelWidth, defaultNumberInputWidth } from './constants';
+import { useDatasource } from './DatasourceContext';
+import {
+  ChangefeedDescription,
+  OnChangeQueryAttribute,
+  QueryFormat,
+  TopicOptions,
+  TopicParser,
+  YDBTopicQuery,
+} from './types';
+
+interface TopicEditorProps {
+  query: YDBTopicQuery;
+  onChange: OnChangeQueryAttribute<YDBTopicQuery>;
+}
+
+const parsers: Array<SelectableValue<TopicParser>> = [
+  { label: 'Text', value: 'text' },
+  { label: 'JSON', value: 'json' },
+];
+
+type TopicSource = 'topic' | 'changefeed';
+
+const sources: Array<SelectableValue<TopicSource>> = [
+  { label: 'Topic', value: 'topic' },
+  { label: 'Changefeed', value: 'changefeed' },
+];
+
+const formatOptions = (formats: QueryFormat[]): Array<SelectableValue<QueryFormat>> =>
+  formats.map((format) => ({ label: QueryFormatNames[format], value: format }));
+
+// messages are streamed as a table or as logs, lag metrics are returned as time series or as a table
+const formats = formatOptions(['logs', 'table']);
+const lagFormats = formatOptions(['timeseries', 'table']);
+
+function parseNumber(value: string): number | undefined {
+  const number = Number(value);
+  return value !== '' && !isNaN(number) ? number : undefined;
+}
+
+function useChangefeeds(datasource: DataSource, table?: string) {
+  const [changefeeds, setChangefeeds] = React.useState<ChangefeedDescription[]>([]);
+  const [loading, setLoading] = React.useState(false);
+  React.useEffect(() => {
+    if (table) {
+      setLoading(true);
+      datasource
+        .fetchChangefeeds(table)
+        .then(setChangefeeds)
+        .catch(() => setChangefeeds([]))
+        .finally(() => setLoading(false));
+    } else {
+      setChangefeeds([]);
+    }
+  }, [datasource, table]);
+
+  return [changefeeds, loading] as const;
+}
+
+export function TopicEditor({ query, onChange }: TopicEditorProps) {
+  const topic: TopicOptions = query.topic ?? { path: '' };
+  const { parser = 'text' } = topic;
+  const lag = query.queryType === 'topicLag';
+  const source: TopicSource = !lag && parser === 'cdc' ? 'changefeed' : 'topic';
+  const { Topic, QueryBuilder } = selectors.components;
+  const datasource = useDatasource();
+  const [changefeeds, loadingChangefeeds] = useChangefeeds(
+    datasource,
+    source === 'changefeed' ? topic.table : undefined
+  );
+  const changefeedOptions: Array<SelectableValue<string>> = changefeeds.map((c) => ({
+    label: c.name,
+    value: c.name,
+    description: `${c.mode}, ${c.format}`,
+  }));
+
+  const handleChange = (value: Partial<TopicOptions>) => {
+    onChange({ topic: { ...topic, ...value } });
+  };
+  const handleSourceChange = (value: TopicSource) => {
+    if (value === 'changefeed') {
+      handleChange({ path: '', parser: 'cdc', fields: undefined });
+    } else {
+      handleChange({ parser: 'text', table: undefined, changefeed: undefined });
+    }
+  };
+  const textField = (option: 'path' | 'consumer' | 'startFrom') => {
+    const labels = { path: Topic.Path, consumer: lag ? Topic.LagConsumer : Topic.Consumer, startFrom: Topic.StartFrom };
+    return (
+      <InlineField labelWidth={defaultLabelWidth} label={labels[option].label} tooltip={labels[option].tooltip}>
+        <Input
+          width={defaultInputWidth}
+          value={topic[option] ?? ''}
+          onChange={(e) => handleChange({ [option]: e.currentTarget.value || undefined })}
+        />
+      </InlineField>
+    );
+  };
+
+  return (
+    <React.Fragment>
+      <InlineField
+        labelWidth={defaultLabelWidth}
+        label={QueryBuilder.Format.label}
+        tooltip={QueryBuilder.Format.tooltip}
+      >
+        <RadioButtonGroup
+          options={lag ? lagFormats : formats}
+          value={query.queryFormat}
+          onChange={(queryFormat) => onChange({ queryFormat })}
+        />
+      </InlineField>
+      {!lag && (
+        <InlineField labelWidth={defaultLabelWidth} label={Topic.Source.label} tooltip={Topic.Source.tooltip}>
+          <RadioButtonGroup options={sources} value={source} onChange={handleSourceChange} />
+        </InlineField>
+      )}
+      {source === 'topic' ? (
+        textField('path')
+      ) : (
+        <React.Fragment>
+          <TableSelect table={topic.table} onTableChange={(table) => handleChange({ table, changefeed: undefined })} />
+          <InlineField labelWidth={defaultLabelWidth} label={Topic.Changefeed.label} tooltip={Topic.Changefeed.tooltip}>
+            <Select
+              width={defaultInputWidth}
+              options={changefeedOptions}
+              value={topic.changefeed ?? null}
+              isLoading={loadingChangefeeds}
+              onChange={(e) => handleChange({ changefeed: e?.value })}
+            />
+          </InlineField>
+        </React.Fragment>
+      )}
+      {textField('consumer')}
+      {!lag && (
+        <React.Fragment>
+          {textField('startFrom')}
+          {source === 'topic' && (
+            <InlineField labelWidth={defaultLabelWidth} label={Topic.Parser.label} tooltip={Topic.Parser.tooltip}>
+              <RadioButtonGroup
+                options={parsers}
+                value={parser}
+                onChange={(value) => handleChange({ parser: value })}
+              />
+            </InlineField>
+          )}
+          {parser === 'json' && (
+            <InlineField labelWidth={defaultLabelWidth} label={Topic.Fields.label} tooltip={Topic.Fields.tooltip}>
+              <TagsInput
+                width={defaultInputWidth}
+                tags={topic.fields ?? []}
+                onChange={(fields) => handleChange({ fields: fields.length > 0 ? fields : undefined })}
+              />
+            </InlineField>
+          )}
+          <InlineField labelWidth={defaultLabelWidth} label={Topic.RateLimit.label} tooltip={Topic.RateLimit.tooltip}>
+            <Input
+              width={defaultNumberInputWidth}
+              type="number"
+              min={0}
+              value={topic.rateLimit ?? ''}
+              onChange={(e) => handleChange({ rateLimit: parseNumber(e.currentTarget.value) })}
+            />
+          </InlineField>
+          <InlineField labelWidth={defaultLabelWidth} label={Topic.Limit.label} tooltip={Topic.Limit.tooltip}>
+            <Input
+              width={defaultNumberInputWidth}
+              type="number"
+              min={0}
+              value={topic.limit ?? ''}
+              placeholder="10000"
+              onChange={(e) => handleChange({ limit: parseNumber(e.currentTarget.value) })}
+            />
+          </InlineField>
+        </React.Fragment>
+      )}
+    </React.Fragment>
+  );
+}
@@ -0,0 +1,108 @@
+import * as React from 'react';
+import { SelectableValue } from '@grafana/data';
+import { InlineField, Input, RadioButtonGroup, Select } from '@grafana/ui';
+
+import { TableSelect } from 'components/TableSelect';
+import { selectors } from 'selectors';
+
+import { defaultInputWidth, defaultLabelWidth } from './constants';
+import { OnChangeQueryAttribute, TraceColumns, TraceMode, TraceOptions, YDBTracesQuery } from './types';
+
+interface TracesEditorProps {
+  query: YDBTracesQuery;
+  onChange: OnChangeQueryAttribute<YDBTracesQuery>;
+}
+
+const modes: Array<SelectableValue<TraceMode>> = [
+  { label: 'Trace', value: 'trace' },
+  { label: 'Search', value: 'search' },
+];
+
+const durationUnits: Array<SelectableValue<TraceOptions['durationUnit']>> = ['ns', 'us', 'ms', 's'].map((unit) => ({
+  label: unit,
+  value: unit as TraceOptions['durationUnit'],
+}));
+
+const columnLabels: Record<keyof TraceColumns, string> = {
+  traceId: 'Trace ID column',
+  spanId: 'Span ID column',
+  parentSpanId: 'Parent ID column',
+  serviceName: 'Service column',
+  operationName: 'Operation column',
+  startTime: 'Start time column',
+  duration: 'Duration column',
+  tags: 'Tags column (Json)',
+};
+
+export function TracesEditor({ query, onChange }: TracesEditorProps) {
+  const traces: TraceOptions = query.traces ?? { columns: {} };
+  const { mode = 'trace', columns } = traces;
+  const { Traces } = selectors.components;
+
+  const handleChange = (value: Partial<TraceOptions>) => {
+    const next = { ...traces, ...value };
+    onChange({ traces: next, queryFormat: next.mode === 'search' ? 'table' : 'traces' });
+  };
+  const handleColumnChange = (column: keyof TraceColumns) => (e: React.FormEvent<HTMLInputElement>) => {
+    handleChange({ columns: { ...columns, [column]: e.currentTarget.value } });
+  };
+  const textField = (option: 'traceId' | 'serviceName' | 'operationName' | 'minDuration' | 'maxDuration') => {
+    const labels = {
+      traceId: Traces.TraceId,
+      serviceName: Traces.ServiceName,
+      operationName: Traces.OperationName,
+      minDuration: Traces.MinDuration,
+      maxDuration: Traces.MaxDuration,
+    };
+    return (
+      <InlineField
+        key={option}
+        labelWidth={defaultLabelWidth}
+        label={labels[option].label}
+        tooltip={labels[option].tooltip}
+      >
+        <Input
+          width={defaultInputWidth}
+          value={traces[option] ?? ''}
+          onChange={(e) => handleChange({ [option]: e.currentTarget.value })}
+        />
+      </InlineField>
+    );
+  };
+
+  return (
+    <React.Fragment>
+      <TableSelect table={traces.table} onTableChange={(table) => handleChange({ table })} />
+      <InlineField labelWidth={defaultLabelWidth} label={Traces.Mode.label} tooltip={Traces.Mode.tooltip}>
+        <RadioButtonGroup options={modes} value={mode} onChange={(value) => handleChange({ mode: value })} />
+      </InlineField>
+      {mode === 'trace'
+        ? textField('traceId')
+        : ['serviceName', 'operationName', 'minDuration', 'maxDuration'].map((option) =>
+            textField(option as 'serviceName' | 'operationName' | 'minDuration' | 'maxDuration')
+          )}
+      {(Object.keys(columnLabels) as Array<keyof TraceColumns>).map((column) => (
+        <InlineField
+          key={column}
+          labelWidth={defaultLabelWidth}
+          label={columnLabels[column]}
+          tooltip={Traces.Column.tooltip}
+        >
+          <Input width={defaultInputWidth} value={columns[column] ?? ''} onChange={handleColumnChange(column)} />
+        </InlineField>
+      ))}
+      <InlineField
+        labelWidth={defaultLabelWidth}
+        label={Traces.DurationUnit.label}
+        tooltip={Traces.DurationUnit.tooltip}
+      >
+        <Select
+          width={defaultInputWidth}
+          options={durationUnits}
+          value={traces.durationUnit ?? 'ms'}
+          onChange={(e) => handleChange({ durationUnit: e.value })}
+        />
+      </InlineField>
+    </React.Fragment>
+  );
+}
@@ -6,17 +6,37 @@ import { QueryEditorProps } from '@grafana/data';
 import { QueryFormatSelect } from 'components/QueryFormatSelect';
 import { QueryTypeSwitcher } from 'components/QueryTypeSwitcher';
 import { SqlEditorHeightInput } from 'components/SqlEditorHeightInput';
+import { StreamEditor } from 'components/StreamEditor';
 import { QueryBuilderSettings } from 'components/QueryBuilderSettings';
 import { QueryBuilder } from './QueryBuilder';
 import { SqlEditor } from './SqlEditor';
+import { SystemEditor } from './SystemEditor';
+import { TopicEditor } from './TopicEditor';
+import { TracesEditor } from './TracesEditor';
 
 import { DatasourceProvider } from './DatasourceContext';
 import { TablesProvider } from './TablesContext';
 import { BuilderSettingsProvider, EditorHeightProvider } from './EditorSettingsContext';
 
-import { QueryFormat, QueryType, YDBBuilderQuery, YDBQuery, YDBSQLQuery } from './types';
+import {
+  QueryFormat,
+  QueryType,
+  YDBBuilderQuery,
+  YDBQuery,
+  YDBSQLQuery,
+  YDBSystemQuery,
+  YDBTopicQuery,
+  YDBTracesQuery,
+} from './types';
 import { YdbDataSourceOptions } from 'containers/ConfigEditor/types';
-import { defaultYDBBuilderQuery, defaultYDBSQLQuery } from './constants';
+import {
+  defaultYDBBuilderQuery,
+  defaultYDBSQLQuery,
+  defaultYDBSystemQuery,
+  defaultYDBTopicLagQuery,
+  defaultYDBTopicQuery,
+  defaultYDBTracesQuery,
+} from './constants';
 import { DataSource } from 'datasource';
 import { getRawSqlFromBuilderOptions } from './prepare-query';
 
@@ -31,10 +51,31 @@ function normalizeSQLQuery(query: YDBSQLQuery): YDBSQLQuery {
   return { ...defaultYDBSQLQuery, ...query };
 }
 
+function normalizeTracesQuery(query: YDBTracesQuery): YDBTracesQuery {
+  return { ...defaultYDBTracesQuery, ...query };
+}
+
+function normalizeTopicQuery(query: YDBTopicQuery): YDBTopicQuery {
+  return { ...(query.queryType === 'topicLag' ? defaultYDBTopicLagQuery : defaultYDBTopicQuery), ...query };
+}
+
+function normalizeSystemQuery(query: YDBSystemQuery): YDBSystemQuery {
+  return { ...defaultYDBSystemQuery, ...query };
+}
+
 function normalizeQuery(query: YDBQuery) {
   if (query.queryType === 'sql') {
     return normalizeSQLQuery(query);
   }
+  if (query.queryType === 'traces') {
+    return normalizeTracesQuery(query);
+  }
+  if (query.queryType === 'topic' || query.queryType === 'topicLag') {
+    return normalizeTopicQuery(query);
+  }
+  if (query.queryType === 'system') {
+    return normalizeSystemQuery(query);
+  }
   return normalizeBuilderQuery(query);
 }
 
@@ -43,6 +84,8 @@ export function YDBQueryEditor({ query: baseQuery, onChange, onRunQuery, datasou
   const { queryType, queryFormat, rawSql, builderOptions } = query;
 
   const { rawSqlBuilder } = builderOptions;
+  // topic and system queries are not SQL, their editors choose formats themselves
+  const sqlQuery = queryType === 'sql' || queryType === 'builder';
 
   const handleChangeQueryAttribute = <T,>(value: Partial<T>) => {
     onChange({ ...query, ...value });
@@ -50,6 +93,19 @@ export function YDBQueryEditor({ query: baseQuery, onChange, onRunQuery, datasou
 
   const handleChangeQueryType = (type: QueryType) => {
     const params: Partial<YDBQuery> = { queryType: type };
+    if (type === 'traces') {
+      handleChangeQueryAttribute<YDBQuery>({ ...defaultYDBTracesQuery, ...params });
+      return;
+    }
+    if (type === 'topic' || type === 'topicLag') {
+      const defaults = type === 'topic' ? defaultYDBTopicQuery : defaultYDBTopicLagQuery;
+      handleChangeQueryAttribute<YDBQuery>({ ...defaults, ...params, topic: { ...defaults.topic!, ...query.topic } });
+      return;
+    }
+    if (type === 'system') {
+      handleChangeQueryAttribute<YDBQuery>({ ...defaultYDBSystemQuery, ...params });
+      return;
+    }
     if (type === 'builder') {
       //need to recalculate rawSql based on BuilderOptions to get correct preview after switch to builder mode
       params.rawSql =
@@ -82,12 +138,30 @@ export function YDBQueryEditor({ query: baseQuery, onChange, onRunQuery, datasou
                     {queryType === 'sql' && <SqlEditorHeightInput />}
                     {queryType === 'builder' && <QueryBuilderSettings />}
                   </div>
-                  <QueryFormatSelect format={queryFormat} onChange={handleChangeQueryFormat} />
-                  {queryType === 'builder' ? (
+                  {sqlQuery && (
+                    <QueryFormatSelect format={queryFormat} onChange={handleChangeQueryFormat} />
+                  )}
+                  {queryType === 'builder' && (
                     <QueryBuilder query={query} onChange={handleChangeQueryAttribute<YDBBuilderQuery>} />
-                  ) : (
+                  )}
+                  {queryType === 'sql' && (
                     <SqlEditor onChange={handleChangeQueryAttribute<YDBSQLQuery>} query={query} />
                   )}
+                  {queryType === 'traces' && (
+                    <TracesEditor query={query} onChange={handleChangeQueryAttribute<YDBTracesQuery>} />
+                  )}
+                  {(queryType === 'topic' || queryType === 'topicLag') && (
+                    <TopicEditor query={query} onChange={handleChangeQueryAttribute<YDBTopicQuery>} />
+                  )}
+                  {queryType === 'system' && (
+                    <SystemEditor query={query} onChange={handleChangeQueryAttribute<YDBSystemQuery>} />
+                  )}
+                  {sqlQuery && (
+                    <StreamEditor
+                      stream={query.stream}
+                      onChange={(stream) => handleChangeQueryAttribute<YDBQuery>({ stream })}
+                    />
+                  )}
                   <Button type="submit">Run Query</Button>
                 </React.Fragment>
               )}
@@ -1,8 +1,18 @@
-import { ExpressionName, QueryFormat, YDBBuilderQuery, YDBSQLQuery } from './types';
+import {
+  ExpressionName,
+  QUERY_MODEL_VERSION,
+  QueryFormat,
+  YDBBuilderQuery,
+  YDBSQLQuery,
+  YDBSystemQuery,
+  YDBTopicQuery,
+  YDBTracesQuery,
+} from './types';
 
 export const LIMIT = '100';
 
 export const defaultYDBBuilderQuery: Partial<YDBBuilderQuery> = {
+  version: QUERY_MODEL_VERSION,
   queryType: 'builder',
   rawSql: '',
   builderOptions: {
@@ -12,11 +22,56 @@ export const defaultYDBBuilderQuery: Partial<YDBBuilderQuery> = {
   queryFormat: 'table',
 };
 export const defaultYDBSQLQuery: Partial<YDBSQLQuery> = {
+  version: QUERY_MODEL_VERSION,
   queryType: 'sql',
   rawSql: '',
   queryFormat: 'table',
 };
 
+export const defaultYDBTracesQuery: Partial<YDBTracesQuery> = {
+  version: QUERY_MODEL_VERSION,
+  queryType: 'traces',
+  rawSql: '',
+  queryFormat: 'traces',
+  builderOptions: {},
+  traces: {
+    mode: 'trace',
+    columns: {},
+  },
+};
+
+export const defaultYDBTopicQuery: Partial<YDBTopicQuery> = {
+  version: QUERY_MODEL_VERSION,
+  queryType: 'topic',
+  rawSql: '',
+  queryFormat: 'logs',
+  builderOptions: {},
+  topic: {
+    path: '',
+    parser: 'text',
+  },
+};
+
+export const defaultYDBTopicLagQuery: Partial<YDBTopicQuery> = {
+  ...defaultYDBTopicQuery,
+  queryType: 'topicLag',
+  queryFormat: 'timeseries',
+  topic: {
+    path: '',
+  },
+};
+
+export const defaultYDBSystemQuery: Partial<YDBSystemQuery> = {
+  version: QUERY_MODEL_VERSION,
+  queryType: 'system',
+  rawSql: '',
+  queryFormat: 'table',
+  builderOptions: {},
+  system: {
+    view: 'top_queries_by_cpu_time',
+  },
+};
+
 export const defaultLabelWidth = 16;
 export const defaultInputWidth = 40;
 export const defaultNumberInputWidth = 10;
@@ -26,6 +81,7 @@ export const QueryFormatNames: Record<QueryFormat, string> = {
   table: 'Table',
   timeseries: 'Time Series',
   logs: 'Logs',
+  traces: 'Traces',
 } as const;
 
 export const MONACO_LANGUAGE_SQL = 'sql';
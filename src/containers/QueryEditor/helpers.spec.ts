@@ -1,4 +1,4 @@
-import { escapeAndWrapString } from './helpers';
+import { escapeAndWrapString, hashString } from './helpers';
 
 describe('should properly escapeAndWrapString', () => {
   it('simple string with backticks', () => {
@@ -14,3 +14,12 @@ describe('should properly escapeAndWrapString', () => {
     expect(escapeAndWrapString('fo\\o`"', '"')).toBe('"fo\\\\o`\\""');
   });
 });
+
+describe('hashString', () => {
+  it('returns the FNV-1a hash', () => {
+    expect(hashString('')).toBe('cbf29ce484222325');
+    expect(hashString('a')).toBe('af63dc4c8601ec8c');
+    expect(hashString('foobar')).toBe('85944171f73967e8');
+    expect(hashString('ü€')).toBe('0a759c07b6bbb215');
+  });
+});
@@ -1,23 +1,10 @@
 import * as React from 'react';
 import { nanoid } from 'nanoid';
 
-import { ExpressionName, QueryFormat, TableField, TableFieldBackend } from './types';
+import { ExpressionName, TableField, TableFieldBackend } from './types';
 import { expressionWithMultipleParams, expressionWithoutParams, panelVariables } from './constants';
 import { getTemplateSrv } from '@grafana/runtime';
 
-export function ConvertQueryFormatToVisualizationType(format: QueryFormat) {
-  switch (format) {
-    case 'table':
-      return 1;
-    case 'timeseries':
-      return 0;
-    case 'logs':
-      return 2;
-    default:
-      return 1;
-  }
-}
-
 export const defaultWrapper = '`';
 
 const escapeBackticksRe = /[\\`]/g;
@@ -170,3 +157,19 @@ export function useVariables(props?: UseVariablesProps) {
 
   return result;
 }
+
+// hashString returns the 64-bit FNV-1a hash of UTF-16 code units of the string, e.g. to name Grafana Live
+// channels of queries. The hash is kept in 16-bit limbs since numbers can't hold 64-bit products.
+export function hashString(st: string) {
+  let [h0, h1, h2, h3] = [0x2325, 0x8422, 0x9ce4, 0xcbf2];
+  for (let i = 0; i < st.length; i++) {
+    h0 ^= st.charCodeAt(i);
+    // the prime is 2^40 + 0x1b3
+    const t0 = h0 * 0x1b3;
+    const t1 = h1 * 0x1b3 + (t0 >>> 16);
+    const t2 = h2 * 0x1b3 + (h0 << 8) + (t1 >>> 16);
+    const t3 = h3 * 0x1b3 + (h1 << 8) + (t2 >>> 16);
+    [h0, h1, h2, h3] = [t0 & 0xffff, t1 & 0xffff, t2 & 0xffff, t3 & 0xffff];
+  }
+  return [h3, h2, h1, h0].map((h) => h.toString(16).padStart(4, '0')).join('');
+}
@@ -0,0 +1,28 @@
+import fs from 'fs';
+import path from 'path';
+
+import { getRawSqlFromBuilderOptions } from './prepare-query';
+import { QueryFormat, SqlBuilderOptions } from './types';
+
+// the backend builder compiles the same inputs in pkg/builder/builder_test.go,
+// both must produce the YQL of the golden files
+const testdata = path.resolve(__dirname, '../../../pkg/builder/testdata');
+
+interface GoldenQuery {
+  format: QueryFormat;
+  builderOptions: SqlBuilderOptions;
+}
+
+const inputs = fs.readdirSync(testdata).filter((file) => file.endsWith('.json'));
+
+describe('builder golden files', () => {
+  it('has inputs', () => {
+    expect(inputs.length).toBeGreaterThan(0);
+  });
+
+  it.each(inputs)('%s', (input) => {
+    const query: GoldenQuery = JSON.parse(fs.readFileSync(path.join(testdata, input), 'utf8'));
+    const expected = fs.readFileSync(path.join(testdata, input.replace(/\.json$/, '.yql')), 'utf8');
+    expect(getRawSqlFromBuilderOptions(query.builderOptions, query.format)).toBe(expected);
+  });
+});
@@ -9,17 +9,46 @@ export interface OnChangeQueryAttribute<T> {
 export const QueryTypes = {
   SQL: 'sql',
   Builder: 'builder',
+  Traces: 'traces',
+  Topic: 'topic',
+  TopicLag: 'topicLag',
+  System: 'system',
 } as const;
 
 export type QueryType = (typeof QueryTypes)[keyof typeof QueryTypes];
 
+export const QUERY_MODEL_VERSION = 1;
+
 export interface YDBQueryBase extends DataQuery {
+  version?: number;
   rawSql: string;
   builderOptions: SqlBuilderOptions;
   queryFormat: QueryFormat;
+  mode?: QueryMode;
+  options?: QueryOptions;
   meta?: {
     timezone?: string;
   };
+  variableQuery?: boolean;
+  supplementaryQueryType?: string;
+  logContext?: LogContext;
+  annotation?: boolean;
+  stream?: StreamOptions;
+}
+
+// StreamOptions stream new rows of the query ordered by a monotonic column over Grafana Live
+export interface StreamOptions {
+  column: string;
+  interval?: string;
+  limit?: number;
+}
+
+export interface LogContext {
+  timeNs: string;
+  columns?: Record<string, unknown>;
+  tieBreaker?: unknown;
+  direction?: 'backward' | 'forward';
+  limit?: number;
 }
 
 export interface YDBSQLQuery extends YDBQueryBase {
@@ -30,7 +59,90 @@ export interface YDBBuilderQuery extends YDBQueryBase {
   queryType: typeof QueryTypes.Builder;
 }
 
-export type YDBQuery = YDBSQLQuery | YDBBuilderQuery;
+export interface YDBTracesQuery extends YDBQueryBase {
+  queryType: typeof QueryTypes.Traces;
+  traces?: TraceOptions;
+}
+
+// YDBTopicQuery streams messages of the topic or, as a topic lag query, returns lag metrics of its consumers
+export interface YDBTopicQuery extends YDBQueryBase {
+  queryType: typeof QueryTypes.Topic | typeof QueryTypes.TopicLag;
+  topic?: TopicOptions;
+}
+
+// YDBSystemQuery reads a system view of the catalog of the backend
+export interface YDBSystemQuery extends YDBQueryBase {
+  queryType: typeof QueryTypes.System;
+  system?: SystemOptions;
+}
+
+export type YDBQuery = YDBSQLQuery | YDBBuilderQuery | YDBTracesQuery | YDBTopicQuery | YDBSystemQuery;
+
+export type SystemWindow = 'minute' | 'hour';
+
+export interface SystemOptions {
+  view: string;
+  window?: SystemWindow;
+  limit?: number;
+}
+
+// SystemView is a view of the .sys folder of the database, views with windows are aggregated over intervals
+export interface SystemView {
+  name: string;
+  title: string;
+  description: string;
+  windows?: SystemWindow[];
+  timeColumn?: string;
+  columns: Array<{ name: string; unit?: string }>;
+}
+
+export type TopicParser = 'text' | 'json' | 'cdc';
+
+// TopicOptions stream messages of a YDB topic or records of a changefeed of a table over Grafana Live
+export interface TopicOptions {
+  path: string;
+  table?: string;
+  changefeed?: string;
+  consumer?: string;
+  startFrom?: string;
+  parser?: TopicParser;
+  fields?: string[];
+  rateLimit?: number;
+  limit?: number;
+}
+
+export type TraceMode = 'trace' | 'search';
+
+export interface TraceColumns {
+  traceId?: string;
+  spanId?: string;
+  parentSpanId?: string;
+  serviceName?: string;
+  operationName?: string;
+  startTime?: string;
+  duration?: string;
+  tags?: string;
+}
+
+export interface TraceOptions {
+  table?: string;
+  mode?: TraceMode;
+  traceId?: string;
+  columns: TraceColumns;
+  durationUnit?: 'ns' | 'us' | 'ms' | 's';
+  serviceName?: string;
+  operationName?: string;
+  minDuration?: string;
+  maxDuration?: string;
+  limit?: number;
+}
+
+export interface ChangefeedDescription {
+  name: string;
+  mode: string;
+  format: string;
+  state: string;
+}
 
 export interface TableFieldBackend {
   Name: string;
@@ -42,6 +154,14 @@ export interface TableField {
   type?: string;
 }
 
+// Completion is a suggestion of the /completions resource ranked by the backend
+export interface Completion {
+  label: string;
+  kind: 'table' | 'column' | 'function' | 'module' | 'macro';
+  detail?: string;
+  insertText: string;
+}
+
 export interface LogTimeField {
   name: string | null;
   cast?: string | null;
@@ -58,11 +178,20 @@ export interface SqlBuilderOptionsList {
   groupBy?: string[];
   aggregations?: AggregationType[];
   orderBy?: OrderByType[];
+  logContextColumns?: string[];
+  logContextField?: string | null;
 }
 
 export type SqlBuilderOptions = SqlBuilderOptionsList;
 
-export type QueryFormat = 'table' | 'timeseries' | 'logs';
+export type QueryFormat = 'table' | 'timeseries' | 'logs' | 'traces';
+
+export type QueryMode = 'serializable' | 'snapshotReadOnly' | 'onlineReadOnly' | 'staleReadOnly' | 'explain';
+
+export interface QueryOptions {
+  rowLimit?: number;
+  timeout?: string;
+}
 
 export const LogicalOperations = ['and', 'or'] as const;
 
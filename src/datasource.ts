@@ -9,23 +9,47 @@ import {
   DataQueryResponse,
   vectorator,
   ScopedVars,
+  DataSourceWithSupplementaryQueriesSupport,
+  SupplementaryQueryType,
+  DataSourceWithLogsContextSupport,
+  LogRowModel,
+  RowContextOptions,
+  AnnotationQuery,
+  LiveChannelScope,
 } from '@grafana/data';
-import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
+import { config, DataSourceWithBackend, getGrafanaLiveSrv, getTemplateSrv } from '@grafana/runtime';
+import { lastValueFrom, map, merge, Observable } from 'rxjs';
 
 import { YdbDataSourceOptions } from 'containers/ConfigEditor/types';
-import { ConvertQueryFormatToVisualizationType, normalizeFields, wrapString } from 'containers/QueryEditor/helpers';
+import { hashString, normalizeFields, wrapString } from 'containers/QueryEditor/helpers';
 
-import { TableField, YDBQuery } from 'containers/QueryEditor/types';
+import {
+  ChangefeedDescription,
+  Completion,
+  SystemView,
+  TableField,
+  TableFieldBackend,
+  YDBQuery,
+} from 'containers/QueryEditor/types';
 
 const defaultQuery: Partial<YDBQuery> = {};
 
-export class DataSource extends DataSourceWithBackend<YDBQuery, YdbDataSourceOptions> {
+export class DataSource
+  extends DataSourceWithBackend<YDBQuery, YdbDataSourceOptions>
+  implements DataSourceWithSupplementaryQueriesSupport<YDBQuery>, DataSourceWithLogsContextSupport<YDBQuery>
+{
   database: string;
-  // This enables default annotation support for 7.2+
-  annotations = {};
+  // channels of streams are per user when rows are read with the user's identity
+  forwardsIdentity: boolean;
+  // This enables default annotation support for 7.2+, rows of annotation queries are converted by the backend
+  annotations = {
+    prepareQuery: (anno: AnnotationQuery<YDBQuery>): YDBQuery | undefined =>
+      anno.target ? { ...anno.target, annotation: true } : undefined,
+  };
   constructor(instanceSettings: DataSourceInstanceSettings<YdbDataSourceOptions>) {
     super(instanceSettings);
     this.database = instanceSettings.jsonData.dbLocation ?? '';
+    this.forwardsIdentity = instanceSettings.jsonData.authKind === 'ForwardOAuthIdentity';
   }
 
   async fetchTables(): Promise<string[]> {
@@ -34,8 +58,23 @@ export class DataSource extends DataSourceWithBackend<YDBQuery, YdbDataSourceOpt
   }
 
   async fetchFields(table: string): Promise<TableField[]> {
-    const fields = await this.getResource('listFields', { table });
-    return normalizeFields(fields);
+    // fields are returned by the table path resolved relative to the database
+    const fields: Record<string, TableFieldBackend[]> = await this.getResource('listFields', { table });
+    return normalizeFields(Object.values(fields)[0] ?? []);
+  }
+
+  // fetchCompletions returns suggestions for the query at the cursor, the cursor is an offset of the editor model
+  async fetchCompletions(query: string, cursor: number): Promise<Completion[]> {
+    return this.getResource('completions', { query, cursor });
+  }
+
+  async fetchChangefeeds(table: string): Promise<ChangefeedDescription[]> {
+    const description = await this.getResource('describeTable', { table });
+    return description.changefeeds ?? [];
+  }
+
+  async fetchSystemViews(): Promise<SystemView[]> {
+    return this.getResource('systemViews');
   }
 
   getDefaultQuery(_: CoreApp): Partial<YDBQuery> {
@@ -75,7 +114,6 @@ export class DataSource extends DataSourceWithBackend<YDBQuery, YdbDataSourceOpt
       .map((t) => {
         return {
           ...t,
-          format: ConvertQueryFormatToVisualizationType(t.queryFormat),
           meta: {
             ...t.meta,
             timezone: this.getTimezone(request),
@@ -83,30 +121,119 @@ export class DataSource extends DataSourceWithBackend<YDBQuery, YdbDataSourceOpt
         };
       });
 
-    return super.query({
-      ...request,
-      targets,
+    const logsQueries = new Map(
+      targets.filter((t) => t.queryFormat === 'logs' && !t.logContext).map((t) => [t.refId, t] as const)
+    );
+
+    // topic queries are always streamed
+    const streams = targets.filter(
+      (t) => t.queryType === 'topic' || (t.stream?.column && request.app !== CoreApp.UnifiedAlerting)
+    );
+    if (streams.length === 0) {
+      return withLogsQueries(super.query({ ...request, targets }), logsQueries);
+    }
+    const observables: Array<Observable<DataQueryResponse>> = streams.map((t) => this.stream(request, t));
+    const rest = targets.filter((t) => !streams.includes(t));
+    if (rest.length > 0) {
+      observables.push(withLogsQueries(super.query({ ...request, targets: rest }), logsQueries));
+    }
+    return merge(...observables);
+  }
+
+  // the backend polls new rows of the query or reads messages of the topic and sends them over Grafana Live,
+  // panels with the same query share the poller or the topic reader. Rows of datasources forwarding the user's
+  // identity are read with the user's token, so their channels are per user and the backend checks the user
+  private stream(request: DataQueryRequest<YDBQuery>, target: YDBQuery): Observable<DataQueryResponse> {
+    const query = this.applyTemplateVariables(target, request.scopedVars);
+    const data = { query, rangeMs: request.range.to.valueOf() - request.range.from.valueOf() };
+    const channel = hashString(JSON.stringify(data));
+    const path = this.forwardsIdentity
+      ? `stream/${hashString(config.bootData.user.login)}/${channel}`
+      : `stream/${channel}`;
+    return getGrafanaLiveSrv().getDataStream({
+      key: `${request.requestId}.${target.refId}`,
+      addr: {
+        scope: LiveChannelScope.DataSource,
+        namespace: this.uid,
+        path,
+        data,
+      },
     });
   }
+
+  showContextToggle(row?: LogRowModel): boolean {
+    const query = logsQuery(row);
+    return Boolean(
+      query?.queryType === 'builder' && query.builderOptions.table && query.builderOptions.logTimeField?.name
+    );
+  }
+
+  // the backend reads rows around the log row with the same values of log context columns
+  async getLogRowContext(row: LogRowModel, options?: RowContextOptions): Promise<DataQueryResponse> {
+    const query = logsQuery(row);
+    if (!query) {
+      return { data: [] };
+    }
+    // columns missing from the frame are not filtered, a missing or null tie breaker is not compared
+    const fields = new Map(row.dataFrame.fields.map((f) => [f.name, f.values.get(row.rowIndex) ?? null]));
+    const { logContextColumns = [], logContextField } = query.builderOptions;
+    const logContext = {
+      timeNs: row.timeEpochNs,
+      columns: Object.fromEntries(
+        logContextColumns.filter((column) => fields.has(column)).map((column) => [column, fields.get(column)])
+      ),
+      tieBreaker: logContextField ? fields.get(logContextField) ?? undefined : undefined,
+      direction: options?.direction === 'FORWARD' ? ('forward' as const) : ('backward' as const),
+      limit: options?.limit,
+    };
+    const req = {
+      targets: [{ ...query, refId: `log-context-${query.refId}`, logContext }],
+      range: { from: row.timeEpochMs, to: row.timeEpochMs },
+    } as unknown as DataQueryRequest<YDBQuery>;
+    return lastValueFrom(this.query(req));
+  }
+  getSupportedSupplementaryQueryTypes(): SupplementaryQueryType[] {
+    return [SupplementaryQueryType.LogsVolume];
+  }
+
+  // the backend derives the log volume query from builder logs queries with a log time field
+  getDataProvider(type: SupplementaryQueryType, request: DataQueryRequest<YDBQuery>) {
+    if (type !== SupplementaryQueryType.LogsVolume) {
+      return undefined;
+    }
+    const targets = request.targets
+      .filter(
+        (t) =>
+          t.hide !== true &&
+          t.queryType === 'builder' &&
+          t.queryFormat === 'logs' &&
+          t.builderOptions.table &&
+          t.builderOptions.logTimeField?.name
+      )
+      .map((t) => ({ ...t, refId: `log-volume-${t.refId}`, supplementaryQueryType: type }));
+    if (targets.length === 0) {
+      return undefined;
+    }
+    return this.query({ ...request, targets });
+  }
+
   async metricFindQuery(query: YDBQuery | string, options: any) {
     const ydbQuery: Partial<YDBQuery> = typeof query === 'string' ? { rawSql: query, queryType: 'sql' } : query;
 
     if (!ydbQuery.rawSql) {
       return [];
     }
-    const frame = await this.runQuery(ydbQuery, options);
+    // the backend deduplicates and sorts values and returns them as __text and __value fields
+    const frame = await this.runQuery({ ...ydbQuery, variableQuery: true }, options);
     if ('error' in frame) {
       throw new Error(frame.error);
     }
-    if (frame.fields?.length === 0) {
+    const texts = frame.fields?.find((f) => f.name === '__text')?.values;
+    const values = frame.fields?.find((f) => f.name === '__value')?.values;
+    if (!texts || !values) {
       return [];
     }
-    if (frame?.fields?.length === 1) {
-      return vectorator(frame?.fields[0]?.values).map((value) => ({ text: String(value), value: String(value) }));
-    }
-    // convention - assume the first field is an id field
-    const ids = frame?.fields[0]?.values;
-    return vectorator(frame?.fields[1]?.values).map((value, i) => ({ text: String(value), value: ids.get(i) }));
+    return vectorator(values).map((value, i) => ({ text: String(texts.get(i)), value: String(value) }));
   }
 
   private replace(value = '', scopedVars?: ScopedVars) {
@@ -131,9 +258,55 @@ export class DataSource extends DataSourceWithBackend<YDBQuery, YdbDataSourceOpt
 
   applyTemplateVariables(query: YDBQuery, scoped: ScopedVars): YDBQuery {
     let rawQuery = query.rawSql || '';
+    if (query.queryType === 'traces' && query.traces) {
+      const { traceId, serviceName, operationName } = query.traces;
+      const replace = (value?: string) => (value ? getTemplateSrv().replace(value, scoped) : value);
+      return {
+        ...query,
+        traces: {
+          ...query.traces,
+          traceId: replace(traceId),
+          serviceName: replace(serviceName),
+          operationName: replace(operationName),
+        },
+      };
+    }
+    if ((query.queryType === 'topic' || query.queryType === 'topicLag') && query.topic) {
+      const { path, table, consumer } = query.topic;
+      const replace = (value?: string) => (value ? getTemplateSrv().replace(value, scoped) : value);
+      return {
+        ...query,
+        topic: { ...query.topic, path: replace(path) ?? '', table: replace(table), consumer: replace(consumer) },
+      };
+    }
     return {
       ...query,
       rawSql: this.replace(rawQuery, scoped),
     };
   }
 }
+
+// frames of logs queries carry their query, so the log context of a row is read with the query of its request
+function withLogsQueries(
+  response: Observable<DataQueryResponse>,
+  queries: Map<string, YDBQuery>
+): Observable<DataQueryResponse> {
+  if (queries.size === 0) {
+    return response;
+  }
+  return response.pipe(
+    map((res) => {
+      res.data.forEach((frame: DataFrame) => {
+        const query = frame.refId ? queries.get(frame.refId) : undefined;
+        if (query) {
+          frame.meta = { ...frame.meta, custom: { ...frame.meta?.custom, logsQuery: query } };
+        }
+      });
+      return res;
+    })
+  );
+}
+
+function logsQuery(row?: LogRowModel): YDBQuery | undefined {
+  return row?.dataFrame.meta?.custom?.logsQuery;
+}
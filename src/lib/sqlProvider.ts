@@ -2,69 +2,86 @@ import { CancellationToken, Position, editor as monacoEditor, languages, IRange
 import { ParseResult, parseGenericSql } from 'sql-autocomplete-parsers';
 
 import { DataSource } from 'datasource';
-import { wrapString } from 'containers/QueryEditor/helpers';
+import { Completion } from 'containers/QueryEditor/types';
 
 const alphabet = 'abcdefghijklmnopqrstuvwxyz'.split('');
 
-const KEEP_CACHE_MILLIS = 60 * 60 * 1000;
-
-function getFieldsWithCache(datasource: DataSource) {
-  const cache = new Map<string, string[]>();
-  return async (value: string) => {
-    const existed = cache.get(value);
-    if (existed) {
-      return existed;
-    }
-    const fields = await datasource.fetchFields(value);
-    const fieldNames = fields.map((f) => f.name);
-    cache.set(value, fieldNames);
-    setTimeout(() => {
-      cache.delete(value);
-    }, KEEP_CACHE_MILLIS);
-    return fieldNames;
+// COMPLETIONS_DEBOUNCE_MILLIS delays requests of completions while the user is typing
+const COMPLETIONS_DEBOUNCE_MILLIS = 200;
+
+const completionKinds: Record<Completion['kind'], languages.CompletionItemKind> = {
+  table: languages.CompletionItemKind.Value,
+  column: languages.CompletionItemKind.Field,
+  function: languages.CompletionItemKind.Function,
+  module: languages.CompletionItemKind.Module,
+  macro: languages.CompletionItemKind.Variable,
+};
+
+// debounceCompletions requests completions of the last call made within the debounce interval,
+// superseded and cancelled calls resolve with no completions
+function debounceCompletions(datasource: DataSource) {
+  let timeoutId: ReturnType<typeof setTimeout> | undefined;
+  let resolvePending: ((completions: Completion[]) => void) | undefined;
+  return (query: string, cursor: number, token: CancellationToken): Promise<Completion[]> => {
+    clearTimeout(timeoutId);
+    resolvePending?.([]);
+    return new Promise((resolve) => {
+      resolvePending = resolve;
+      const id = setTimeout(async () => {
+        if (resolvePending === resolve) {
+          resolvePending = undefined;
+        }
+        if (token.isCancellationRequested) {
+          resolve([]);
+          return;
+        }
+        try {
+          resolve(await datasource.fetchCompletions(query, cursor));
+        } catch {
+          resolve([]);
+        }
+      }, COMPLETIONS_DEBOUNCE_MILLIS);
+      timeoutId = id;
+      token.onCancellationRequested(() => {
+        clearTimeout(id);
+        resolve([]);
+      });
+    });
   };
 }
 
-export function createProvideSuggestionsFunction(
-  datasource: DataSource,
-  tables: Array<{ label: string; value: string }>,
-  variables: string[]
-) {
-  const getFields = getFieldsWithCache(datasource);
+export function createProvideSuggestionsFunction(datasource: DataSource, variables: string[]) {
+  const fetchCompletions = debounceCompletions(datasource);
   return async (
     model: monacoEditor.ITextModel,
     cursorPosition: Position,
     _context: languages.CompletionContext,
-    _token: CancellationToken
+    token: CancellationToken
   ) => {
     const [queryBeforeCursor, queryAfterCursor] = getQueriesAroundCursor(model, cursorPosition);
     const parseResult = parseGenericSql(queryBeforeCursor, queryAfterCursor);
-    const suggestions = await getSuggestions(model, cursorPosition, parseResult, tables, variables, getFields);
+    const rangeToInsertSuggestion = getRangeToInsertSuggestion(model, cursorPosition);
+    // tables, columns and functions are suggested by the backend which knows the schema
+    const completions = await fetchCompletions(model.getValue(), model.getOffsetAt(cursorPosition), token);
+    const suggestions: languages.CompletionItem[] = completions.map((completion, index) => ({
+      label: completion.label,
+      insertText: completion.insertText,
+      kind: completionKinds[completion.kind] ?? languages.CompletionItemKind.Text,
+      detail: completion.detail,
+      range: rangeToInsertSuggestion,
+      sortText: suggestionIndexToWeight(0) + String(index).padStart(4, '0'),
+    }));
 
-    return { suggestions };
+    return { suggestions: [...suggestions, ...getSuggestions(parseResult, variables, rangeToInsertSuggestion)] };
   };
 }
 
-async function getSuggestions(
-  model: monacoEditor.ITextModel,
-  cursorPosition: Position,
+// getSuggestions returns suggestions of the parsed query which don't depend on the schema
+function getSuggestions(
   parseResult: ParseResult,
-  tablesForSuggest: Array<{ label: string; value: string }>,
   variablesForSuggest: string[],
-  getFields: (value: string) => Promise<string[]>
-): Promise<languages.CompletionItem[]> {
-  const rangeToInsertSuggestion = getRangeToInsertSuggestion(model, cursorPosition);
-
-  if (shouldSuggestTables(parseResult)) {
-    return tablesForSuggest.map(({ label, value }) => ({
-      label,
-      insertText: wrapString(value),
-      kind: languages.CompletionItemKind.Value,
-      detail: 'Table',
-      range: rangeToInsertSuggestion,
-    }));
-  }
-
+  rangeToInsertSuggestion: IRange
+): languages.CompletionItem[] {
   const suggestions: languages.CompletionItem[] = [];
 
   parseResult.suggestColumnAliases?.forEach((columnAliasSuggestion) => {
@@ -74,31 +91,10 @@ async function getSuggestions(
       kind: languages.CompletionItemKind.Interface,
       detail: 'Column alias',
       range: rangeToInsertSuggestion,
-      sortText: suggestionIndexToWeight(0),
+      sortText: suggestionIndexToWeight(1),
     });
   });
 
-  if (parseResult.suggestColumns?.tables) {
-    for (const table of parseResult.suggestColumns?.tables) {
-      const tableIdentifier = table.identifierChain.map((identifier) => identifier.name).join('.');
-      const fields = await getFields(tableIdentifier);
-      fields.forEach((columnName) => {
-        let columnNameSuggestion = wrapString(columnName);
-        if (table.alias) {
-          columnNameSuggestion = `${table.alias}.${wrapString(columnName)}`;
-        }
-        suggestions.push({
-          label: columnName,
-          insertText: columnNameSuggestion,
-          kind: languages.CompletionItemKind.Field,
-          detail: 'Column',
-          range: rangeToInsertSuggestion,
-          sortText: suggestionIndexToWeight(1),
-        });
-      });
-    }
-  }
-
   parseResult.suggestKeywords?.forEach((keywordSuggestion, index) => {
     suggestions.push({
       label: keywordSuggestion.value,
@@ -110,22 +106,6 @@ async function getSuggestions(
     });
   });
 
-  if (parseResult.suggestAggregateFunctions) {
-    const aggregateFunctionsSortText = getLastSortText(suggestions);
-
-    getAggregateFunctionNamesToSuggest().forEach((functionName) => {
-      suggestions.push({
-        label: functionName,
-        insertText: getFunctionInsertTextWithCursor(functionName),
-        insertTextRules: languages.CompletionItemInsertTextRule.InsertAsSnippet,
-        kind: languages.CompletionItemKind.Function,
-        detail: 'Aggregate function',
-        range: rangeToInsertSuggestion,
-        sortText: aggregateFunctionsSortText,
-      });
-    });
-  }
-
   // @ts-ignore is not typed properly in sql-autocomplete-parsers
   if (parseResult.suggestValues) {
     variablesForSuggest?.forEach((variable) => {
@@ -139,22 +119,6 @@ async function getSuggestions(
     });
   }
 
-  if (parseResult.suggestFunctions) {
-    const functionsSortText = getLastSortText(suggestions);
-
-    getFunctionNamesToSuggest().forEach((functionName) => {
-      suggestions.push({
-        label: functionName,
-        insertText: getFunctionInsertTextWithCursor(functionName),
-        insertTextRules: languages.CompletionItemInsertTextRule.InsertAsSnippet,
-        kind: languages.CompletionItemKind.Function,
-        detail: 'Function',
-        range: rangeToInsertSuggestion,
-        sortText: functionsSortText,
-      });
-    });
-  }
-
   return suggestions;
 }
 
@@ -177,15 +141,6 @@ function getQueriesAroundCursor(model: monacoEditor.ITextModel, cursorPosition:
   return [queryBeforeCursor, queryAfterCursor];
 }
 
-function shouldSuggestTables({ suggestTables }: ParseResult): boolean {
-  if (typeof suggestTables !== 'object') {
-    return false;
-  }
-  // This condition is strange, but only when parseResult.suggestTables === {} or {onlyTables: true},
-  // it actually wants us to suggest tables;
-  return suggestTables.onlyTables || Object.keys(suggestTables).length === 0;
-}
-
 function getRangeToInsertSuggestion(model: monacoEditor.ITextModel, cursorPosition: Position): IRange {
   const { startColumn: lastWordStartColumn, endColumn: lastWordEndColumn } = model.getWordUntilPosition(cursorPosition);
   // https://github.com/microsoft/monaco-editor/discussions/3639#discussioncomment-5190373 if user already typed "$" sign, it should not be duplicated
@@ -217,62 +172,3 @@ function suggestionIndexToWeight(index: number): string {
 
   return lastCharacter.repeat(duplicateTimes) + alphabet[remains];
 }
-
-function getLastSortText(suggestions: languages.CompletionItem[]): string {
-  // This char is like fallback value because
-  // every item which have empty weight gets random position in suggestions list.
-  let prefix = 'a';
-
-  const lastSuggestion = suggestions[suggestions.length - 1];
-  if (lastSuggestion !== undefined) {
-    prefix = lastSuggestion.sortText + 'a';
-  }
-
-  return prefix;
-}
-
-function getAggregateFunctionNamesToSuggest(): string[] {
-  return ['COUNT()', 'SUM()', 'AVG()', 'MIN()', 'MAX(), COUNT_IF(), SUM_IF(), AVG_IF(), SOME()'];
-}
-
-function getFunctionInsertTextWithCursor(functionTemplate: string): string {
-  let normalizedFunctionTemplate = functionTemplate;
-  if (functionTemplate.startsWith('$')) {
-    normalizedFunctionTemplate = `\\${functionTemplate}`;
-  }
-  return `${normalizedFunctionTemplate.slice(0, -1)}$1)`;
-}
-
-function getFunctionNamesToSuggest(): string[] {
-  return [
-    '$__timeFilter()',
-    '$__varFallback()',
-    'LENGTH()',
-    'SUBSTRING()',
-    'FIND()',
-    'RFIND()',
-    'StartsWith()',
-    'EndsWith()',
-    'EndsWith()',
-    'IF()',
-    'NANVL()',
-    'Random()',
-    'RandomNumber()',
-    'RandomUuid()',
-    'MIN_OF()',
-    'MIN_OF()',
-    'COALESCE()',
-    'String::Reverse()',
-    'String::Substring()',
-    'Math::Abs()',
-    'Math::Pi()',
-    'Math::Log()',
-    'Math::Log10()',
-    'Math::Floor()',
-    'Math::Ceil()',
-    'Math::Round()',
-    'Math::Sqrt()',
-    'Math::Sin()',
-    'Math::Cos()',
-  ];
-}
@@ -7,6 +7,10 @@ export const Components = {
       options: {
         SQLEditor: 'SQL Editor',
         QueryBuilder: 'Query Builder',
+        Traces: 'Traces',
+        Topic: 'Topic',
+        TopicLag: 'Topic lag',
+        System: 'System',
       },
       switcher: {
         title: 'Are you sure?',
@@ -33,6 +37,14 @@ export const Components = {
       label: 'Log level field',
       tooltip: 'Select the field to extract log level information from',
     },
+    LogContextColumns: {
+      label: 'Log context',
+      tooltip: 'Fields equal in rows around a log line, e.g. the host',
+    },
+    LogContextField: {
+      label: 'Log context order',
+      tooltip: 'Unique field ordering rows around a log line with the same time',
+    },
     LogTimeField: {
       Name: {
         label: 'Log time field',
@@ -77,6 +89,107 @@ export const Components = {
       tooltip: 'Number of records/results to show',
     },
   },
+  Traces: {
+    Mode: {
+      label: 'Mode',
+      tooltip: 'Show spans of a trace or search traces',
+    },
+    TraceId: {
+      label: 'Trace ID',
+      tooltip: 'ID of the trace to show',
+    },
+    Column: {
+      tooltip: 'Column of the table of spans',
+    },
+    DurationUnit: {
+      label: 'Duration unit',
+      tooltip: 'Unit of numeric span durations',
+    },
+    ServiceName: {
+      label: 'Service',
+      tooltip: 'Find traces with spans of the service',
+    },
+    OperationName: {
+      label: 'Operation',
+      tooltip: 'Find traces with spans of the operation',
+    },
+    MinDuration: {
+      label: 'Min duration',
+      tooltip: 'Minimal duration of the first span of traces, e.g. 100ms',
+    },
+    MaxDuration: {
+      label: 'Max duration',
+      tooltip: 'Maximal duration of the first span of traces, e.g. 2s',
+    },
+  },
+  Topic: {
+    Source: {
+      label: 'Source',
+      tooltip: 'Read a topic or the changefeed of a table',
+    },
+    Path: {
+      label: 'Topic',
+      tooltip: 'Path of the topic in the database',
+    },
+    Changefeed: {
+      label: 'Changefeed',
+      tooltip: 'Changefeed of the table in the JSON format, records are decoded into key, old and new columns',
+    },
+    LagConsumer: {
+      label: 'Consumer',
+      tooltip: 'Consumer of the topic, metrics of all consumers are returned when empty',
+    },
+    Consumer: {
+      label: 'Consumer',
+      tooltip:
+        'Consumer dedicated to Grafana, offsets are never committed. Readers of a consumer share its partitions, so a consumer of an application loses partitions while the stream runs. Without a consumer every subscription reads all messages',
+    },
+    StartFrom: {
+      label: 'Start from',
+      tooltip:
+        'RFC 3339 time or a duration before the subscription like 15m. By default consumers start from their offsets, other streams from the subscription',
+    },
+    Parser: {
+      label: 'Parser',
+      tooltip: 'Show payloads as text or extract fields of JSON payloads',
+    },
+    Fields: {
+      label: 'Fields',
+      tooltip: 'Dot separated paths of JSON values, e.g. user.id. Keys of the first message by default',
+    },
+    RateLimit: {
+      label: 'Rate limit',
+      tooltip: 'Maximal number of messages per second, unlimited when empty',
+    },
+    Limit: {
+      label: 'Limit',
+      tooltip: 'Number of the last messages shown',
+    },
+  },
+  System: {
+    View: {
+      label: 'System view',
+      tooltip: 'View of the .sys folder of the database',
+    },
+    Window: {
+      label: 'Window',
+      tooltip: 'Interval of aggregation of top queries and partitions',
+    },
+    Limit: {
+      label: 'Limit',
+      tooltip: 'Maximal number of rows',
+    },
+  },
+  Stream: {
+    Column: {
+      label: 'Stream column',
+      tooltip: 'Monotonic column, like a timestamp or a sequence, new rows are streamed live when it is set',
+    },
+    Interval: {
+      label: 'Poll interval',
+      tooltip: 'Interval of polls for new rows, e.g. 5s',
+    },
+  },
   ConfigEditor: {
     Endpoint: {
       label: 'Endpoint',
@@ -119,6 +232,99 @@ export const Components = {
       label: 'Username',
       placeholder: 'Username',
     },
+    TokenEndpoint: {
+      label: 'Token endpoint',
+      placeholder: 'https://auth.example.com/oauth/token',
+      tooltip: 'OAuth 2.0 token exchange endpoint',
+    },
+    Audience: {
+      label: 'Audience',
+      tooltip: 'Comma separated audiences of the exchanged token',
+    },
+    Scope: {
+      label: 'Scope',
+      tooltip: 'Space separated scopes of the exchanged token',
+    },
+    SubjectToken: {
+      label: 'Subject token',
+      placeholder: 'Token exchanged for a YDB token',
+      tooltip: 'Subject token of the token exchange',
+    },
+    SubjectTokenType: {
+      label: 'Subject token type',
+      placeholder: 'urn:ietf:params:oauth:token-type:access_token',
+      tooltip: 'Type of the subject token',
+    },
+    SigningMethod: {
+      label: 'Signing method',
+      tooltip: 'Algorithm signing the JWT subject token',
+    },
+    PrivateKey: {
+      label: 'Private key',
+      placeholder: 'PEM key for RSA and ECDSA, base64 secret for HMAC',
+      tooltip: 'Key signing the JWT subject token',
+    },
+    KeyID: {
+      label: 'Key ID',
+      tooltip: 'kid header of the JWT subject token',
+    },
+    Issuer: {
+      label: 'Issuer',
+      tooltip: 'iss claim of the JWT subject token',
+    },
+    Subject: {
+      label: 'Subject',
+      tooltip: 'sub claim of the JWT subject token',
+    },
+    ForwardOAuthIdentity: {
+      description:
+        'Queries run with the OAuth token of the Grafana user, Grafana should be configured with OAuth authentication',
+    },
+    ConnectTimeout: {
+      label: 'Connect timeout',
+      placeholder: '10s',
+      tooltip: 'Timeout of connecting to the database, a duration like 1500ms or a number of seconds',
+    },
+    QueryTimeout: {
+      label: 'Query timeout',
+      placeholder: '60s',
+      tooltip: 'Maximum duration of a query, a query can set a shorter timeout',
+    },
+    MetadataTimeout: {
+      label: 'Metadata timeout',
+      placeholder: '10s',
+      tooltip: 'Timeout of listing tables and fields',
+    },
+    MaxOpenConns: {
+      label: 'Max open connections',
+      placeholder: 'unlimited',
+      tooltip: 'Maximum number of open connections',
+    },
+    MaxIdleConns: {
+      label: 'Max idle connections',
+      placeholder: '2',
+      tooltip: 'Maximum number of idle connections',
+    },
+    ConnMaxLifetime: {
+      label: 'Connection max lifetime',
+      placeholder: 'unlimited',
+      tooltip: 'Maximum lifetime of a connection, a duration like 30m',
+    },
+    SessionPoolSizeLimit: {
+      label: 'Session pool size',
+      placeholder: '50',
+      tooltip: 'Maximum number of YDB sessions',
+    },
+    SessionIdleThreshold: {
+      label: 'Session idle threshold',
+      placeholder: '5m',
+      tooltip: 'Idle time after which YDB sessions are closed, a duration like 5m',
+    },
+    SchemaCacheTTL: {
+      label: 'Schema cache TTL',
+      placeholder: '1m',
+      tooltip: 'How long lists of tables and fields are cached, 0 disables the cache',
+    },
   },
 };
 export const selectors: { components: E2ESelectors<typeof Components> } = {